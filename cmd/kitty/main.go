@@ -30,6 +30,9 @@ const help = `Usage:
   kitty install
   kitty add <hook-name> <cmd>
   kitty tools install <tool-name>
+  kitty config get|set|unset|list <key> [--user|--repo]
+  kitty config explain
+  kitty config validate
   kitty @extension ...
 `
 
@@ -44,6 +47,7 @@ func main() {
 
 	app.AddCommand(hooks.Commands()...)
 	app.AddCommand(tools.Commands()...)
+	app.AddCommand(config.Commands()...)
 
 	app.AddCommand(
 		&cobra.Command{
@@ -130,26 +134,11 @@ func main() {
 }
 
 func mayUseAnotherKitty() error {
-	wd, err := os.Getwd()
-	if err != nil {
-		return ee.Wrap(err, "cannot get working directory")
-	}
-
-	gitRoot, err := git.GetRoot(wd)
-	if err != nil {
-		return nil // cannot found git root
-	}
-
-	c, err := config.GetKittyConfig(gitRoot)
-	if err != nil {
-		if config.IsNotExist(err) {
-			return nil // no kitty config
-		} else {
-			return ee.Wrap(err, "cannot get kitty config")
-		}
+	requiredVersion, err := config.RequiredKittyVersion("")
+	if err != nil { // use the current kitty, the commands using the config report the problem (e.g. `kitty config validate`)
+		slog.Debug("Cannot get the required kitty version", "error", err)
+		return nil
 	}
-
-	requiredVersion := kittyversion.ParseRequired(c)
 	if requiredVersion == "" {
 		return nil // no required version
 	}
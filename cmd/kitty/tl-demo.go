@@ -19,7 +19,7 @@ func init() {
 		Use:    "tl",
 		Hidden: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return tl.New([]*tl.Task{
+			_, err := tl.New([]*tl.Task{
 				{
 					Title: "task1",
 					Run: func(callback tl.TaskCallback) error {
@@ -167,6 +167,7 @@ func init() {
 			},
 			//tl.WithExitOnError(false),
 			).Run()
+			return err
 		},
 	}
 }
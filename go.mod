@@ -3,18 +3,20 @@ module github.com/ImSingee/kitty
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/ImSingee/go-ex v0.4.43
 	github.com/ImSingee/tt v1.0.4
 	github.com/Masterminds/semver/v3 v3.4.0
 	github.com/alessio/shellescape v1.4.2
 	github.com/charmbracelet/bubbletea v0.24.2
 	github.com/go-git/go-git/v5 v5.9.0
-	github.com/gobwas/glob v0.2.3
 	github.com/google/shlex v0.0.0-20191202100458-e7afc7fbc510
 	github.com/google/uuid v1.3.1
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 	github.com/spf13/cobra v1.7.0
 	github.com/stretchr/testify v1.8.4
 	github.com/ysmood/gson v0.7.3
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -58,5 +60,4 @@ require (
 	golang.org/x/text v0.13.0 // indirect
 	golang.org/x/tools v0.13.0 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,420 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/pp"
+	"github.com/spf13/cobra"
+	"github.com/ysmood/gson"
+
+	"github.com/ImSingee/kitty/internal/lib/jsonfmt"
+)
+
+func Commands() []*cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "read and change kitty config",
+		Long: `Read and change kitty config.
+
+Values are resolved by layers (the later ones take precedence):
+defaults, the user-level config, the repo-level config and environment variables.
+
+Keys are separated by dots, and keys containing special characters must be quoted in brackets,
+e.g. tools.gci or lint-staged["*.go"]`,
+	}
+
+	cmd.AddCommand(
+		getCommand(),
+		setCommand(),
+		unsetCommand(),
+		listCommand(),
+		validateCommand(),
+		explainCommand(),
+		updateCommand(),
+	)
+
+	return []*cobra.Command{cmd}
+}
+
+type scopeOptions struct {
+	user bool
+	repo bool
+}
+
+func (o *scopeOptions) addFlags(cmd *cobra.Command, action string) {
+	cmd.Flags().BoolVar(&o.user, "user", false, action+" the user-level config")
+	cmd.Flags().BoolVar(&o.repo, "repo", false, action+" the repo-level config")
+	cmd.MarkFlagsMutuallyExclusive("user", "repo")
+}
+
+// load loads the config of the selected scope (or the resolved one if no scope selected)
+func (o *scopeOptions) load() (*Merged, error) {
+	switch {
+	case o.user:
+		m, err := LoadUserConfig()
+		if IsNotExist(err) {
+			return mergeLayers(nil), nil
+		}
+		return m, err
+	case o.repo:
+		root, err := RepoRoot("")
+		if err != nil {
+			return nil, err
+		}
+		m, err := LoadKittyConfig(root)
+		if IsNotExist(err) {
+			return mergeLayers(nil), nil
+		}
+		return m, err
+	default:
+		return Resolve("")
+	}
+}
+
+// patch patches the config file of the selected scope (the repo-level one by default)
+func (o *scopeOptions) patch(patch func(map[string]gson.JSON) (save bool, err error)) error {
+	if o.user {
+		return PatchUserConfig(patch)
+	}
+
+	root, err := RepoRoot("")
+	if err != nil {
+		return err
+	}
+
+	return PatchKittyConfig(root, patch)
+}
+
+func getCommand() *cobra.Command {
+	o := &scopeOptions{}
+	showOrigin := false
+
+	cmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "print the value of a config key",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := ParsePath(args[0])
+			if err != nil {
+				return err
+			}
+
+			m, err := o.load()
+			if err != nil {
+				return err
+			}
+
+			value, ok := m.Get(path...)
+			if !ok {
+				return ee.Errorf("key %s is not set", FormatPath(anyPath(path)))
+			}
+
+			if showOrigin {
+				pp.Printf("%s\t%s\n", formatOrigin(m.Origin(path...)), formatValue(value))
+			} else {
+				pp.Println(formatValue(value))
+			}
+			return nil
+		},
+	}
+
+	o.addFlags(cmd, "read")
+	cmd.Flags().BoolVar(&showOrigin, "show-origin", false, "show where the value comes from")
+
+	return cmd
+}
+
+func setCommand() *cobra.Command {
+	o := &scopeOptions{}
+	isJSON := false
+
+	cmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "set a config key (in the repo-level config by default)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := ParsePath(args[0])
+			if err != nil {
+				return err
+			}
+
+			var value any = args[1]
+			if isJSON {
+				if err := json.Unmarshal([]byte(args[1]), &value); err != nil {
+					return ee.Wrap(err, "invalid json value")
+				}
+			}
+
+			return o.patch(func(c map[string]gson.JSON) (bool, error) {
+				return true, setValue(c, path, value)
+			})
+		},
+	}
+
+	o.addFlags(cmd, "change")
+	cmd.Flags().BoolVar(&isJSON, "json", false, "parse the value as json")
+
+	return cmd
+}
+
+func unsetCommand() *cobra.Command {
+	o := &scopeOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "unset <key>",
+		Short: "remove a config key (from the repo-level config by default)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := ParsePath(args[0])
+			if err != nil {
+				return err
+			}
+
+			return o.patch(func(c map[string]gson.JSON) (bool, error) {
+				if !unsetValue(c, path) {
+					return false, ee.Errorf("key %s is not set", FormatPath(anyPath(path)))
+				}
+				return true, nil
+			})
+		},
+	}
+
+	o.addFlags(cmd, "change")
+
+	return cmd
+}
+
+func listCommand() *cobra.Command {
+	o := &scopeOptions{}
+	showOrigin := false
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list all config values",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := o.load()
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range m.Entries() {
+				line := FormatPath(anyPath(entry.Path)) + "=" + formatValue(entry.Value)
+				if showOrigin {
+					line = formatOrigin(entry.Origin) + "\t" + line
+				}
+
+				pp.Println(line)
+			}
+			return nil
+		},
+	}
+
+	o.addFlags(cmd, "read")
+	cmd.Flags().BoolVar(&showOrigin, "show-origin", false, "show where every value comes from")
+
+	return cmd
+}
+
+func validateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [file...]",
+		Short: "validate the config files against the json schema",
+		Long: `Validate the config files against the json schema.
+
+If no file given, all kitty and lint-staged config files in the current repository and the user-level config files are validated.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			files := args
+			var conflicts []string
+			if len(files) == 0 {
+				var err error
+				files, conflicts, err = FindConfigFiles("")
+				if err != nil {
+					return err
+				}
+			}
+
+			count := len(conflicts)
+			for _, conflict := range conflicts {
+				pp.ERedPrintln(conflict)
+			}
+
+			for _, file := range files {
+				problems, err := ValidateFile(file)
+				if err != nil {
+					problems = []*Problem{{Message: err.Error()}}
+				}
+
+				if len(problems) == 0 {
+					pp.GreenPrintf("%s: ok\n", relPath(file))
+					continue
+				}
+
+				count += len(problems)
+				pp.ERedPrintln(relPath(file) + ":")
+				for _, p := range problems {
+					pp.ERedPrintln("  " + p.String())
+				}
+			}
+
+			if count != 0 {
+				return ee.Errorf("found %d problem(s)", count)
+			}
+			return nil
+		},
+	}
+}
+
+func explainCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain",
+		Short: "show the merged config and where every value comes from",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := Resolve("")
+			if err != nil {
+				return err
+			}
+
+			printExplain(m)
+			return nil
+		},
+	}
+}
+
+func updateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "update",
+		Short: "resolve the extended remote sources again and update " + LockFileName,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, err := RepoRoot("")
+			if err != nil {
+				return err
+			}
+
+			m, err := UpdateKittyConfigLock(root)
+			if err != nil {
+				return err
+			}
+
+			printExplain(m)
+			return nil
+		},
+	}
+}
+
+func printExplain(m *Merged) {
+	pp.Println("Sources (later ones take precedence):")
+	for i, layer := range m.Layers {
+		pp.Printf("  %d. %s\n", i+1, formatOrigin(layer.Origin))
+	}
+
+	entries := m.Entries()
+	if len(entries) == 0 {
+		return
+	}
+
+	pp.Println()
+	pp.Println("Merged config:")
+	for _, entry := range entries {
+		value, err := jsonfmt.Marshal(entry.Value, "")
+		if err != nil {
+			value = []byte("?")
+		}
+
+		pp.Printf("  %s = %s %s\n", FormatPath(anyPath(entry.Path)), value, symGray("# "+formatOrigin(entry.Origin)))
+	}
+}
+
+// formatValue formats strings as is, and other values as json
+func formatValue(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+
+	data, err := jsonfmt.Marshal(v, "")
+	if err != nil {
+		return "?"
+	}
+	return string(data)
+}
+
+// formatOrigin formats the origin like `git config --show-origin`,
+// local files are shown as "file:<path relative to current working directory>"
+func formatOrigin(origin string) string {
+	if !filepath.IsAbs(origin) {
+		return origin
+	}
+
+	return "file:" + relPath(origin)
+}
+
+// relPath returns the path relative to current working directory (if possible)
+func relPath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			return rel
+		}
+	}
+
+	return path
+}
+
+func setValue(c map[string]gson.JSON, path []string, value any) error {
+	raw := rawConfig(c)
+
+	obj := raw
+	for i, p := range path[:len(path)-1] {
+		next, exists := obj[p]
+		if !exists || next == nil {
+			next = map[string]any{}
+			obj[p] = next
+		}
+
+		nextObj, ok := next.(map[string]any)
+		if !ok {
+			return ee.Errorf("cannot set %s: %s is not an object", FormatPath(anyPath(path)), FormatPath(anyPath(path[:i+1])))
+		}
+		obj = nextObj
+	}
+	obj[path[len(path)-1]] = value
+
+	c[path[0]] = gson.New(raw[path[0]])
+	return nil
+}
+
+// unsetValue returns false if the key does not exist
+func unsetValue(c map[string]gson.JSON, path []string) bool {
+	raw := rawConfig(c)
+
+	obj := raw
+	for _, p := range path[:len(path)-1] {
+		next, ok := obj[p].(map[string]any)
+		if !ok {
+			return false
+		}
+		obj = next
+	}
+
+	if _, ok := obj[path[len(path)-1]]; !ok {
+		return false
+	}
+	delete(obj, path[len(path)-1])
+
+	if len(path) == 1 {
+		delete(c, path[0])
+	} else {
+		c[path[0]] = gson.New(raw[path[0]])
+	}
+	return true
+}
+
+var gray = pp.GetColor(38, 5, 240)
+
+func symGray(s string) string {
+	return pp.ColorString(gray, s).GetForStdout()
+}
@@ -4,37 +4,62 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/ImSingee/go-ex/ee"
-	"github.com/ImSingee/go-ex/exjson"
 	"github.com/ysmood/gson"
-
-	"github.com/ImSingee/kitty/internal/lib/jsonfmt"
 )
 
 var ConfigFileNames = []string{
 	".kittyrc.json",
 	".kittyrc",
 	"kitty.config.json",
+	".kittyrc.yaml",
+	".kittyrc.yml",
+	"kitty.config.toml",
 }
 
 func ReadKittyConfig(filename string) (map[string]gson.JSON, error) {
-	// only parse json now
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
 
-	var obj map[string]any
-	err := exjson.Read(filename, &obj)
+	c, err := Unmarshal(filename, data)
 	if err != nil {
 		return nil, err
 	}
 
-	return gson.New(obj).Map(), nil
+	return gson.New(c).Map(), nil
 }
 
 // GetKittyConfig will find the kitty config file in the given directory
+// and merge it with everything it extends (see ExtendsKey)
 //
 // if dir is empty, it will use the current working directory
 // if no config file found, it will return an error wrapped ErrNotExist, use IsNotExist to check
 func GetKittyConfig(dir string) (map[string]gson.JSON, error) {
+	m, err := LoadKittyConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.Values(), nil
+}
+
+// LoadKittyConfig is like GetKittyConfig, but returns the merged result
+// which also knows where every value comes from
+func LoadKittyConfig(dir string) (*Merged, error) {
+	return loadKittyConfigIn(dir, false)
+}
+
+// UpdateKittyConfigLock resolves the remote sources the config extends again
+// (ignoring the pinned versions) and rewrites the lock file
+func UpdateKittyConfigLock(dir string) (*Merged, error) {
+	return loadKittyConfigIn(dir, true)
+}
+
+func loadKittyConfigIn(dir string, refresh bool) (*Merged, error) {
 	filename, c, err := getKittyConfig(dir)
 	if err != nil {
 		return nil, err
@@ -43,55 +68,119 @@ func GetKittyConfig(dir string) (map[string]gson.JSON, error) {
 		return nil, ee.Wrap(ErrNotExist, "cannot find kitty config file")
 	}
 
-	return c, nil
+	m, err := loadKittyConfig(filename, c, refresh)
+	if err != nil {
+		return nil, ee.Wrapf(err, "cannot load kitty config file %s", filename)
+	}
+
+	return m, nil
 }
 
-// TODO report error if there's more than one config
+// LoadKittyConfigFile loads the given kitty config file with everything it extends
+func LoadKittyConfigFile(filename string) (*Merged, error) {
+	c, err := ReadKittyConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := loadKittyConfig(filename, c, false)
+	if err != nil {
+		return nil, ee.Wrapf(err, "cannot load kitty config file %s", filename)
+	}
+
+	return m, nil
+}
 
 func getKittyConfig(dir string) (string, map[string]gson.JSON, error) {
+	return findKittyConfig(dir, ConfigFileNames)
+}
+
+// findKittyConfig finds the existing config file (with one of the given names) in dir
+//
+// it's an error if there's more than one config file in dir
+func findKittyConfig(dir string, names []string) (string, map[string]gson.JSON, error) {
+	files, err := findKittyConfigFiles(dir, names)
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch len(files) {
+	case 0:
+		return "", nil, nil
+	case 1:
+	default:
+		return files[0], nil, fmt.Errorf("multiple kitty config files found in the same directory: [%s], please keep only one of them", strings.Join(files, ", "))
+	}
+
+	filename := files[0]
+	c, err := ReadKittyConfig(filename)
+	if err != nil {
+		return filename, nil, fmt.Errorf("cannot read kitty config file %s: %w", filename, err)
+	}
+
+	return filename, c, nil
+}
+
+// findKittyConfigFiles returns all existing config files (with one of the given names) in dir
+func findKittyConfigFiles(dir string, names []string) ([]string, error) {
 	if dir == "" {
 		var err error
 		dir, err = os.Getwd()
 		if err != nil {
-			return "", nil, fmt.Errorf("cannot get working directory: %w", err)
+			return nil, fmt.Errorf("cannot get working directory: %w", err)
 		}
 	}
 
-	for _, name := range ConfigFileNames {
+	var files []string
+	for _, name := range names {
 		filename := filepath.Join(dir, name)
-		c, err := ReadKittyConfig(filename)
+		_, err := os.Stat(filename)
 		if err != nil {
 			if ee.Is(err, os.ErrNotExist) {
 				continue
 			}
 
-			return filename, nil, fmt.Errorf("cannot read kitty config file %s: %w", filename, err)
+			return nil, fmt.Errorf("cannot read kitty config file %s: %w", filename, err)
 		}
 
-		return filename, c, nil
+		files = append(files, filename)
 	}
 
-	return "", nil, nil
+	return files, nil
 }
 
+// PatchKittyConfig patches the kitty config file in the given directory (it won't touch the extended files)
+//
+// the config is written back in the format of the file (json, yaml or toml),
+// for yaml the comments and the order of the unchanged values are kept,
+// and toml files containing comments are refused to update
 func PatchKittyConfig(dir string, patch func(map[string]gson.JSON) (save bool, err error)) error {
-	filename, c, err := getKittyConfig(dir)
+	return patchKittyConfig(dir, ConfigFileNames, patch)
+}
+
+func patchKittyConfig(dir string, names []string, patch func(map[string]gson.JSON) (save bool, err error)) error {
+	filename, c, err := findKittyConfig(dir, names)
 	if err != nil {
 		return err
 	}
 
 	if filename == "" { // config not exist, generate one
-		filename = filepath.Join(dir, ConfigFileNames[0])
+		filename = filepath.Join(dir, names[0])
 		c = make(map[string]gson.JSON)
 	}
 
+	before, err := normalize(rawConfig(c))
+	if err != nil {
+		return ee.Wrap(err, "invalid config")
+	}
+
 	save, err := patch(c)
 	if err != nil {
 		return err
 	}
 
 	if save {
-		err = saveKittyConfig(filename, c)
+		err = saveKittyConfig(filename, before, c)
 		if err != nil {
 			return err
 		}
@@ -100,16 +189,30 @@ func PatchKittyConfig(dir string, patch func(map[string]gson.JSON) (save bool, e
 	return nil
 }
 
-func saveKittyConfig(filename string, c map[string]gson.JSON) error {
-	raw := make(map[string]any, len(c))
-	for key, value := range c {
-		raw[key] = value.Val()
+func saveKittyConfig(filename string, before map[string]any, c map[string]gson.JSON) error {
+	after, err := normalize(rawConfig(c))
+	if err != nil {
+		return ee.Wrap(err, "cannot encode config")
+	}
+
+	original, err := os.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return ee.Wrapf(err, "cannot read kitty config file %s", filename)
 	}
 
-	data, err := jsonfmt.Marshal(raw, "  ")
+	data, err := marshal(filename, original, before, after)
 	if err != nil {
-		return ee.Wrap(err, "cannot json encode config")
+		return ee.Wrapf(err, "cannot encode config as %s", detectFormat(filename))
 	}
 
 	return os.WriteFile(filename, data, 0644)
 }
+
+func rawConfig(c map[string]gson.JSON) map[string]any {
+	raw := make(map[string]any, len(c))
+	for key, value := range c {
+		raw[key] = value.Val()
+	}
+
+	return raw
+}
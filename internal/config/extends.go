@@ -0,0 +1,270 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ysmood/gson"
+)
+
+// ExtendsKey is the config key to declare the sources the config extends
+//
+// The value can be a string or a list of strings, each one is one of
+//   - a file path, relative to the config file declaring it, e.g. "./shared/kitty.json"
+//   - a git source, e.g. "git+https://github.com/org/kitty-presets.git#v2:go.json"
+//     (the part after # is "<ref>:<path>", both are optional)
+//   - an http(s) url, e.g. "https://example.com/kitty.json"
+//
+// Sources listed later override the former ones,
+// and the config file itself overrides everything it extends.
+const ExtendsKey = "extends"
+
+type source interface {
+	// String returns the canonical name of the source, it's also the key in lock file
+	String() string
+	// read returns the config file name (used to detect the format) and its content
+	read(r *extendsResolver) (name string, data []byte, err error)
+	// resolve resolves a reference declared inside the source
+	resolve(ref string) (source, error)
+}
+
+func parseSource(ref string, base source) (source, error) {
+	ref = strings.TrimSpace(ref)
+
+	switch {
+	case ref == "":
+		return nil, ee.New("empty source")
+	case strings.HasPrefix(ref, "git+"):
+		return parseGitSource(ref)
+	case strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://"):
+		return &urlSource{url: ref}, nil
+	case strings.Contains(ref, "://"):
+		return nil, ee.Errorf("unsupported source %s", ref)
+	default:
+		return base.resolve(ref)
+	}
+}
+
+type fileSource struct {
+	path string
+}
+
+func (s *fileSource) String() string {
+	return s.path
+}
+
+func (s *fileSource) read(r *extendsResolver) (string, []byte, error) {
+	data, err := os.ReadFile(s.path)
+	return s.path, data, err
+}
+
+func (s *fileSource) resolve(ref string) (source, error) {
+	if !filepath.IsAbs(ref) {
+		ref = filepath.Join(filepath.Dir(s.path), ref)
+	}
+
+	return &fileSource{path: filepath.Clean(ref)}, nil
+}
+
+type gitSource struct {
+	url  string
+	ref  string // empty means the default branch
+	path string // empty means the kitty config file in the repo root
+
+	commit string // pinned commit
+}
+
+func parseGitSource(ref string) (*gitSource, error) {
+	u, fragment, _ := strings.Cut(strings.TrimPrefix(ref, "git+"), "#")
+	if u == "" {
+		return nil, ee.Errorf("invalid git source %s: missing url", ref)
+	}
+
+	gitRef, p, _ := strings.Cut(fragment, ":")
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+
+	return &gitSource{url: u, ref: gitRef, path: p}, nil
+}
+
+func (s *gitSource) String() string {
+	result := "git+" + s.url
+	if s.ref != "" || s.path != "" {
+		result += "#" + s.ref
+	}
+	if s.path != "" {
+		result += ":" + s.path
+	}
+	return result
+}
+
+func (s *gitSource) read(r *extendsResolver) (string, []byte, error) {
+	return r.readGit(s)
+}
+
+func (s *gitSource) resolve(ref string) (source, error) {
+	if path.IsAbs(ref) {
+		return nil, ee.Errorf("cannot use absolute path %s inside %s", ref, s)
+	}
+
+	return &gitSource{
+		url:    s.url,
+		ref:    s.ref,
+		path:   path.Join(path.Dir(s.path), ref),
+		commit: s.commit,
+	}, nil
+}
+
+type urlSource struct {
+	url string
+}
+
+func (s *urlSource) String() string {
+	return s.url
+}
+
+func (s *urlSource) read(r *extendsResolver) (string, []byte, error) {
+	return r.readURL(s)
+}
+
+func (s *urlSource) resolve(ref string) (source, error) {
+	base, err := url.Parse(s.url)
+	if err != nil {
+		return nil, ee.Wrapf(err, "invalid url %s", s.url)
+	}
+	rel, err := url.Parse(ref)
+	if err != nil {
+		return nil, ee.Wrapf(err, "invalid reference %s", ref)
+	}
+
+	return &urlSource{url: base.ResolveReference(rel).String()}, nil
+}
+
+type extendsResolver struct {
+	lock    *lockFile
+	refresh bool // ignore locked and cached versions
+	local   bool // skip the remote sources
+
+	stack []string
+}
+
+// resolveLayers returns all layers of the config file (the lowest precedence first)
+//
+// c is the parsed content of src, the returned layers always end with the layer of src itself
+func (r *extendsResolver) resolveLayers(src source, c map[string]any) ([]*Layer, error) {
+	name := src.String()
+	for _, s := range r.stack {
+		if s == name {
+			return nil, ee.Errorf("circular extends: %s -> %s", strings.Join(r.stack, " -> "), name)
+		}
+	}
+	r.stack = append(r.stack, name)
+	defer func() {
+		r.stack = r.stack[:len(r.stack)-1]
+	}()
+
+	refs, err := parseExtends(c[ExtendsKey])
+	if err != nil {
+		return nil, ee.Wrapf(err, "invalid %s in %s", ExtendsKey, name)
+	}
+
+	var layers []*Layer
+	for _, ref := range refs {
+		sub, err := parseSource(ref, src)
+		if err != nil {
+			return nil, ee.Wrapf(err, "cannot resolve %s extended by %s", ref, name)
+		}
+		if _, ok := sub.(*fileSource); r.local && !ok {
+			continue
+		}
+
+		subName, data, err := sub.read(r)
+		if err != nil {
+			return nil, ee.Wrapf(err, "cannot read %s extended by %s", sub, name)
+		}
+
+		subConfig, err := Unmarshal(subName, data)
+		if err != nil {
+			return nil, ee.Wrapf(err, "cannot parse %s extended by %s", sub, name)
+		}
+
+		subLayers, err := r.resolveLayers(sub, subConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		layers = append(layers, subLayers...)
+	}
+
+	values := make(map[string]any, len(c))
+	for k, v := range c {
+		if k != ExtendsKey {
+			values[k] = v
+		}
+	}
+
+	return append(layers, &Layer{Origin: name, Values: values}), nil
+}
+
+func parseExtends(v any) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []any:
+		result := make([]string, 0, len(v))
+		for i, vv := range v {
+			s, ok := vv.(string)
+			if !ok {
+				return nil, fmt.Errorf("item %d must be a string", i)
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("must be a string or a list of strings")
+	}
+}
+
+// loadKittyConfig loads the kitty config file with everything it extends
+//
+// the remote sources are checked against the lock file and read from the cache when possible,
+// only refresh (i.e. `kitty config update`) fetches them again and writes the lock file
+func loadKittyConfig(filename string, c map[string]gson.JSON, refresh bool) (*Merged, error) {
+	lock, err := readLockFile(filepath.Join(filepath.Dir(filename), LockFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	r := &extendsResolver{lock: lock, refresh: refresh}
+
+	layers, err := r.resolveLayers(&fileSource{path: filename}, rawConfig(c))
+	if err != nil {
+		return nil, err
+	}
+
+	if refresh {
+		if err := lock.save(); err != nil {
+			return nil, ee.Wrap(err, "cannot save lock file")
+		}
+	}
+
+	return mergeLayers(layers), nil
+}
+
+// loadLocalKittyConfig is like loadKittyConfig, but skips the remote sources extended (and so never uses the network)
+func loadLocalKittyConfig(filename string, c map[string]gson.JSON) (*Merged, error) {
+	r := &extendsResolver{local: true}
+
+	layers, err := r.resolveLayers(&fileSource{path: filename}, rawConfig(c))
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeLayers(layers), nil
+}
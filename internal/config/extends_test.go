@@ -0,0 +1,223 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetKittyConfigExtends(t *testing.T) {
+	t.Setenv(CacheDirEnv, t.TempDir())
+
+	presets := newPresetsRepo(t)
+	presets.commit(t, map[string]string{
+		"go.json":   `{"extends": "./base.json", "lint-staged": {"*.go": "gofmt -l"}, "tools": {"gci": "0.11.1"}}`,
+		"base.json": `{"lint-staged": {"*.md": "prettier"}, "registry": "https://git.example.com/"}`,
+	})
+	presets.git(t, "tag", "v2")
+
+	files := http.FileServer(http.Dir(writeFiles(t, map[string]string{
+		"kitty.json": `{"tools": {"gci": "0.12.0", "golangci-lint": "1.55.0"}, "registry": "https://url.example.com/"}`,
+	})))
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		files.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	repo := writeFiles(t, map[string]string{
+		"shared/kitty.json": `{"lint-staged": {"*.go": ["gofmt -l", "go vet"]}, "kitty": "0.2.2"}`,
+		".kittyrc.json": `{
+			"extends": ["git+file://` + presets.dir + `#v2:go.json", "./shared/kitty.json", "` + server.URL + `/kitty.json"],
+			"tools": {"golangci-lint": null, "gofumpt": "0.5.0"}
+		}`,
+	})
+
+	m, err := LoadKittyConfig(repo)
+	require.NoError(t, err)
+
+	gitSource := "git+file://" + presets.dir + "#v2:go.json"
+	assert.Equal(t, []string{
+		"git+file://" + presets.dir + "#v2:base.json",
+		gitSource,
+		filepath.Join(repo, "shared/kitty.json"),
+		server.URL + "/kitty.json",
+		filepath.Join(repo, ".kittyrc.json"),
+	}, layerOrigins(m))
+
+	c := m.Values()
+	assert.Equal(t, map[string]any{
+		"*.go": []any{"gofmt -l", "go vet"},
+		"*.md": "prettier",
+	}, c["lint-staged"].Val())
+	assert.Equal(t, map[string]any{
+		"gci":     "0.12.0",
+		"gofumpt": "0.5.0",
+	}, c["tools"].Val())
+	assert.Equal(t, "https://url.example.com/", c["registry"].Str())
+	assert.NotContains(t, c, ExtendsKey)
+
+	assert.Equal(t, filepath.Join(repo, "shared/kitty.json"), m.Origin("lint-staged", "*.go"))
+	assert.Equal(t, "git+file://"+presets.dir+"#v2:base.json", m.Origin("lint-staged", "*.md"))
+	assert.Equal(t, server.URL+"/kitty.json", m.Origin("tools", "gci"))
+	assert.Equal(t, filepath.Join(repo, ".kittyrc.json"), m.Origin("tools", "gofumpt"))
+	assert.Equal(t, "", m.Origin("tools", "golangci-lint"))
+
+	t.Run("locked sources are reproducible", func(t *testing.T) {
+		assert.NoFileExists(t, filepath.Join(repo, LockFileName), "only written by update")
+
+		_, err := UpdateKittyConfigLock(repo)
+		require.NoError(t, err)
+		lock, err := os.ReadFile(filepath.Join(repo, LockFileName))
+		require.NoError(t, err)
+
+		// move the tag to a new commit, the locked commit is still used
+		presets.commit(t, map[string]string{
+			"go.json": `{"lint-staged": {"*.go": "gofumpt -l"}}`,
+		})
+		presets.git(t, "tag", "-f", "v2")
+
+		m, err := LoadKittyConfig(repo)
+		require.NoError(t, err)
+		assert.Equal(t, "prettier", m.Values()["lint-staged"].Map()["*.md"].Str())
+
+		// the unlocked sources are read from the cache, and the lock file is only checked
+		require.NoError(t, os.Remove(filepath.Join(repo, LockFileName)))
+		before := downloads
+		m, err = LoadKittyConfig(repo)
+		require.NoError(t, err)
+		assert.Equal(t, "prettier", m.Values()["lint-staged"].Map()["*.md"].Str())
+		assert.Equal(t, "0.12.0", m.Values()["tools"].Get("gci").Str())
+		assert.Equal(t, before, downloads)
+		assert.NoFileExists(t, filepath.Join(repo, LockFileName))
+		require.NoError(t, os.WriteFile(filepath.Join(repo, LockFileName), lock, 0644))
+
+		// the updated source is used after the lock is updated
+		m, err = UpdateKittyConfigLock(repo)
+		require.NoError(t, err)
+		assert.NotContains(t, m.Values()["lint-staged"].Map(), "*.md")
+		assert.Equal(t, []string{
+			gitSource,
+			filepath.Join(repo, "shared/kitty.json"),
+			server.URL + "/kitty.json",
+			filepath.Join(repo, ".kittyrc.json"),
+		}, layerOrigins(m))
+	})
+
+	t.Run("remote content must match the lock", func(t *testing.T) {
+		t.Setenv(CacheDirEnv, t.TempDir()) // cold cache
+
+		lock, err := readLockFile(filepath.Join(repo, LockFileName))
+		require.NoError(t, err)
+		lock.entries[server.URL+"/kitty.json"].Integrity = integrityOf([]byte("{}"))
+		lock.used = map[string]*lockEntry{}
+		for k, v := range lock.entries {
+			lock.used[k] = v
+		}
+		lock.entries = map[string]*lockEntry{}
+		require.NoError(t, lock.save())
+
+		_, err = LoadKittyConfig(repo)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "does not match")
+	})
+}
+
+func TestGetKittyConfigExtendsCircular(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		"a.json":        `{"extends": "./b.json"}`,
+		"b.json":        `{"extends": "./a.json"}`,
+		".kittyrc.json": `{"extends": "./a.json"}`,
+	})
+
+	_, err := GetKittyConfig(repo)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "circular extends")
+}
+
+func TestGetKittyConfigWithoutExtends(t *testing.T) {
+	repo := writeFiles(t, map[string]string{
+		".kittyrc.json": `{"tools": {"gci": "0.11.1"}}`,
+	})
+
+	c, err := GetKittyConfig(repo)
+	require.NoError(t, err)
+	assert.Equal(t, "0.11.1", c["tools"].Get("gci").Str())
+	assert.NoFileExists(t, filepath.Join(repo, LockFileName))
+
+	_, err = GetKittyConfig(t.TempDir())
+	assert.True(t, IsNotExist(err))
+}
+
+type presetsRepo struct {
+	dir  string
+	work string
+}
+
+func newPresetsRepo(t *testing.T) *presetsRepo {
+	t.Helper()
+
+	r := &presetsRepo{dir: t.TempDir(), work: t.TempDir()}
+	runGit(t, r.dir, "init", "--bare", "--quiet")
+	runGit(t, r.work, "init", "--quiet")
+	runGit(t, r.work, "config", "user.name", "Test User")
+	runGit(t, r.work, "config", "user.email", "test@example.com")
+	runGit(t, r.work, "remote", "add", "origin", r.dir)
+
+	return r
+}
+
+func (r *presetsRepo) commit(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(r.work, name), []byte(content), 0644))
+	}
+	runGit(t, r.work, "add", ".")
+	runGit(t, r.work, "commit", "--quiet", "-m", "update")
+	runGit(t, r.work, "push", "--quiet", "--force", "origin", "HEAD:refs/heads/main")
+}
+
+func (r *presetsRepo) git(t *testing.T, args ...string) {
+	t.Helper()
+
+	runGit(t, r.work, args...)
+	runGit(t, r.work, "push", "--quiet", "--force", "--tags", "origin")
+}
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(output))
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0755))
+		require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	}
+
+	return dir
+}
+
+func layerOrigins(m *Merged) []string {
+	result := make([]string, len(m.Layers))
+	for i, layer := range m.Layers {
+		result[i] = layer.Origin
+	}
+	return result
+}
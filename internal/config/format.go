@@ -0,0 +1,245 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ImSingee/go-ex/ee"
+	"gopkg.in/yaml.v3"
+
+	"github.com/ImSingee/kitty/internal/lib/jsonfmt"
+)
+
+type format string
+
+const (
+	formatJSON format = "json"
+	formatYAML format = "yaml"
+	formatTOML format = "toml"
+)
+
+// detectFormat detects config format by filename, files without known extension (e.g. `.kittyrc`) are json
+func detectFormat(filename string) format {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	default:
+		return formatJSON
+	}
+}
+
+// Unmarshal parses the content of a config file, the format is detected by filename (see detectFormat)
+//
+// the result always uses json types (e.g. numbers are float64), whatever the format is
+func Unmarshal(filename string, data []byte) (map[string]any, error) {
+	var obj map[string]any
+
+	switch detectFormat(filename) {
+	case formatYAML:
+		if err := yaml.Unmarshal(data, &obj); err != nil {
+			return nil, err
+		}
+	case formatTOML:
+		if err := toml.Unmarshal(data, &obj); err != nil {
+			return nil, err
+		}
+	default:
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return nil, err
+		}
+	}
+
+	if obj == nil {
+		return nil, ee.New("config must be an object")
+	}
+
+	return normalize(obj)
+}
+
+// normalize converts values to the types produced by encoding/json
+func normalize(obj map[string]any) (map[string]any, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// marshal encodes the config in the format of filename
+//
+// original is the current content of the file, and before is the parsed result of it,
+// they are used to keep the comments and the order of unchanged values for formats support comments
+func marshal(filename string, original []byte, before, after map[string]any) ([]byte, error) {
+	switch detectFormat(filename) {
+	case formatYAML:
+		return marshalYAML(original, before, after)
+	case formatTOML:
+		return marshalTOML(filename, original, after)
+	default:
+		return jsonfmt.Marshal(after, "  ")
+	}
+}
+
+func marshalYAML(original []byte, before, after map[string]any) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(original, &doc); err != nil {
+		return nil, err
+	}
+
+	if len(doc.Content) == 0 { // empty file
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, ee.New("config must be an object")
+	}
+
+	root, err := updateYAMLNode(doc.Content[0], before, after)
+	if err != nil {
+		return nil, err
+	}
+	doc.Content[0] = root
+
+	buf := bytes.Buffer{}
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// updateYAMLNode returns the node representing after,
+// unchanged values (compared with before) and the comments are kept as is
+func updateYAMLNode(node *yaml.Node, before, after any) (*yaml.Node, error) {
+	if reflect.DeepEqual(before, after) {
+		return node, nil
+	}
+
+	beforeMap, _ := before.(map[string]any)
+	afterMap, isMap := after.(map[string]any)
+	if !isMap || node.Kind != yaml.MappingNode {
+		newNode := &yaml.Node{}
+		if err := newNode.Encode(after); err != nil {
+			return nil, err
+		}
+		newNode.HeadComment = node.HeadComment
+		newNode.LineComment = node.LineComment
+		newNode.FootComment = node.FootComment
+		return newNode, nil
+	}
+
+	// update or remove existing keys, keep the order
+	content := make([]*yaml.Node, 0, len(node.Content))
+	seen := make(map[string]bool, len(afterMap))
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		seen[key.Value] = true
+
+		v, ok := afterMap[key.Value]
+		if !ok {
+			continue
+		}
+
+		value, err := updateYAMLNode(value, beforeMap[key.Value], v)
+		if err != nil {
+			return nil, err
+		}
+
+		content = append(content, key, value)
+	}
+
+	// add new keys
+	for _, k := range sortedKeys(afterMap) {
+		if seen[k] {
+			continue
+		}
+
+		key := &yaml.Node{}
+		if err := key.Encode(k); err != nil {
+			return nil, err
+		}
+		value := &yaml.Node{}
+		if err := value.Encode(afterMap[k]); err != nil {
+			return nil, err
+		}
+
+		content = append(content, key, value)
+	}
+
+	newNode := *node
+	newNode.Content = content
+	return &newNode, nil
+}
+
+func marshalTOML(filename string, original []byte, after map[string]any) ([]byte, error) {
+	// the toml encoder cannot keep comments, refuse to drop them silently
+	if tomlHasComments(string(original)) {
+		return nil, ee.Errorf("cannot update %s automatically because it may contain comments which would be lost, please edit it manually", filepath.Base(filename))
+	}
+
+	buf := bytes.Buffer{}
+	encoder := toml.NewEncoder(&buf)
+	encoder.Indent = "  "
+	if err := encoder.Encode(after); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// tomlHasComments reports whether the toml document contains comments, the # inside strings (e.g. in urls) is not a comment
+func tomlHasComments(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '#':
+			return true
+		case '"', '\'':
+			delim := s[i : i+1]
+			if strings.HasPrefix(s[i:], strings.Repeat(delim, 3)) { // multi-line string
+				delim = strings.Repeat(delim, 3)
+			}
+
+			i += len(delim)
+			for i < len(s) && !strings.HasPrefix(s[i:], delim) {
+				if c == '"' && s[i] == '\\' { // escaped char in basic strings
+					i++
+				}
+				i++
+			}
+			// a multi-line string may end with up to 2 more quotes, e.g. """a quote""""
+			for n := 0; len(delim) == 3 && n < 2 && i+3 < len(s) && s[i+3] == c; n++ {
+				i++
+			}
+			i += len(delim) - 1
+		}
+	}
+
+	return false
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
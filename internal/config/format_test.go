@@ -0,0 +1,155 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ysmood/gson"
+)
+
+func TestReadKittyConfigFormats(t *testing.T) {
+	expected := map[string]any{
+		"kitty": "0.2.2",
+		"lint-staged": map[string]any{
+			"*.go": []any{"gofmt -l"},
+		},
+		"tools": map[string]any{
+			"gci": "0.11.1",
+		},
+	}
+
+	testCases := map[string]string{
+		".kittyrc.json": `{"kitty": "0.2.2", "lint-staged": {"*.go": ["gofmt -l"]}, "tools": {"gci": "0.11.1"}}`,
+		".kittyrc.yaml": `
+kitty: 0.2.2
+lint-staged:
+  # explain why
+  "*.go":
+    - gofmt -l
+tools:
+  gci: 0.11.1
+`,
+		".kittyrc.yml": `{kitty: 0.2.2, lint-staged: {"*.go": [gofmt -l]}, tools: {gci: 0.11.1}}`,
+		"kitty.config.toml": `
+kitty = "0.2.2"
+
+# explain why
+[lint-staged]
+"*.go" = ["gofmt -l"]
+
+[tools]
+gci = "0.11.1"
+`,
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{name: content})
+
+			c, err := GetKittyConfig(dir)
+			require.NoError(t, err)
+			assert.Equal(t, expected, rawConfig(c))
+		})
+	}
+}
+
+func TestPatchKittyConfigFormats(t *testing.T) {
+	addTool := func(c map[string]gson.JSON) (bool, error) {
+		tools := c["tools"].Map()
+		tools["gofumpt"] = gson.New("0.5.0")
+		c["tools"] = gson.New(tools)
+		return true, nil
+	}
+
+	t.Run("json", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			".kittyrc.json": `{"tools": {"gci": "0.11.1"}}`,
+		})
+
+		require.NoError(t, PatchKittyConfig(dir, addTool))
+		assertFileContent(t, filepath.Join(dir, ".kittyrc.json"), `{
+  "tools": {
+    "gci": "0.11.1",
+    "gofumpt": "0.5.0"
+  }
+}`)
+	})
+
+	t.Run("yaml keeps comments and order", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			".kittyrc.yml": `# kitty config
+tools:
+  # formatter
+  gci: 0.11.1 # pinned
+lint-staged:
+  # gci breaks generated files
+  "*.go": gci write --skip-generated
+`,
+		})
+
+		require.NoError(t, PatchKittyConfig(dir, addTool))
+		assertFileContent(t, filepath.Join(dir, ".kittyrc.yml"), `# kitty config
+tools:
+  # formatter
+  gci: 0.11.1 # pinned
+  gofumpt: 0.5.0
+lint-staged:
+  # gci breaks generated files
+  "*.go": gci write --skip-generated
+`)
+	})
+
+	t.Run("toml without comments", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"kitty.config.toml": "[tools]\ngci = \"0.11.1\"\n",
+		})
+
+		require.NoError(t, PatchKittyConfig(dir, addTool))
+
+		c, err := GetKittyConfig(dir)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]any{"gci": "0.11.1", "gofumpt": "0.5.0"}, c["tools"].Val())
+	})
+
+	t.Run("toml with # in strings", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			"kitty.config.toml": "extends = \"git+https://example.com/presets.git#v2:go.json\"\n" +
+				"[tools]\ngci = '0.11.1#x'\n\"a#\\\"b\" = \"\"\"\nmulti # line \"\"\"\"\n",
+		})
+
+		require.NoError(t, PatchKittyConfig(dir, addTool))
+
+		c, err := ReadKittyConfig(filepath.Join(dir, "kitty.config.toml"))
+		require.NoError(t, err)
+		assert.Equal(t, "git+https://example.com/presets.git#v2:go.json", c[ExtendsKey].Str())
+		assert.Equal(t, map[string]any{"gci": "0.11.1#x", "a#\"b": "multi # line \"", "gofumpt": "0.5.0"}, c["tools"].Val())
+	})
+
+	t.Run("toml with comments is refused", func(t *testing.T) {
+		for _, content := range []string{
+			"# keep me\n[tools]\ngci = \"0.11.1\"\n",
+			"[tools]\ngci = \"0.11.1\" # pinned\n",
+			"[tools]\ngci = '''0.11.1''' # pinned\n",
+		} {
+			dir := writeFiles(t, map[string]string{
+				"kitty.config.toml": content,
+			})
+
+			err := PatchKittyConfig(dir, addTool)
+			require.Error(t, err, content)
+			assert.Contains(t, err.Error(), "please edit it manually")
+			assertFileContent(t, filepath.Join(dir, "kitty.config.toml"), content)
+		}
+	})
+}
+
+func assertFileContent(t *testing.T, filename string, expected string) {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	assert.Equal(t, expected, string(data))
+}
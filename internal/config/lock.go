@@ -0,0 +1,104 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/ImSingee/go-ex/ee"
+
+	"github.com/ImSingee/kitty/internal/lib/jsonfmt"
+)
+
+// LockFileName is the name of the file (placed next to the kitty config file)
+// which pins the remote sources the config extends, so that everyone gets the same config
+const LockFileName = "kitty.lock.json"
+
+type lockFile struct {
+	path string
+
+	entries map[string]*lockEntry // all entries in file
+	used    map[string]*lockEntry // entries used during this resolution
+}
+
+type lockEntry struct {
+	Commit    string `json:"commit,omitempty"`
+	Integrity string `json:"integrity"`
+}
+
+type lockFileContent struct {
+	Extends map[string]*lockEntry `json:"extends"`
+}
+
+func readLockFile(filename string) (*lockFile, error) {
+	lock := &lockFile{
+		path:    filename,
+		entries: make(map[string]*lockEntry),
+		used:    make(map[string]*lockEntry),
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return lock, nil
+		}
+		return nil, ee.Wrapf(err, "cannot read lock file %s", filename)
+	}
+
+	var content lockFileContent
+	if err := json.Unmarshal(data, &content); err != nil {
+		return nil, ee.Wrapf(err, "invalid lock file %s", filename)
+	}
+	for k, v := range content.Extends {
+		if v != nil {
+			lock.entries[k] = v
+		}
+	}
+
+	return lock, nil
+}
+
+// get returns the locked entry of the source, or nil if it's not locked
+func (l *lockFile) get(name string) *lockEntry {
+	return l.entries[name]
+}
+
+// set records the entry is used in this resolution
+func (l *lockFile) set(name string, entry *lockEntry) {
+	l.used[name] = entry
+}
+
+func (l *lockFile) changed() bool {
+	if len(l.used) != len(l.entries) {
+		return true
+	}
+
+	for k, v := range l.used {
+		if e := l.entries[k]; e == nil || *e != *v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// save writes used entries back (and drops unused ones)
+func (l *lockFile) save() error {
+	if !l.changed() {
+		return nil
+	}
+
+	if len(l.used) == 0 {
+		err := os.Remove(l.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	data, err := jsonfmt.Marshal(lockFileContent{Extends: l.used}, "  ")
+	if err != nil {
+		return ee.Wrap(err, "cannot json encode lock file")
+	}
+
+	return os.WriteFile(l.path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ysmood/gson"
+)
+
+// Layer is one source of config values
+type Layer struct {
+	Origin string // where the values come from, e.g. a file path or a remote source
+	Values map[string]any
+}
+
+// Merged is the result of merging multiple layers
+//
+// Layers are merged in order, so the later layers override the former ones:
+//   - objects are merged key by key (recursively)
+//   - any other value (including lists) replaces the previous one as a whole
+//   - a null value removes the key
+type Merged struct {
+	Layers []*Layer
+
+	values  map[string]any
+	origins map[string]string // key is pathKey(path)
+}
+
+func mergeLayers(layers []*Layer) *Merged {
+	m := &Merged{
+		Layers:  layers,
+		values:  make(map[string]any),
+		origins: make(map[string]string),
+	}
+
+	for _, layer := range layers {
+		m.merge(m.values, layer.Values, nil, layer.Origin)
+	}
+
+	return m
+}
+
+func (m *Merged) merge(dst, src map[string]any, prefix []string, origin string) {
+	for k, v := range src {
+		p := append(prefix[:len(prefix):len(prefix)], k)
+
+		if v == nil {
+			delete(dst, k)
+			m.forget(p)
+			continue
+		}
+
+		if sv, ok := v.(map[string]any); ok {
+			if dv, ok := dst[k].(map[string]any); ok {
+				m.merge(dv, sv, p, origin)
+				continue
+			}
+
+			m.forget(p)
+			nv := make(map[string]any, len(sv))
+			dst[k] = nv
+			m.origins[pathKey(p)] = origin
+			m.merge(nv, sv, p, origin)
+			continue
+		}
+
+		m.forget(p)
+		dst[k] = v
+		m.origins[pathKey(p)] = origin
+	}
+}
+
+// forget removes the origins of path and all its children
+func (m *Merged) forget(path []string) {
+	key := pathKey(path)
+	for k := range m.origins {
+		if k == key || strings.HasPrefix(k, key+"\x00") {
+			delete(m.origins, k)
+		}
+	}
+}
+
+// Values returns the merged config
+func (m *Merged) Values() map[string]gson.JSON {
+	return gson.New(m.values).Map()
+}
+
+// Get returns the value at path
+func (m *Merged) Get(path ...string) (any, bool) {
+	var v any = m.values
+	for _, p := range path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[p]; !ok {
+			return nil, false
+		}
+	}
+
+	return v, true
+}
+
+// Origin returns the origin of the value at path
+//
+// return empty string if the value does not exist
+func (m *Merged) Origin(path ...string) string {
+	if _, ok := m.Get(path...); !ok {
+		return ""
+	}
+
+	for i := len(path); i > 0; i-- {
+		if origin, ok := m.origins[pathKey(path[:i])]; ok {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+// Entry is a leaf value of the merged config
+type Entry struct {
+	Path   []string
+	Value  any
+	Origin string
+}
+
+// Entries returns all leaf values (non-object values and empty objects) sorted by path
+func (m *Merged) Entries() []*Entry {
+	var entries []*Entry
+
+	var walk func(prefix []string, values map[string]any)
+	walk = func(prefix []string, values map[string]any) {
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p := append(prefix[:len(prefix):len(prefix)], k)
+
+			if sub, ok := values[k].(map[string]any); ok && len(sub) != 0 {
+				walk(p, sub)
+				continue
+			}
+
+			entries = append(entries, &Entry{
+				Path:   p,
+				Value:  values[k],
+				Origin: m.Origin(p...),
+			})
+		}
+	}
+	walk(nil, m.values)
+
+	return entries
+}
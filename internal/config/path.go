@@ -0,0 +1,95 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var identifierRegexp = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$-]*$`)
+
+// FormatPath formats the path of a config value for humans
+//
+// each element is either a string (object key) or an int (list index),
+// e.g. ["lint-staged", "*.go", 0] is formatted as `lint-staged["*.go"][0]`
+func FormatPath(path []any) string {
+	b := strings.Builder{}
+
+	for _, p := range path {
+		switch p := p.(type) {
+		case int:
+			b.WriteString("[" + strconv.Itoa(p) + "]")
+		case string:
+			if identifierRegexp.MatchString(p) {
+				if b.Len() != 0 {
+					b.WriteString(".")
+				}
+				b.WriteString(p)
+			} else {
+				b.WriteString("[" + strconv.Quote(p) + "]")
+			}
+		}
+	}
+
+	if b.Len() == 0 {
+		return "."
+	}
+
+	return b.String()
+}
+
+// ParsePath parses the path formatted by FormatPath (list indexes are not supported)
+//
+// keys are separated by dots, and keys containing special characters must be quoted in brackets,
+// e.g. `tools.gci` or `lint-staged["*.go"]`
+func ParsePath(s string) ([]string, error) {
+	var path []string
+
+	rest := s
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "["):
+			key, err := strconv.QuotedPrefix(rest[1:])
+			if err != nil || !strings.HasPrefix(rest[1+len(key):], "]") {
+				return nil, fmt.Errorf("invalid key %s: brackets must contain a quoted string", s)
+			}
+			rest = rest[1+len(key)+1:]
+
+			key, _ = strconv.Unquote(key)
+			path = append(path, key)
+		case strings.HasPrefix(rest, ".") && len(path) != 0:
+			rest = rest[1:]
+			fallthrough
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid key %s: empty key", s)
+			}
+
+			path = append(path, rest[:end])
+			rest = rest[end:]
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, fmt.Errorf("empty key")
+	}
+
+	return path, nil
+}
+
+func pathKey(path []string) string {
+	return strings.Join(path, "\x00")
+}
+
+func anyPath(path []string) []any {
+	result := make([]any, len(path))
+	for i, p := range path {
+		result[i] = p
+	}
+	return result
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+
+	erutils "github.com/ImSingee/kitty/internal/extension-registry/utils"
+	"github.com/ImSingee/kitty/internal/lib/git"
+)
+
+// CacheDirEnv can be used to override the kitty cache directory
+const CacheDirEnv = "KITTY_CACHE_DIR"
+
+// CacheDir returns the directory to cache kitty data (e.g. remote config sources)
+func CacheDir() (string, error) {
+	if d := os.Getenv(CacheDirEnv); d != "" {
+		return d, nil
+	}
+
+	d, err := os.UserCacheDir()
+	if err != nil {
+		return "", ee.Wrap(err, "cannot get user cache directory")
+	}
+
+	return filepath.Join(d, "kitty"), nil
+}
+
+func integrityOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// cacheKey returns the file name in the cache for the url
+func cacheKey(u string) string {
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:8])
+}
+
+func verifyIntegrity(s source, locked *lockEntry, data []byte) error {
+	if locked == nil || locked.Integrity == integrityOf(data) {
+		return nil
+	}
+
+	return ee.Errorf("content of %s does not match %s (run `kitty config update` if the change is expected)", s, LockFileName)
+}
+
+func (r *extendsResolver) locked(key string) *lockEntry {
+	if r.refresh {
+		return nil
+	}
+
+	return r.lock.get(key)
+}
+
+func (r *extendsResolver) readURL(s *urlSource) (string, []byte, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return "", nil, err
+	}
+	blobsDir := filepath.Join(cacheDir, "extends", "blobs")
+
+	name := s.url
+	if u, _, ok := strings.Cut(name, "?"); ok {
+		name = u
+	}
+
+	// the last downloaded content is used if the url is not locked
+	indexFile := filepath.Join(cacheDir, "extends", "urls", cacheKey(s.url))
+
+	key := s.String()
+	locked := r.locked(key)
+	integrity := ""
+	if locked != nil {
+		integrity = locked.Integrity
+	} else if !r.refresh {
+		if data, err := os.ReadFile(indexFile); err == nil {
+			integrity = strings.TrimSpace(string(data))
+		}
+	}
+	if integrity != "" {
+		data, err := os.ReadFile(filepath.Join(blobsDir, strings.TrimPrefix(integrity, "sha256:")))
+		if err == nil && integrityOf(data) == integrity {
+			r.lock.set(key, &lockEntry{Integrity: integrity})
+			return name, data, nil
+		}
+	}
+
+	data, err := download(s.url)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := verifyIntegrity(s, locked, data); err != nil {
+		return "", nil, err
+	}
+
+	integrity = integrityOf(data)
+	if err := writeFileAtomic(filepath.Join(blobsDir, strings.TrimPrefix(integrity, "sha256:")), data); err != nil {
+		return "", nil, ee.Wrap(err, "cannot write cache")
+	}
+	if err := writeFileAtomic(indexFile, []byte(integrity+"\n")); err != nil {
+		return "", nil, ee.Wrap(err, "cannot write cache")
+	}
+
+	r.lock.set(key, &lockEntry{Integrity: integrity})
+	return name, data, nil
+}
+
+func download(u string) ([]byte, error) {
+	u, err := erutils.ApplyGitHubProxy(u)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug("Download config source", "url", u)
+
+	resp, err := http.Get(u)
+	if err != nil {
+		return nil, ee.Wrapf(err, "cannot download %s", u)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, ee.Errorf("cannot download %s: status code = %d", u, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, ee.Wrapf(err, "cannot download %s", u)
+	}
+
+	return data, nil
+}
+
+func (r *extendsResolver) readGit(s *gitSource) (string, []byte, error) {
+	repo, err := r.gitCacheRepo(s.url)
+	if err != nil {
+		return "", nil, err
+	}
+
+	key := s.String()
+	locked := r.locked(key)
+
+	commit := s.commit
+	if commit == "" && locked != nil {
+		commit = locked.Commit
+	}
+
+	if commit != "" {
+		if !gitHasCommit(repo, commit) {
+			if err := gitFetch(repo, s.url, false); err != nil {
+				return "", nil, err
+			}
+			if !gitHasCommit(repo, commit) {
+				return "", nil, ee.Errorf("cannot find commit %s in %s", commit, s.url)
+			}
+		}
+	} else {
+		if !r.refresh { // the ref fetched before is used until `kitty config update`
+			commit, _ = gitResolveRef(repo, s.ref)
+		}
+		if commit == "" {
+			if err := gitFetch(repo, s.url, s.ref == ""); err != nil {
+				return "", nil, err
+			}
+			commit, err = gitResolveRef(repo, s.ref)
+			if err != nil {
+				return "", nil, ee.Wrapf(err, "cannot resolve %s", s)
+			}
+		}
+	}
+	// the sources declared inside this one should use the same commit
+	s.commit = commit
+
+	if s.path == "" {
+		for _, name := range ConfigFileNames {
+			if git.R(repo, []string{"cat-file", "-e", commit + ":" + name}).Err() == nil {
+				s.path = name
+				break
+			}
+		}
+		if s.path == "" {
+			return "", nil, ee.Errorf("cannot find kitty config file in %s", s)
+		}
+	}
+
+	result := git.R(repo, []string{"show", commit + ":" + s.path})
+	if err := result.Err(); err != nil {
+		return "", nil, ee.Wrapf(err, "cannot read %s at commit %s", s.path, commit)
+	}
+	data := result.Output
+
+	if err := verifyIntegrity(s, locked, data); err != nil {
+		return "", nil, err
+	}
+
+	r.lock.set(key, &lockEntry{Commit: commit, Integrity: integrityOf(data)})
+	return s.path, data, nil
+}
+
+// gitCacheRepo returns the bare repository caching the given url
+func (r *extendsResolver) gitCacheRepo(u string) (string, error) {
+	cacheDir, err := CacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	repo := filepath.Join(cacheDir, "extends", "git", cacheKey(u))
+
+	if _, err := os.Stat(filepath.Join(repo, "HEAD")); err == nil {
+		return repo, nil
+	}
+
+	if err := os.MkdirAll(repo, 0755); err != nil {
+		return "", ee.Wrap(err, "cannot create cache directory")
+	}
+	if err := git.R(repo, []string{"init", "--bare", "--quiet"}).Err(); err != nil {
+		return "", ee.Wrap(err, "cannot init cache repository")
+	}
+
+	return repo, nil
+}
+
+// gitFetch fetches all branches and tags (and HEAD if withHead) of the url to the cache repository
+func gitFetch(repo string, u string, withHead bool) error {
+	slog.Debug("Fetch config source", "url", u)
+
+	refspecs := []string{"+refs/heads/*:refs/kitty/heads/*"}
+	if withHead {
+		refspecs = append(refspecs, "+HEAD:refs/kitty/HEAD")
+	}
+
+	result := git.R(repo, append([]string{"fetch", "--quiet", "--force", "--prune", "--tags", u}, refspecs...))
+	if err := result.Err(); err != nil {
+		return ee.Wrapf(err, "cannot fetch %s", u)
+	}
+
+	return nil
+}
+
+func gitHasCommit(repo string, commit string) bool {
+	return git.R(repo, []string{"cat-file", "-e", commit + "^{commit}"}).Err() == nil
+}
+
+func gitResolveRef(repo string, ref string) (string, error) {
+	candidates := []string{"refs/kitty/HEAD"}
+	if ref != "" {
+		candidates = []string{"refs/tags/" + ref, "refs/kitty/heads/" + ref, ref}
+	}
+
+	for _, candidate := range candidates {
+		result := git.R(repo, []string{"rev-parse", "--verify", "--quiet", candidate + "^{commit}"})
+		if result.Err() == nil {
+			return strings.TrimSpace(string(result.Output)), nil
+		}
+	}
+
+	return "", ee.Errorf("unknown ref %s", ref)
+}
+
+func writeFileAtomic(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
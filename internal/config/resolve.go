@@ -0,0 +1,269 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ysmood/gson"
+
+	"github.com/ImSingee/kitty/internal/config/kittyversion"
+	"github.com/ImSingee/kitty/internal/lib/git"
+)
+
+const (
+	RegistryEnv     = "KITTY_REGISTRY"
+	DefaultRegistry = "https://raw.githubusercontent.com/ImSingee/kitty-registry/master/"
+)
+
+// envBindings maps config keys to the environment variables overriding them
+var envBindings = []struct {
+	Key string
+	Env string
+}{
+	{Key: "registry", Env: RegistryEnv},
+}
+
+// defaults are the config values used when no layer provides them
+var defaults = map[string]any{
+	"registry": DefaultRegistry,
+}
+
+const (
+	OriginDefault   = "default"
+	OriginEnvPrefix = "env:"
+)
+
+// UserConfigDirEnv can be used to override the directory of the user-level config file
+const UserConfigDirEnv = "KITTY_CONFIG_DIR"
+
+// UserConfigFileNames are the names of the user-level config file (inside UserConfigDir)
+var UserConfigFileNames = []string{
+	"config.json",
+	"config.yaml",
+	"config.yml",
+	"config.toml",
+}
+
+// UserConfigDir returns the directory of the user-level config file
+func UserConfigDir() (string, error) {
+	if d := os.Getenv(UserConfigDirEnv); d != "" {
+		return d, nil
+	}
+
+	d, err := os.UserConfigDir()
+	if err != nil {
+		return "", ee.Wrap(err, "cannot get user config directory")
+	}
+
+	return filepath.Join(d, "kitty"), nil
+}
+
+// LoadUserConfig loads the user-level config file (with everything it extends)
+//
+// if no config file found, it will return an error wrapped ErrNotExist, use IsNotExist to check
+func LoadUserConfig() (*Merged, error) {
+	dir, err := UserConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	filename, c, err := findKittyConfig(dir, UserConfigFileNames)
+	if err != nil {
+		return nil, err
+	}
+	if filename == "" {
+		return nil, ee.Wrap(ErrNotExist, "cannot find user config file")
+	}
+
+	m, err := loadKittyConfig(filename, c, false)
+	if err != nil {
+		return nil, ee.Wrapf(err, "cannot load user config file %s", filename)
+	}
+
+	return m, nil
+}
+
+// PatchUserConfig is like PatchKittyConfig, but patches the user-level config file
+func PatchUserConfig(patch func(map[string]gson.JSON) (save bool, err error)) error {
+	dir, err := UserConfigDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return ee.Wrapf(err, "cannot create directory %s", dir)
+	}
+
+	return patchKittyConfig(dir, UserConfigFileNames, patch)
+}
+
+// RepoRoot returns the directory of the repo-level config for dir,
+// that is the root of the git repository containing dir, or dir itself if it's not inside a git repository
+func RepoRoot(dir string) (string, error) {
+	if dir == "" {
+		var err error
+		dir, err = os.Getwd()
+		if err != nil {
+			return "", ee.Wrap(err, "cannot get working directory")
+		}
+	}
+
+	if root, err := git.GetRoot(dir); err == nil && root != "" {
+		return root, nil
+	}
+
+	return dir, nil
+}
+
+// Resolve resolves the config for dir by layers (the later ones take precedence):
+//   - defaults
+//   - the user-level config (see UserConfigDir), except `tools` since tools are installed per repository
+//   - the repo-level config (see RepoRoot)
+//   - the config files in every directory from the repo root down to dir,
+//     so a config file in a subdirectory overrides the ones in its parents
+//   - environment variables (e.g. KITTY_REGISTRY)
+//
+// every config file is expanded to the layers it extends (see ExtendsKey),
+// and the layers are merged as described in Merged: objects (including `tools`) are merged key by key,
+// lists and other values are replaced as a whole, and null removes the key
+//
+// if dir is empty, it will use the current working directory
+func Resolve(dir string) (*Merged, error) {
+	dir, err := absDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := []*Layer{{Origin: OriginDefault, Values: defaults}}
+
+	user, err := LoadUserConfig()
+	if err != nil && !IsNotExist(err) {
+		return nil, err
+	}
+	if user != nil {
+		for _, layer := range user.Layers {
+			layers = append(layers, withoutKey(layer, "tools"))
+		}
+	}
+
+	root, err := RepoRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range dirsBetween(root, dir) {
+		m, err := LoadKittyConfig(d)
+		if err != nil && !IsNotExist(err) {
+			return nil, err
+		}
+		if m != nil {
+			layers = append(layers, m.Layers...)
+		}
+	}
+
+	for _, binding := range envBindings {
+		if v := os.Getenv(binding.Env); v != "" {
+			layers = append(layers, &Layer{
+				Origin: OriginEnvPrefix + binding.Env,
+				Values: map[string]any{binding.Key: v},
+			})
+		}
+	}
+
+	return mergeLayers(layers), nil
+}
+
+// RequiredKittyVersion returns the kitty version required by the repo-level config for dir
+// (the config files from the repo root down to dir, see Resolve), empty if not required
+//
+// it's read before running every command, so the user-level config is ignored (it must not choose the kitty of a repository),
+// the remote sources extended are skipped, and the broken config files are skipped as well
+// (they are reported by the commands using the config)
+func RequiredKittyVersion(dir string) (string, error) {
+	dir, err := absDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	root, err := RepoRoot(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var layers []*Layer
+	for _, d := range dirsBetween(root, dir) {
+		filename, c, err := findKittyConfig(d, ConfigFileNames)
+		if err != nil {
+			slog.Debug("Skip broken kitty config", "dir", d, "error", err)
+			continue
+		}
+		if filename == "" {
+			continue
+		}
+
+		m, err := loadLocalKittyConfig(filename, c)
+		if err != nil {
+			slog.Debug("Skip broken kitty config", "file", filename, "error", err)
+			continue
+		}
+		layers = append(layers, m.Layers...)
+	}
+
+	return kittyversion.ParseRequired(mergeLayers(layers).Values()), nil
+}
+
+// absDir returns the absolute path of dir without symlinks (to match the git root), or the working directory if dir is empty
+func absDir(dir string) (string, error) {
+	if dir == "" {
+		var err error
+		dir, err = os.Getwd()
+		if err != nil {
+			return "", ee.Wrap(err, "cannot get working directory")
+		}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ee.Wrapf(err, "cannot get absolute path of %s", dir)
+	}
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		dir = real
+	}
+
+	return dir, nil
+}
+
+// dirsBetween returns root and every directory under it down to dir (in order),
+// or only root if dir is not inside root
+func dirsBetween(root, dir string) []string {
+	dirs := []string{root}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return dirs
+	}
+
+	d := root
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		d = filepath.Join(d, part)
+		dirs = append(dirs, d)
+	}
+
+	return dirs
+}
+
+func withoutKey(layer *Layer, key string) *Layer {
+	if _, ok := layer.Values[key]; !ok {
+		return layer
+	}
+
+	values := make(map[string]any, len(layer.Values))
+	for k, v := range layer.Values {
+		if k != key {
+			values[k] = v
+		}
+	}
+
+	return &Layer{Origin: layer.Origin, Values: values}
+}
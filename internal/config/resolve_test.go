@@ -0,0 +1,224 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ysmood/gson"
+)
+
+func TestResolve(t *testing.T) {
+	userDir := writeFiles(t, map[string]string{
+		"config.yaml": "registry: https://user.example.com/\ntools:\n  gci: 0.11.0\n  gofumpt: 0.5.0\n",
+	})
+	t.Setenv(UserConfigDirEnv, userDir)
+	t.Setenv(RegistryEnv, "")
+
+	repo := writeFiles(t, map[string]string{
+		".kittyrc.json": `{"tools": {"gci": "0.11.1"}}`,
+	})
+	runGit(t, repo, "init")
+
+	m, err := Resolve(repo)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		OriginDefault,
+		filepath.Join(userDir, "config.yaml"),
+		filepath.Join(repo, ".kittyrc.json"),
+	}, layerOrigins(m))
+
+	gci, _ := m.Get("tools", "gci")
+	assert.Equal(t, "0.11.1", gci)
+	assert.Equal(t, filepath.Join(repo, ".kittyrc.json"), m.Origin("tools", "gci"))
+
+	_, ok := m.Get("tools", "gofumpt")
+	assert.False(t, ok, "tools in user-level config should be ignored")
+
+	registry, _ := m.Get("registry")
+	assert.Equal(t, "https://user.example.com/", registry)
+
+	t.Run("env overrides everything", func(t *testing.T) {
+		t.Setenv(RegistryEnv, "https://env.example.com/")
+
+		m, err := Resolve(repo)
+		require.NoError(t, err)
+
+		registry, _ := m.Get("registry")
+		assert.Equal(t, "https://env.example.com/", registry)
+		assert.Equal(t, OriginEnvPrefix+RegistryEnv, m.Origin("registry"))
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		t.Setenv(UserConfigDirEnv, t.TempDir())
+
+		m, err := Resolve(t.TempDir())
+		require.NoError(t, err)
+
+		registry, _ := m.Get("registry")
+		assert.Equal(t, DefaultRegistry, registry)
+		assert.Equal(t, OriginDefault, m.Origin("registry"))
+	})
+}
+
+func TestResolveSubdirectories(t *testing.T) {
+	t.Setenv(UserConfigDirEnv, t.TempDir())
+	t.Setenv(RegistryEnv, "")
+
+	repo := writeFiles(t, map[string]string{
+		".kittyrc.json":           `{"kitty": ">=0.2.0", "tools": {"gci": "0.11.1", "gofumpt": "0.5.0"}, "extends": ["./shared.json"]}`,
+		"shared.json":             `{"registry": "https://shared.example.com/"}`,
+		"a/.kittyrc.yaml":         "kitty: '>=0.3.0'\ntools:\n  gci: 0.12.0\n  gofumpt: null\n",
+		"a/b/c/kitty.config.toml": "registry = \"https://c.example.com/\"\n",
+		"other/.kittyrc.json":     `{"kitty": ">=1.0.0"}`,
+		"a/b/c/d/.gitkeep":        "",
+	})
+	runGit(t, repo, "init")
+
+	m, err := Resolve(filepath.Join(repo, "a", "b", "c", "d"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		OriginDefault,
+		filepath.Join(repo, "shared.json"),
+		filepath.Join(repo, ".kittyrc.json"),
+		filepath.Join(repo, "a", ".kittyrc.yaml"),
+		filepath.Join(repo, "a", "b", "c", "kitty.config.toml"),
+	}, layerOrigins(m))
+
+	assert.Equal(t, map[string]any{
+		"kitty":    ">=0.3.0",
+		"registry": "https://c.example.com/",
+		"tools":    map[string]any{"gci": "0.12.0"},
+	}, rawConfig(m.Values()))
+	assert.Equal(t, filepath.Join(repo, "a", ".kittyrc.yaml"), m.Origin("tools", "gci"))
+
+	m, err = Resolve(repo)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"kitty":    ">=0.2.0",
+		"registry": "https://shared.example.com/",
+		"tools":    map[string]any{"gci": "0.11.1", "gofumpt": "0.5.0"},
+	}, rawConfig(m.Values()))
+}
+
+func TestRequiredKittyVersion(t *testing.T) {
+	t.Setenv(UserConfigDirEnv, writeFiles(t, map[string]string{
+		"config.json": `{"kitty": ">=9.0.0"}`,
+	}))
+	t.Setenv(CacheDirEnv, t.TempDir())
+
+	repo := writeFiles(t, map[string]string{
+		".kittyrc.json":       `{"extends": ["./shared.json", "https://unreachable.invalid/kitty.json"]}`,
+		"shared.json":         `{"kitty": ">=0.2.0"}`,
+		"a/.kittyrc.yaml":     "kitty: '>=0.3.0'\n",
+		"b/.kittyrc.json":     `{"kitty": ">=1.0.0"`,
+		"c/.kittyrc.json":     `{"kitty": ">=1.0.0"}`,
+		"c/kitty.config.json": `{}`,
+	})
+	runGit(t, repo, "init")
+
+	_, err := Resolve(repo)
+	require.Error(t, err)
+
+	v, err := RequiredKittyVersion(repo)
+	require.NoError(t, err, "the remote sources are skipped")
+	assert.Equal(t, ">=0.2.0", v)
+
+	v, err = RequiredKittyVersion(filepath.Join(repo, "a"))
+	require.NoError(t, err)
+	assert.Equal(t, ">=0.3.0", v)
+
+	for _, broken := range []string{"b", "c"} {
+		v, err = RequiredKittyVersion(filepath.Join(repo, broken))
+		require.NoError(t, err)
+		assert.Equal(t, ">=0.2.0", v, "the broken config in %s is skipped", broken)
+	}
+
+	v, err = RequiredKittyVersion(t.TempDir())
+	require.NoError(t, err)
+	assert.Equal(t, "", v, "the user-level config is ignored")
+}
+
+func TestPatchUserConfig(t *testing.T) {
+	userDir := filepath.Join(t.TempDir(), "kitty")
+	t.Setenv(UserConfigDirEnv, userDir)
+
+	require.NoError(t, PatchUserConfig(func(c map[string]gson.JSON) (bool, error) {
+		return true, setValue(c, []string{"lint-staged", "*.go"}, "gofmt -l")
+	}))
+	assertFileContent(t, filepath.Join(userDir, "config.json"), `{
+  "lint-staged": {
+    "*.go": "gofmt -l"
+  }
+}`)
+
+	m, err := LoadUserConfig()
+	require.NoError(t, err)
+	v, ok := m.Get("lint-staged", "*.go")
+	assert.True(t, ok)
+	assert.Equal(t, "gofmt -l", v)
+}
+
+func TestSetAndUnsetValue(t *testing.T) {
+	c := gson.New(map[string]any{
+		"kitty": "0.2.2",
+		"tools": map[string]any{"gci": "0.11.1"},
+	}).Map()
+
+	require.NoError(t, setValue(c, []string{"tools", "gofumpt"}, "0.5.0"))
+	require.NoError(t, setValue(c, []string{"lint-staged", "*.go"}, []any{"gofmt -l"}))
+	assert.Equal(t, map[string]any{
+		"kitty": "0.2.2",
+		"tools": map[string]any{"gci": "0.11.1", "gofumpt": "0.5.0"},
+		"lint-staged": map[string]any{
+			"*.go": []any{"gofmt -l"},
+		},
+	}, rawConfig(c))
+
+	err := setValue(c, []string{"kitty", "version"}, "0.2.3")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "kitty is not an object")
+
+	assert.True(t, unsetValue(c, []string{"tools", "gci"}))
+	assert.True(t, unsetValue(c, []string{"kitty"}))
+	assert.False(t, unsetValue(c, []string{"kitty"}))
+	assert.False(t, unsetValue(c, []string{"tools", "gci", "x"}))
+	assert.Equal(t, map[string]any{
+		"tools": map[string]any{"gofumpt": "0.5.0"},
+		"lint-staged": map[string]any{
+			"*.go": []any{"gofmt -l"},
+		},
+	}, rawConfig(c))
+}
+
+func TestParsePath(t *testing.T) {
+	testCases := map[string][]string{
+		"registry":                 {"registry"},
+		"tools.gci":                {"tools", "gci"},
+		`lint-staged["*.go"]`:      {"lint-staged", "*.go"},
+		`lint-staged["*.{js,ts}"]`: {"lint-staged", "*.{js,ts}"},
+		`["lint-staged"]["a.b"].c`: {"lint-staged", "a.b", "c"},
+	}
+
+	for s, expected := range testCases {
+		t.Run(s, func(t *testing.T) {
+			path, err := ParsePath(s)
+			require.NoError(t, err)
+			assert.Equal(t, expected, path)
+
+			roundtrip, err := ParsePath(FormatPath(anyPath(path)))
+			require.NoError(t, err)
+			assert.Equal(t, expected, roundtrip)
+		})
+	}
+
+	for _, s := range []string{"", "a..b", "a.", `a["b`, "a[b]"} {
+		t.Run("invalid "+s, func(t *testing.T) {
+			_, err := ParsePath(s)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// Schema is the JSON Schema of the kitty config file
+//
+//go:embed kitty.schema.json
+var Schema []byte
+
+const schemaURL = "https://raw.githubusercontent.com/ImSingee/kitty/master/internal/config/kitty.schema.json"
+
+// Problem is a problem found when validating a config
+type Problem struct {
+	Path    []any // see FormatPath
+	Message string
+}
+
+func (p *Problem) String() string {
+	return FormatPath(p.Path) + ": " + p.Message
+}
+
+// messages replaces the messages of the json schema library for some keywords (by their schema locations),
+// which are more meaningful to users than a regexp
+var messages = map[string]string{
+	"#/$defs/commandString/pattern":                    "invalid command options, only [dir], [absolute], [noArgs], [changedLinesOnly], [argfile] and [prepend <prefix>] are supported",
+	"#/$defs/commandString/not":                        "cannot have both [absolute] and [noArgs] options",
+	"#/$defs/commandObject/not":                        "cannot have both absolute and noArgs options",
+	"#/$defs/commandObject/properties/timeout/pattern": "invalid duration (e.g. 30s, 1m)",
+	"#/$defs/commandObject/properties/cwd/pattern":     "must be a relative path",
+	"#/$defs/lintStaged/minProperties":                 "empty config",
+	"#/$defs/filter/properties/maxSize/pattern":        "invalid size (e.g. 100KB, 1MB)",
+}
+
+// quotedRegexp extracts the keys from the messages like "additionalProperties 'a', 'b' not allowed"
+var quotedRegexp = regexp.MustCompile(`'([^']*)'`)
+
+var compileSchemas = sync.OnceValues(func() (map[string]*jsonschema.Schema, error) {
+	c := jsonschema.NewCompiler()
+	c.Draft = jsonschema.Draft2020
+	if err := c.AddResource(schemaURL, bytes.NewReader(Schema)); err != nil {
+		return nil, err
+	}
+
+	schemas := make(map[string]*jsonschema.Schema, 2)
+	for name, ref := range map[string]string{
+		"kitty":       schemaURL,
+		"lint-staged": schemaURL + "#/$defs/lintStaged",
+	} {
+		s, err := c.Compile(ref)
+		if err != nil {
+			return nil, err
+		}
+		schemas[name] = s
+	}
+
+	return schemas, nil
+})
+
+// ValidateKittyConfig validates the content of a kitty config file and returns every problem found
+func ValidateKittyConfig(c map[string]any) ([]*Problem, error) {
+	return validate("kitty", c)
+}
+
+// ValidateLintStagedConfig validates the content of a standalone lint-staged config file (e.g. .lintstagedrc.json)
+func ValidateLintStagedConfig(c map[string]any) ([]*Problem, error) {
+	return validate("lint-staged", c)
+}
+
+func validate(name string, c map[string]any) ([]*Problem, error) {
+	schemas, err := compileSchemas()
+	if err != nil {
+		return nil, ee.Wrap(err, "cannot compile config schema")
+	}
+
+	err = schemas[name].Validate(c)
+	if err == nil {
+		return nil, nil
+	}
+
+	var verr *jsonschema.ValidationError
+	if !ee.As(err, &verr) {
+		return nil, err
+	}
+
+	var problems []*Problem
+	seen := make(map[string]bool)
+
+	var collect func(e *jsonschema.ValidationError)
+	collect = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) != 0 {
+			for _, cause := range e.Causes {
+				collect(cause)
+			}
+			return
+		}
+
+		path := instancePath(c, e.InstanceLocation)
+		add := func(p *Problem) {
+			if key := p.String(); !seen[key] {
+				seen[key] = true
+				problems = append(problems, p)
+			}
+		}
+
+		if strings.HasSuffix(e.KeywordLocation, "/additionalProperties") {
+			if keys := quotedRegexp.FindAllStringSubmatch(e.Message, -1); len(keys) != 0 {
+				for _, key := range keys {
+					add(&Problem{
+						Path:    append(path[:len(path):len(path)], key[1]),
+						Message: "unknown key",
+					})
+				}
+				return
+			}
+		}
+
+		message := e.Message
+		if _, fragment, ok := strings.Cut(e.AbsoluteKeywordLocation, "#"); ok {
+			if m, ok := messages["#"+fragment]; ok {
+				message = m
+			}
+		}
+
+		add(&Problem{Path: path, Message: message})
+	}
+	collect(verr)
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return FormatPath(problems[i].Path) < FormatPath(problems[j].Path)
+	})
+
+	return problems, nil
+}
+
+// instancePath converts the json pointer to the path of FormatPath,
+// list indexes are detected by walking through the value
+func instancePath(v any, pointer string) []any {
+	if pointer == "" {
+		return nil
+	}
+
+	parts := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	path := make([]any, 0, len(parts))
+	for _, part := range parts {
+		if unescaped, err := url.PathUnescape(part); err == nil {
+			part = unescaped
+		}
+		part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+
+		switch vv := v.(type) {
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err == nil && i >= 0 && i < len(vv) {
+				path = append(path, i)
+				v = vv[i]
+				continue
+			}
+		case map[string]any:
+			v = vv[part]
+		default:
+			v = nil
+		}
+
+		path = append(path, part)
+	}
+
+	return path
+}
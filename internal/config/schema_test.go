@@ -0,0 +1,218 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateKittyConfig(t *testing.T) {
+	testCases := []struct {
+		name     string
+		config   map[string]any
+		expected []string
+	}{
+		{
+			name: "valid",
+			config: map[string]any{
+				"$schema":  "https://example.com/kitty.schema.json",
+				"kitty":    ">=0.2.2",
+				"registry": "https://example.com/registry/",
+				"extends":  []any{"./shared.json"},
+				"tools":    map[string]any{"gci": "0.11.1", "shfmt": "-", "gofumpt": nil},
+				"lint-staged": map[string]any{
+					"*.go": []any{"gci write", "[dir][prepend ./] go vet", "[noArgs] go test"},
+					"*.md": "[absolute] prettier --write",
+				},
+			},
+		},
+		{
+			name: "valid files form",
+			config: map[string]any{
+				"kitty":       map[string]any{"version": ">=0.2.2"},
+				"lint-staged": map[string]any{"files": map[string]any{"*.go": "gofmt -l"}},
+			},
+		},
+		{
+			name: "unknown keys",
+			config: map[string]any{
+				"lint-stage": map[string]any{"*.go": "gofmt -l"},
+				"kitty":      map[string]any{"versoin": ">=0.2.2"},
+				"lint-staged": map[string]any{
+					"files":  map[string]any{"*.go": "gofmt -l"},
+					"ignore": []any{"vendor"},
+				},
+			},
+			expected: []string{
+				"kitty.versoin: unknown key",
+				"lint-stage: unknown key",
+				"lint-staged.ignore: unknown key",
+			},
+		},
+		{
+			name: "wrong types",
+			config: map[string]any{
+				"registry": 1.0,
+				"tools":    map[string]any{"gci": 0.5},
+				"lint-staged": map[string]any{
+					"*.go": []any{"gofmt -l", 1.0},
+					"*.md": true,
+				},
+			},
+			expected: []string{
+				`lint-staged["*.go"][1]: expected string or object, but got number`,
+				`lint-staged["*.md"]: expected string or object or array, but got boolean`,
+				"registry: expected string or null, but got number",
+				"tools.gci: expected string or null, but got number",
+			},
+		},
+		{
+			name: "invalid command options",
+			config: map[string]any{
+				"lint-staged": map[string]any{
+					"*.go": []any{"[dir][abs] gofmt -l", "[absolute][noArgs] go test", "[dir] [noArgs] go vet"},
+				},
+			},
+			expected: []string{
+				`lint-staged["*.go"][0]: invalid command options, only [dir], [absolute], [noArgs], [changedLinesOnly], [argfile] and [prepend <prefix>] are supported`,
+				`lint-staged["*.go"][1]: cannot have both [absolute] and [noArgs] options`,
+				`lint-staged["*.go"][2]: invalid command options, only [dir], [absolute], [noArgs], [changedLinesOnly], [argfile] and [prepend <prefix>] are supported`,
+			},
+		},
+		{
+			name: "object commands",
+			config: map[string]any{
+				"lint-staged": map[string]any{
+					"*.js": map[string]any{"run": "eslint --fix", "absolute": true, "timeout": "30s", "env": map[string]any{"DEBUG": "1"}, "cwd": "..", "title": "ESLint"},
+					"*.go": []any{
+						"gofmt -l",
+						map[string]any{"run": "[dir] go vet", "timeout": "soon"},
+						map[string]any{"absolute": true, "noArgs": true, "run": "go test", "env": map[string]any{"CGO_ENABLED": 0.0}},
+						map[string]any{"run": "go build", "cwd": "/tmp", "shell": "bash"},
+					},
+				},
+			},
+			expected: []string{
+				`lint-staged["*.go"][1].timeout: invalid duration (e.g. 30s, 1m)`,
+				`lint-staged["*.go"][2]: cannot have both absolute and noArgs options`,
+				`lint-staged["*.go"][2].env.CGO_ENABLED: expected string, but got number`,
+				`lint-staged["*.go"][3].cwd: must be a relative path`,
+				`lint-staged["*.go"][3].shell: unknown key`,
+			},
+		},
+		{
+			name: "rule filters",
+			config: map[string]any{
+				"lint-staged": map[string]any{
+					"*": map[string]any{
+						"filter":   map[string]any{"shebang": "^#!.*sh", "language": []any{"Shell"}, "maxSize": "1MB", "excludeGenerated": true},
+						"commands": []any{"shellcheck"},
+					},
+					"*.go": map[string]any{
+						"filter":   map[string]any{"mime": 1.0, "maxSize": "big", "generated": false},
+						"commands": "gofmt -l",
+					},
+					"*.md":    map[string]any{"commands": []any{1.0}, "title": "x"},
+					"*.proto": map[string]any{"on": []any{"deleted", "moved"}, "commands": "false"},
+					"*.sql":   map[string]any{"on": "renamed", "commands": "check"},
+					"*.rs":    map[string]any{"workspace": true, "commands": "cargo clippy"},
+					"*.py":    map[string]any{"workspace": []any{"pyproject.toml"}, "commands": "ruff check"},
+				},
+			},
+			expected: []string{
+				`lint-staged["*.go"].filter.generated: unknown key`,
+				`lint-staged["*.go"].filter.maxSize: invalid size (e.g. 100KB, 1MB)`,
+				`lint-staged["*.go"].filter.mime: expected string or array, but got number`,
+				`lint-staged["*.md"].commands[0]: expected string or object, but got number`,
+				`lint-staged["*.md"].title: unknown key`,
+				`lint-staged["*.proto"].on[1]: value must be one of "added", "modified", "renamed", "deleted"`,
+			},
+		},
+		{
+			name: "empty lint-staged",
+			config: map[string]any{
+				"lint-staged": map[string]any{},
+			},
+			expected: []string{
+				"lint-staged: empty config",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems, err := ValidateKittyConfig(tc.config)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, problemStrings(problems))
+		})
+	}
+}
+
+func TestValidateLintStagedConfig(t *testing.T) {
+	problems, err := ValidateLintStagedConfig(map[string]any{"*.go": "gofmt -l", "*.md": []any{"[prepend ./] prettier"}})
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	problems, err = ValidateLintStagedConfig(map[string]any{"files": map[string]any{"*.go": 1.0}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`files["*.go"]: expected string or object or array, but got number`}, problemStrings(problems))
+
+	problems, err = ValidateLintStagedConfig(map[string]any{"inherit": true, "files": map[string]any{"*.go": []any{}}})
+	require.NoError(t, err)
+	assert.Empty(t, problems)
+
+	problems, err = ValidateLintStagedConfig(map[string]any{"inherit": "yes", "files": map[string]any{"*.go": "gofmt -l"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{`inherit: expected boolean, but got string`}, problemStrings(problems))
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".kittyrc.yaml":         "extends: ./shared.json\ntools:\n  gci: 0.11.1\n",
+		"shared.json":           `{"tools": {"gofumpt": 0.5}}`,
+		"sub/.lintstagedrc":     `{"*.go": ["[foo] gofmt -l"]}`,
+		"bad/kitty.config.toml": "tools = [",
+	})
+
+	problems, err := ValidateFile(filepath.Join(dir, ".kittyrc.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"extends: " + filepath.Join(dir, "shared.json") + ": tools.gofumpt: expected string or null, but got number",
+	}, problemStrings(problems))
+
+	problems, err = ValidateFile(filepath.Join(dir, "sub", ".lintstagedrc"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`["*.go"][0]: invalid command options, only [dir], [absolute], [noArgs], [changedLinesOnly], [argfile] and [prepend <prefix>] are supported`,
+	}, problemStrings(problems))
+
+	_, err = ValidateFile(filepath.Join(dir, "bad", "kitty.config.toml"))
+	assert.Error(t, err)
+}
+
+func TestMultipleKittyConfigs(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		".kittyrc.json": `{"tools": {"gci": "0.11.1"}}`,
+		".kittyrc.yaml": "tools:\n  gci: 0.11.1\n",
+	})
+	t.Setenv(UserConfigDirEnv, t.TempDir())
+
+	_, err := GetKittyConfig(dir)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "multiple kitty config files found")
+
+	files, conflicts, err := FindConfigFiles(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, ".kittyrc.json"), filepath.Join(dir, ".kittyrc.yaml")}, files)
+	assert.Len(t, conflicts, 1)
+}
+
+func problemStrings(problems []*Problem) []string {
+	var result []string
+	for _, p := range problems {
+		result = append(result, p.String())
+	}
+	return result
+}
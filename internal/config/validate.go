@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/exstrings"
+
+	"github.com/ImSingee/kitty/internal/lib/git"
+)
+
+// LintStagedConfigFileNames are the names of the standalone lint-staged config files
+var LintStagedConfigFileNames = []string{
+	".lintstagedrc",
+	".lintstagedrc.json",
+	".lintstagedrc.yaml",
+	".lintstagedrc.yml",
+}
+
+// ValidateFile validates a kitty config file (with everything it extends) or a standalone lint-staged config file
+//
+// the returned error is only for the problems that stop the validation (e.g. the file cannot be parsed)
+func ValidateFile(filename string) ([]*Problem, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Unmarshal(filename, data)
+	if err != nil {
+		return nil, ee.Wrap(err, "cannot parse config")
+	}
+
+	if exstrings.InStringList(LintStagedConfigFileNames, filepath.Base(filename)) {
+		return ValidateLintStagedConfig(c)
+	}
+
+	problems, err := ValidateKittyConfig(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// the extended sources are validated only if the file itself is valid,
+	// otherwise the problems would be reported twice
+	if len(problems) != 0 {
+		return problems, nil
+	}
+
+	m, err := LoadKittyConfigFile(filename)
+	if err != nil {
+		return []*Problem{{Path: []any{ExtendsKey}, Message: err.Error()}}, nil
+	}
+
+	for _, layer := range m.Layers[:len(m.Layers)-1] { // the last one is the file itself
+		layerProblems, err := ValidateKittyConfig(layer.Values)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range layerProblems {
+			problems = append(problems, &Problem{
+				Path:    []any{ExtendsKey},
+				Message: layer.Origin + ": " + p.String(),
+			})
+		}
+	}
+
+	return problems, nil
+}
+
+// FindConfigFiles finds all kitty and lint-staged config files tracked by git in the repository containing dir
+// (or in dir itself if it's not inside a git repository), and the user-level config files
+//
+// it also returns the problems that some directories contain more than one kitty config file
+func FindConfigFiles(dir string) ([]string, []string, error) {
+	root, err := RepoRoot(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var files []string
+
+	r := git.R(root, []string{"ls-files", "-z", "--cached", "--others", "--exclude-standard"})
+	if r.Err() == nil {
+		for _, f := range strings.Split(string(r.Output), "\x00") {
+			name := filepath.Base(f)
+			if exstrings.InStringList(ConfigFileNames, name) || exstrings.InStringList(LintStagedConfigFileNames, name) {
+				files = append(files, filepath.Join(root, f))
+			}
+		}
+	} else {
+		found, err := findKittyConfigFiles(root, append(ConfigFileNames, LintStagedConfigFileNames...))
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, found...)
+	}
+
+	if userDir, err := UserConfigDir(); err == nil {
+		found, err := findKittyConfigFiles(userDir, UserConfigFileNames)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, found...)
+	}
+
+	sort.Strings(files)
+
+	// detect the directories containing more than one kitty config
+	byDir := make(map[string][]string)
+	for _, f := range files {
+		if !exstrings.InStringList(LintStagedConfigFileNames, filepath.Base(f)) {
+			byDir[filepath.Dir(f)] = append(byDir[filepath.Dir(f)], f)
+		}
+	}
+
+	var conflicts []string
+	for d, dirFiles := range byDir {
+		if len(dirFiles) > 1 {
+			conflicts = append(conflicts, d+": multiple kitty config files found ["+strings.Join(dirFiles, ", ")+"], only one is allowed")
+		}
+	}
+	sort.Strings(conflicts)
+
+	return files, conflicts, nil
+}
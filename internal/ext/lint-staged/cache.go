@@ -0,0 +1,201 @@
+package lintstaged
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+)
+
+// CacheDir is the directory of the result cache, relative to the git config dir
+const CacheDir = "kitty-lint-staged-cache"
+
+// resultCache remembers the file contents a command already passed on, see Command.Cache
+//
+// an entry is an empty file at <dir>/<blob hash>/<command key>,
+// so all entries of a content can be removed at once when a fixer modifies it
+type resultCache struct {
+	dir     string
+	gitRoot string
+}
+
+func newResultCache(gitConfigDir, gitRoot string) *resultCache {
+	return &resultCache{
+		dir:     filepath.Join(gitConfigDir, CacheDir),
+		gitRoot: gitRoot,
+	}
+}
+
+// commandKey returns the key of the command in the config,
+// which changes with the command and its options, the resolved config (and the ones it inherits) and the version of the tool
+func (c *resultCache) commandKey(config *Config, cmd *Command) string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "command\x00%s\x00%s\x00", cmd.Command, cmd.execCommand)
+	fmt.Fprintf(h, "options\x00%t %t %t %t %t %q %q\x00", cmd.Dir, cmd.Absolute, cmd.NoArgs, cmd.ArgFile, cmd.ChangedLinesOnly, cmd.Prepend, cmd.Cwd)
+	fmt.Fprintf(h, "placeholders\x00%q %q %t %t\x00", cmd.rangeTemplate, cmd.placeholder, cmd.perFile, cmd.renameSource)
+	for _, name := range sortedKeys(cmd.Env) {
+		fmt.Fprintf(h, "env\x00%s=%s\x00", name, cmd.Env[name])
+	}
+	for ; config != nil; config = config.Parent { // the inherited source chain
+		fmt.Fprintf(h, "config\x00%s\x00%s\x00", config.Path, config.hash)
+	}
+	fmt.Fprintf(h, "tool\x00%s\x00", c.toolVersion(cmd.Command))
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fileKey returns the key of the command on the file,
+// which also changes with the values of the placeholders resolved for the file (the path, the rename source and the changed lines)
+func (c *resultCache) fileKey(key string, cmd *Command, file *File, lines changedLines) string {
+	h := sha256.New()
+
+	fmt.Fprintf(h, "command\x00%s\x00", key)
+	if cmd.perFile || cmd.placeholder != "" || cmd.rangeTemplate != "" {
+		fmt.Fprintf(h, "file\x00%s\x00", file.GitRelativePath())
+	}
+	if from := file.RenamedFrom(); cmd.renameSource && from != nil {
+		fmt.Fprintf(h, "from\x00%s\x00", from.GitRelativePath())
+	}
+	if cmd.ChangedLinesOnly || cmd.rangeTemplate != "" {
+		for _, r := range lines[file.GitRelativePath()] {
+			fmt.Fprintf(h, "lines\x00%d-%d\x00", r.Start, r.End)
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// toolVersion returns the version of the tool in .kitty/.bin running the command, or empty if it's not a kitty tool
+func (c *resultCache) toolVersion(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	// .kitty/.bin/<name> -> <system-key>/<name>@<version>
+	link, err := os.Readlink(filepath.Join(c.gitRoot, ".kitty", ".bin", fields[0]))
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(link)
+}
+
+// blobs returns the git blob hashes of the files, empty for the files cannot be read
+func (c *resultCache) blobs(files Files) []string {
+	blobs := make([]string, len(files))
+	for i, file := range files {
+		blob, err := blobHash(file.AbsolutePath())
+		if err != nil {
+			slog.Debug("Cannot hash file for cache", "file", file.AbsolutePath(), "error", err)
+		}
+		blobs[i] = blob
+	}
+	return blobs
+}
+
+func (c *resultCache) entryPath(key, blob string) string {
+	return filepath.Join(c.dir, blob, key)
+}
+
+// has reports whether the command already passed on the content
+func (c *resultCache) has(key, blob string) bool {
+	if blob == "" {
+		return false
+	}
+
+	_, err := os.Stat(c.entryPath(key, blob))
+	return err == nil
+}
+
+// record remembers that the command passed on the content
+func (c *resultCache) record(key, blob string) error {
+	if blob == "" {
+		return nil
+	}
+
+	p := c.entryPath(key, blob)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, nil, 0644)
+}
+
+// invalidate forgets all results on the content
+func (c *resultCache) invalidate(blob string) error {
+	if blob == "" {
+		return nil
+	}
+
+	return os.RemoveAll(filepath.Join(c.dir, blob))
+}
+
+// update records the results of the command (if keys is not nil, one for each file) after it ran on the files,
+// the files modified by the command are invalidated instead of recorded
+func (c *resultCache) update(keys []string, files Files, before []string, success bool) {
+	after := c.blobs(files)
+
+	for i, file := range files {
+		var err error
+		switch {
+		case after[i] != before[i]:
+			err = c.invalidate(before[i])
+		case keys != nil && success:
+			err = c.record(keys[i], after[i])
+		}
+		if err != nil {
+			slog.Debug("Cannot update cache", "file", file.AbsolutePath(), "error", err)
+		}
+	}
+}
+
+func (c *resultCache) clear() error {
+	if err := os.RemoveAll(c.dir); err != nil {
+		return ee.Wrap(err, "cannot remove cache directory")
+	}
+	return nil
+}
+
+// blobHash returns the hash of the file like `git hash-object --no-filters`
+func blobHash(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha1.New()
+	fmt.Fprintf(h, "blob %d\x00", stat.Size())
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// clearCache removes the result cache of the git repository of the current directory
+func clearCache() error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ee.Wrap(err, "cannot get current working directory")
+	}
+
+	gitRoot, gitConfigDir, err := resolveGitRepo(cwd)
+	if err != nil || gitRoot == "" {
+		return ee.New("current directory is not a git directory")
+	}
+
+	return newResultCache(gitConfigDir, gitRoot).clear()
+}
@@ -0,0 +1,101 @@
+package lintstaged
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCacheUpdate(t *testing.T) {
+	root := t.TempDir()
+	c := newResultCache(filepath.Join(root, ".git"), root)
+
+	writeFile(t, root, "a.txt", "a\n")
+	writeFile(t, root, "b.txt", "b\n")
+	files := NewFiles(&State{gitRoot: root}, []string{"a.txt", "b.txt"})
+
+	before := c.blobs(files)
+	assert.Equal(t, "78981922613b2afb6025042ff6bd878ac1994e85", before[0], "same as git hash-object")
+
+	// a fixer modifies b.txt, so the results on its old content are invalidated
+	require.NoError(t, c.record("other", before[1]))
+	writeFile(t, root, "b.txt", "B\n")
+	c.update([]string{"key", "key"}, files, before, true)
+
+	after := c.blobs(files)
+	assert.True(t, c.has("key", after[0]))
+	assert.False(t, c.has("key", before[1]))
+	assert.False(t, c.has("key", after[1]), "the modified content must run again")
+	assert.False(t, c.has("other", before[1]))
+
+	// failed commands are not recorded
+	require.NoError(t, os.Remove(filepath.Join(root, "a.txt")))
+	writeFile(t, root, "a.txt", "a2\n")
+	before = c.blobs(files)
+	c.update([]string{"key", "key"}, files, before, false)
+	assert.False(t, c.has("key", before[0]))
+}
+
+func TestResultCacheCommandKey(t *testing.T) {
+	root := t.TempDir()
+	c := newResultCache(filepath.Join(root, ".git"), root)
+
+	writeFile(t, root, "shared/kitty.json", `{"lint-staged": {"*": "eslint"}}`)
+	writeFile(t, root, ".kittyrc.json", `{"extends": "./shared/kitty.json"}`)
+	config, err := loadConfig(filepath.Join(root, ".kittyrc.json"))
+	require.NoError(t, err)
+
+	key := c.commandKey(config, &Command{Command: "eslint"})
+	assert.Equal(t, key, c.commandKey(config, &Command{Command: "eslint"}))
+	assert.NotEqual(t, key, c.commandKey(config, &Command{Command: "eslint --fix"}))
+	assert.NotEqual(t, key, c.commandKey(config, &Command{Command: "eslint", Absolute: true}))
+	assert.NotEqual(t, key, c.commandKey(config, &Command{Command: "eslint", ChangedLinesOnly: true}))
+	assert.NotEqual(t, key, c.commandKey(config, &Command{Command: "eslint", ArgFile: true}))
+
+	// the values of the placeholders are in the key of each file
+	rangeCmd, err := parseStringCommand("golint --line {file}:{start}-{end}")
+	require.NoError(t, err)
+	renameCmd, err := parseStringCommand("git-mv-check {from} {file}")
+	require.NoError(t, err)
+	a := NewFile(&State{gitRoot: root}, "a.go")
+	b := NewFile(&State{gitRoot: root}, "b.go")
+	renamed := newSelectedFiles(&State{gitRoot: root}, []selectedFile{{path: "b.go", from: "a.go"}})[0]
+	lines := changedLines{"a.go": {{Start: 1, End: 2}}, "b.go": {{Start: 1, End: 2}}}
+	plain := &Command{Command: "eslint"}
+
+	assert.Equal(t, c.fileKey(key, plain, a, lines), c.fileKey(key, plain, b, nil), "no placeholders")
+	assert.NotEqual(t, c.fileKey(key, rangeCmd, a, lines), c.fileKey(key, rangeCmd, b, lines), "the file path")
+	assert.NotEqual(t, c.fileKey(key, rangeCmd, a, lines), c.fileKey(key, rangeCmd, a, changedLines{"a.go": {{Start: 1, End: 3}}}), "the changed lines")
+	assert.NotEqual(t, c.fileKey(key, &Command{Command: "eslint", ChangedLinesOnly: true}, a, lines),
+		c.fileKey(key, &Command{Command: "eslint", ChangedLinesOnly: true}, a, nil), "the changed lines")
+	assert.NotEqual(t, c.fileKey(key, renameCmd, b, nil), c.fileKey(key, renameCmd, renamed, nil), "the rename source")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, ".kitty", ".bin"), 0755))
+	require.NoError(t, os.Symlink("linux-amd64/eslint@1.0.0", filepath.Join(root, ".kitty", ".bin", "eslint")))
+	assert.Equal(t, "eslint@1.0.0", c.toolVersion("eslint --fix"))
+	assert.NotEqual(t, key, c.commandKey(config, &Command{Command: "eslint"}), "the tool version changes the key")
+
+	key = c.commandKey(config, &Command{Command: "eslint"})
+	writeFile(t, root, "shared/kitty.json", `{"lint-staged": {"*": {"filter": {"maxSize": "1MB"}, "commands": "eslint"}}}`)
+	config, err = loadConfig(filepath.Join(root, ".kittyrc.json"))
+	require.NoError(t, err)
+	extendsKey := c.commandKey(config, &Command{Command: "eslint"})
+	assert.NotEqual(t, key, extendsKey, "the extends source changes the key")
+
+	// the inherited rules run with the key of their own config and its parents
+	writeFile(t, root, "sub/.lintstagedrc.json", `{"inherit": true, "files": {"*.md": "prettier"}}`)
+	sub, err := loadConfig(filepath.Join(root, "sub", ".lintstagedrc.json"))
+	require.NoError(t, err)
+	sub.Parent = config
+	subKey := c.commandKey(sub, &Command{Command: "eslint"})
+
+	writeFile(t, root, "shared/kitty.json", `{"lint-staged": {"*": "eslint"}}`)
+	config, err = loadConfig(filepath.Join(root, ".kittyrc.json"))
+	require.NoError(t, err)
+	sub.Parent = config
+	assert.Equal(t, key, c.commandKey(config, &Command{Command: "eslint"}))
+	assert.NotEqual(t, subKey, c.commandKey(sub, &Command{Command: "eslint"}), "the parent config changes the key")
+}
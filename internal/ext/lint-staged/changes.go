@@ -0,0 +1,186 @@
+package lintstaged
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/set"
+
+	"github.com/ImSingee/kitty/internal/lib/git"
+)
+
+// worktreeSnapshot is the working tree before running the tasks, to find the files modified by them (with --fail-on-changes)
+type worktreeSnapshot struct {
+	gitRoot      string
+	maxArgLength int
+	files        []string          // the selected files, relative to the git root
+	indexFile    string            // a temporary index with the selected files added, empty to compare with the real index
+	others       map[string]string // the blob hashes of the other changed files, empty if deleted
+}
+
+// takeSnapshot records the working tree, the selected files can be compared with the real index
+// only if their unstaged changes are hidden (i.e. the staged files)
+func takeSnapshot(gitRoot string, files Files, usesIndex bool, maxArgLength int) (s *worktreeSnapshot, err error) {
+	s = &worktreeSnapshot{
+		gitRoot:      gitRoot,
+		maxArgLength: maxArgLength,
+		files:        files.GitRelativePaths(),
+	}
+
+	if !usesIndex {
+		var dir string
+		dir, err = os.MkdirTemp("", "kitty-lint-staged-snapshot-")
+		if err != nil {
+			return nil, ee.Wrap(err, "cannot create temporary directory")
+		}
+		s.indexFile = filepath.Join(dir, "index")
+		defer func() {
+			if err != nil {
+				s.remove()
+			}
+		}()
+
+		for _, chunk := range chunkFiles(s.files, maxArgLength) {
+			if _, err = execGitWithIndex(append([]string{"add", "--force", "--"}, chunk...), gitRoot, s.indexFile); err != nil {
+				return nil, ee.Wrap(err, "cannot snapshot the selected files")
+			}
+		}
+	}
+
+	s.others, err = s.otherChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// modifiedFiles returns the selected files modified since the snapshot
+func (s *worktreeSnapshot) modifiedFiles() ([]string, error) {
+	var modified []string
+	for _, chunk := range chunkFiles(s.files, s.maxArgLength) {
+		output, err := execGitWithIndex(append([]string{"diff", "--name-only", "-z", "--"}, chunk...), s.gitRoot, s.indexFile)
+		if err != nil {
+			return nil, ee.Wrap(err, "cannot get modified files")
+		}
+		if output != "" {
+			modified = append(modified, parseGitZOutput(output)...)
+		}
+	}
+	return modified, nil
+}
+
+// diff returns the diff of the files since the snapshot
+func (s *worktreeSnapshot) diff(files []string) (string, error) {
+	var diff strings.Builder
+	for _, chunk := range chunkFiles(files, s.maxArgLength) {
+		output, err := execGitWithIndex(append([]string{"diff", "--no-ext-diff", "--no-color", "--"}, chunk...), s.gitRoot, s.indexFile)
+		if err != nil {
+			return "", ee.Wrap(err, "cannot get diff")
+		}
+		diff.WriteString(output)
+	}
+	return strings.TrimSpace(diff.String()), nil
+}
+
+// outsideChanges returns the files not selected but modified since the snapshot
+func (s *worktreeSnapshot) outsideChanges() ([]string, error) {
+	others, err := s.otherChangedFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	changed := set.New[string]()
+	for name, hash := range others {
+		if before, ok := s.others[name]; !ok || before != hash {
+			changed.Add(name)
+		}
+	}
+	for name := range s.others {
+		if _, ok := others[name]; !ok { // reverted
+			changed.Add(name)
+		}
+	}
+
+	result := changed.All()
+	sort.Strings(result)
+	return result, nil
+}
+
+// otherChangedFiles returns the blob hashes of the changed (or untracked) files not selected
+func (s *worktreeSnapshot) otherChangedFiles() (map[string]string, error) {
+	output, err := execGitWithIndex([]string{"status", "--porcelain", "-z", "--untracked-files=all"}, s.gitRoot, "")
+	if err != nil {
+		return nil, ee.Wrap(err, "cannot get status")
+	}
+
+	selected := set.New(s.files...)
+	result := make(map[string]string)
+
+	// the entries are "XY <path>", followed by the original path for renames and copies
+	entries := parseGitZOutput(output)
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+
+		name := entry[3:]
+		if selected.Has(name) {
+			continue
+		}
+
+		hash, _ := blobHash(filepath.Join(s.gitRoot, name))
+		result[name] = hash
+	}
+
+	return result, nil
+}
+
+func (s *worktreeSnapshot) remove() {
+	if s.indexFile != "" {
+		_ = os.RemoveAll(filepath.Dir(s.indexFile))
+	}
+}
+
+// execGitWithIndex runs git with another index file (if not empty), the output is not trimmed
+func execGitWithIndex(args []string, dir string, indexFile string) (string, error) {
+	g := &git.G{Dir: dir}
+	if indexFile != "" {
+		g.Env = append(os.Environ(), "GIT_INDEX_FILE="+indexFile)
+	}
+
+	result := g.Run(append([]string{"-c", "submodule.recurse=false"}, args...)...)
+	return string(result.Output), result.Err()
+}
+
+// checkModifications reports the files modified by the tasks with --fail-on-changes, it returns an error if any selected file is modified
+func checkModifications(state *State, options *Options) error {
+	if outside, err := state.snapshot.outsideChanges(); err != nil {
+		state.output = append(state.output, fmt.Sprintf("%s Cannot check the modifications by tasks (%s)", warning, err.Error()))
+	} else if len(outside) != 0 {
+		state.output = append(state.output, fmt.Sprintf("%s Tasks modified %d files outside the %s:\n  %s", warning, len(outside), options.SelectedFilesLabel(), strings.Join(outside, "\n  ")))
+	}
+
+	modified, err := state.snapshot.modifiedFiles()
+	if err != nil {
+		return err
+	}
+	if len(modified) == 0 {
+		return nil
+	}
+
+	diff, err := state.snapshot.diff(modified)
+	if err != nil {
+		diff = err.Error()
+	}
+	state.output = append(state.output, fmt.Sprintf("%s Tasks modified %d files:\n\n%s", x, len(modified), diff))
+	return fmt.Errorf("tasks modified %d files", len(modified))
+}
@@ -5,7 +5,10 @@ import (
 	"sync"
 )
 
-var defaultMaxArgLength = sync.OnceValue(func() int {
+// platformArgLimit is the limit of the command line of the platform:
+// the length of one argument (MAX_ARG_STRLEN) on Linux, of all the arguments and the environment (ARG_MAX) on darwin,
+// and of the whole command line on Windows
+var platformArgLimit = sync.OnceValue(func() int {
 	switch runtime.GOOS {
 	case "darwin":
 		return 262144
@@ -16,6 +19,18 @@ var defaultMaxArgLength = sync.OnceValue(func() int {
 	}
 })
 
+// defaultMaxArgLength leaves a safety margin below the platform limit for the shell, its arguments and the environment
+var defaultMaxArgLength = sync.OnceValue(func() int {
+	limit := platformArgLimit()
+	return limit - max(limit/10, 2048)
+})
+
+// commandMaxArgLength returns the maximum length of the (quoted) file arguments of the command,
+// the whole `-c` script of the shell is one argument
+func commandMaxArgLength(options *Options, cmd *Command) int {
+	return max(options.maxArgLength-len(cmd.execCommand)-1, 1)
+}
+
 func chunkFiles(filenames []string, maxArgLength int) [][]string {
 	if maxArgLength <= 0 { // no limit
 		return [][]string{filenames}
@@ -1,48 +1,150 @@
 package lintstaged
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
 	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/ImSingee/go-ex/ee"
 	"github.com/ImSingee/go-ex/exstrings"
 	"github.com/ImSingee/go-ex/mr"
-	"github.com/gobwas/glob"
 	"github.com/ysmood/gson"
 
 	"github.com/ImSingee/kitty/internal/config"
+	"github.com/ImSingee/kitty/internal/lib/glob"
 )
 
 type Config struct {
-	Path  string
-	Rules []*Rule
+	Path    string
+	Rules   []*Rule
+	Inherit bool    // also run the rules of the parent config, see effectiveRules
+	Parent  *Config // the config to inherit the rules from, nil if Inherit is false or there isn't one
+
+	hash string // of the resolved config (with the extends sources), see resultCache.commandKey
 }
 
 type Rule struct {
-	Glob       glob.Glob
+	Glob       *glob.Glob // matches the paths relative to the config file's directory
 	GlobString string
+	Filter     *Filter      // nil means no filter
+	On         []FileStatus // the statuses of the files the rule runs on, nil means all but the deleted files
+	Workspace  []string     // the marker files of the workspaces to run the commands in, nil means the config's directory
 	Commands   []*Command
 }
 
+// runsOn reports whether the rule runs on the files with the status
+func (r *Rule) runsOn(status FileStatus) bool {
+	if len(r.On) == 0 {
+		return status != FileStatusDeleted
+	}
+	return slices.Contains(r.On, status)
+}
+
+// matches reports whether the pattern of the rule matches the file, the pattern is relative to wd (the directory of its config)
+func (r *Rule) matches(wd string, file *File) bool {
+	rel, err := filepath.Rel(wd, file.AbsolutePath())
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	// the files outside the config's directory can only be matched by the patterns starting with ../
+	if strings.HasPrefix(rel, "../") && !r.Glob.IsParentPattern() {
+		return false
+	}
+
+	return r.Glob.Match(rel)
+}
+
+// hasRulesOn reports whether any rule of the configs explicitly runs on the files with the status
+func hasRulesOn(configs []*Config, status FileStatus) bool {
+	for _, c := range configs {
+		for _, r := range c.effectiveRules() {
+			if slices.Contains(r.rule.On, status) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// usesChangedLines reports whether any command of the configs needs the changed lines, see Command.needsChangedLines
+func usesChangedLines(configs []*Config) bool {
+	for _, c := range configs {
+		for _, r := range c.effectiveRules() {
+			if slices.ContainsFunc(r.rule.Commands, (*Command).needsChangedLines) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 type Command struct {
 	Command string // command show to user
+	Title   string // title show in the task list, defaults to Command
 
 	Dir      bool // use dir instead of file
 	Absolute bool
 	NoArgs   bool
 	Prepend  string // prepend to each file
+	ArgFile  bool   // pass the files in a temporary file instead of the arguments, see argFileArg
+	Fixer    bool   // modifies the files, runs in place even with --isolated
+	Cache    bool   // skip the files already passed with the same content, see resultCache
+
+	ChangedLinesOnly bool // keep only the diagnostics on the changed lines in the output, see filterChangedLines
+
+	Timeout time.Duration     // 0 means no timeout
+	Env     map[string]string // extra environment variables
+	Cwd     string            // working directory, relative to the config file's directory
+
+	execCommand   string // real command to execute
+	rangeTemplate string // the argument expanded for each changed line range, see parseLineRangeTemplate
+	placeholder   string // the placeholder replaced by the arguments (files, dirs, pkgs or relfiles), see parsePlaceholders
+	perFile       bool   // runs once for each file, replacing {file}
+	renameSource  bool   // {from} is replaced by the source of the renamed file, with perFile
+	argsAt        int    // the position in execCommand to insert the arguments, if rangeTemplate or placeholder is used
+}
+
+// commandLine returns the command line to run with the (quoted) file arguments
+func (c *Command) commandLine(fileArgs []string) string {
+	if c.perFile {
+		execCommand := c.execCommand
+		if c.renameSource && len(fileArgs) == 2 { // the file and the source of the rename, see commandChunks
+			execCommand = strings.ReplaceAll(execCommand, fromPlaceholder, fileArgs[1])
+			fileArgs = fileArgs[:1]
+		}
+		return strings.ReplaceAll(execCommand, filePlaceholder, strings.Join(fileArgs, " "))
+	}
 
-	execCommand string // real command to execute
+	if c.rangeTemplate != "" || c.placeholder != "" {
+		parts := []string{strings.TrimSpace(c.execCommand[:c.argsAt])}
+		parts = append(parts, fileArgs...)
+		if after := strings.TrimSpace(c.execCommand[c.argsAt:]); after != "" {
+			parts = append(parts, after)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	if len(fileArgs) == 0 {
+		return c.execCommand
+	}
+	return c.execCommand + " " + strings.Join(fileArgs, " ")
 }
 
-func searchConfigs(cwd, gitDir, configPath string) ([]*Config, error) {
+// searchConfigs searches the config files of the git repository (and the submodules) under cwd, deepest first
+func searchConfigs(cwd, gitDir, configPath string, submodules []string) ([]*Config, error) {
 	slog.Debug("Searching for configuration files...")
 
 	// Use only explicit config path instead of discovering multiple
@@ -65,6 +167,15 @@ func searchConfigs(cwd, gitDir, configPath string) ([]*Config, error) {
 	if err != nil {
 		return nil, ee.Wrap(err, "cannot get list of known files")
 	}
+	for _, submodule := range submodules {
+		files, err := getCachedFiles(filepath.Join(gitDir, submodule))
+		if err != nil {
+			return nil, ee.Wrapf(err, "cannot get list of known files in submodule %s", submodule)
+		}
+		for _, file := range files {
+			cachedFiles = append(cachedFiles, path.Join(submodule, file))
+		}
+	}
 
 	//otherFiles, err := getUncommittedFiles(gitDir)
 	//if err != nil {
@@ -72,18 +183,19 @@ func searchConfigs(cwd, gitDir, configPath string) ([]*Config, error) {
 	//}
 	//possibleConfigFiles := mr.Flats(cachedFiles, otherFiles)
 
-	possibleConfigFiles := cachedFiles
-	possibleConfigFiles = mr.Filter(possibleConfigFiles, func(file string, _index int) bool {
+	// all the config files, the ones outside cwd can only be inherited
+	allConfigFiles := mr.Filter(cachedFiles, func(file string, _index int) bool {
 		return exstrings.InStringList(validConfigNames, filepath.Base(file))
 	})
-	possibleConfigFiles = mr.Map(possibleConfigFiles, func(file string, _index int) string {
+	allConfigFiles = mr.Map(allConfigFiles, func(file string, _index int) string {
 		return normalizePath(filepath.Join(gitDir, file))
 	})
-	possibleConfigFiles = mr.Filter(possibleConfigFiles, func(file string, _index int) bool {
-		return strings.HasPrefix(file, cwd)
+	sort.SliceStable(allConfigFiles, func(i, j int) bool {
+		return numberOfLevels(allConfigFiles[i]) > numberOfLevels(allConfigFiles[j])
 	})
-	sort.Slice(possibleConfigFiles, func(i, j int) bool {
-		return numberOfLevels(possibleConfigFiles[i]) > numberOfLevels(possibleConfigFiles[j])
+
+	possibleConfigFiles := mr.Filter(allConfigFiles, func(file string, _index int) bool {
+		return strings.HasPrefix(file, cwd)
 	})
 
 	slog.Debug("Found possible config files", "possibleConfigFiles", possibleConfigFiles, "possibleConfigFilesCount", len(possibleConfigFiles))
@@ -124,15 +236,15 @@ func searchConfigs(cwd, gitDir, configPath string) ([]*Config, error) {
 		configBasePaths[basePath] = config.Path
 	}
 
+	if err := linkParentConfigs(configs, allConfigFiles, gitDir, submodules); err != nil {
+		return nil, err
+	}
+
 	return configs, nil
 }
 
-var validConfigNames = append(config.ConfigFileNames,
-	".lintstagedrc",
-	".lintstagedrc.json",
-	// TODO yaml config files
-	// TODO js config files
-)
+// TODO js config files
+var validConfigNames = append(config.ConfigFileNames, config.LintStagedConfigFileNames...)
 
 // loadConfig read and parse config file
 //
@@ -148,13 +260,36 @@ func loadConfig(file string) (*Config, error) {
 
 	files := in["files"].Map()
 
+	// the path of the rules in the file, used in error messages
+	var rulesPath []any
+	if exstrings.InStringList(config.ConfigFileNames, filepath.Base(file)) {
+		rulesPath = []any{"lint-staged"}
+	}
+
+	// only in the format with the "files" key, "inherit" is a pattern in the short format
+	inherit := false
+	if v, ok := in["inherit"]; ok {
+		inherit, ok = v.Val().(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s: must be a boolean", config.FormatPath(append(rulesPath[:len(rulesPath):len(rulesPath)], "inherit")))
+		}
+	}
+
+	resolved, err := json.Marshal(in)
+	if err != nil {
+		return nil, ee.Wrap(err, "cannot encode config")
+	}
+	hash := sha256.Sum256(resolved)
+
 	config := &Config{
-		Path:  file,
-		Rules: make([]*Rule, 0, len(files)),
+		Path:    file,
+		Rules:   make([]*Rule, 0, len(files)),
+		Inherit: inherit,
+		hash:    hex.EncodeToString(hash[:]),
 	}
 
 	for k, v := range files {
-		vv, err := parseConfigRuleEntry(k, v)
+		vv, err := parseConfigRuleEntry(append(rulesPath[:len(rulesPath):len(rulesPath)], k), k, v)
 		if err != nil {
 			return nil, err
 		}
@@ -188,8 +323,11 @@ func configLoad(filename string) (map[string]gson.JSON, error) {
 		return jsonLoad(content)
 	}
 
-	// // TODO support yaml
-	//	// TODO support js
+	if strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml") {
+		return yamlLoad(name, content)
+	}
+
+	// TODO support js
 
 	return nil, ee.Errorf("unsupported config filename: %s", name)
 }
@@ -199,12 +337,12 @@ func configLoad(filename string) (map[string]gson.JSON, error) {
 // the returned map may be nil if it doesn't contain the `lint-staged` key
 // and if it's not nil, the returned map always contains a "files" key with type map[string]any
 func kittyConfigLoad(filename string) (map[string]gson.JSON, error) {
-	kc, err := config.ReadKittyConfig(filename)
+	m, err := config.LoadKittyConfigFile(filename)
 	if err != nil {
 		return nil, ee.Wrap(err, "cannot read kitty config file")
 	}
 
-	ls, ok := kc["lint-staged"]
+	ls, ok := m.Values()["lint-staged"]
 	if !ok {
 		return nil, nil
 	}
@@ -226,6 +364,18 @@ func jsonLoad(data []byte) (map[string]gson.JSON, error) {
 	return loadConfigJSON(gson.New(in).Map())
 }
 
+// yamlLoad loads yaml file and convert it to map[string]gson.JSON
+//
+// the returned map always contains a "files" key with type map[string]any
+func yamlLoad(name string, data []byte) (map[string]gson.JSON, error) {
+	in, err := config.Unmarshal(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return loadConfigJSON(gson.New(in).Map())
+}
+
 func loadConfigJSON(m map[string]gson.JSON) (map[string]gson.JSON, error) {
 	if len(m) == 0 {
 		return nil, ee.New("empty config")
@@ -245,7 +395,8 @@ func numberOfLevels(p string) int {
 	return strings.Count(p, string(filepath.Separator))
 }
 
-func parseConfigRuleEntry(key string, v gson.JSON) (*Rule, error) {
+// parseConfigRuleEntry parses a rule, path is the path of the rule in the config file (for error messages)
+func parseConfigRuleEntry(path []any, key string, v gson.JSON) (*Rule, error) {
 	rule := &Rule{
 		Glob:       nil,
 		GlobString: key,
@@ -254,43 +405,230 @@ func parseConfigRuleEntry(key string, v gson.JSON) (*Rule, error) {
 
 	g, err := glob.Compile(key)
 	if err != nil {
-		return nil, ee.Wrapf(err, "cannot parse glob pattern `%s`", key)
+		return nil, fmt.Errorf("%s: %w", config.FormatPath(path), err)
 	}
 	rule.Glob = g
 
-	switch vv := v.Val().(type) {
-	case nil:
-		return nil, fmt.Errorf("invalid nil command for %s", key)
-	case string:
-		cmd, err := parseStringCommand(vv)
-		if err != nil {
-			return nil, ee.Wrapf(err, "cannot parse command `%s`", vv)
+	// the rule in object form: {"filter": {...}, "on": [...], "commands": ...}
+	if m, ok := v.Val().(map[string]any); ok {
+		if _, ok := m["commands"]; ok {
+			return parseObjectRule(path, rule, m)
 		}
-		rule.Commands = []*Command{cmd}
-		return rule, nil
+	}
+
+	rule.Commands, err = parseRuleCommands(path, v.Val())
+	if err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// parseObjectRule parses a rule in object form, e.g.
+//
+//	{"filter": {"language": "Shell"}, "commands": ["shellcheck", "shfmt -w"]}
+//	{"on": ["deleted", "renamed"], "commands": "./scripts/regenerate-index.sh"}
+//	{"workspace": true, "commands": "[noArgs] go vet ./..."}
+func parseObjectRule(path []any, rule *Rule, v map[string]any) (*Rule, error) {
+	for _, key := range sortedKeys(v) {
+		keyPath := append(path[:len(path):len(path)], key)
+
+		switch key {
+		case "commands":
+			commands, err := parseRuleCommands(keyPath, v[key])
+			if err != nil {
+				return nil, err
+			}
+			rule.Commands = commands
+		case "filter":
+			filter, err := parseFilter(keyPath, v[key])
+			if err != nil {
+				return nil, err
+			}
+			rule.Filter = filter
+		case "on":
+			on, err := parseRuleOn(keyPath, v[key])
+			if err != nil {
+				return nil, err
+			}
+			rule.On = on
+		case "workspace":
+			markers, err := parseWorkspace(keyPath, v[key])
+			if err != nil {
+				return nil, err
+			}
+			rule.Workspace = markers
+		default:
+			return nil, fmt.Errorf("%s: unknown option", config.FormatPath(keyPath))
+		}
+	}
+
+	return rule, nil
+}
+
+// parseRuleOn parses the statuses of the files a rule runs on, a status or a list of them
+func parseRuleOn(path []any, v any) ([]FileStatus, error) {
+	names, err := stringOrList(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", config.FormatPath(path), err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("%s: empty list", config.FormatPath(path))
+	}
+
+	on := make([]FileStatus, 0, len(names))
+	for _, name := range names {
+		status := FileStatus(name)
+		if !slices.Contains(fileStatuses, status) {
+			return nil, fmt.Errorf("%s: unknown status %q (must be one of: added, modified, renamed, deleted)", config.FormatPath(path), name)
+		}
+		on = append(on, status)
+	}
+	return on, nil
+}
+
+// parseRuleCommands parses the commands of a rule, which is a command or a list of commands
+func parseRuleCommands(path []any, v any) ([]*Command, error) {
+	switch vv := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("%s: invalid nil command", config.FormatPath(path))
 	case []any:
 		result := make([]*Command, 0, len(vv))
 		for i, vvv := range vv {
-			s, err := parseRule(vvv)
+			s, err := parseRule(append(path[:len(path):len(path)], i), vvv)
 			if err != nil {
-				return nil, fmt.Errorf("invalid command for %s.%d: %w", key, i+1, err)
+				return nil, err
 			}
 			result = append(result, s)
 		}
-		rule.Commands = result
-		return rule, nil
+		return result, nil
 	default:
-		return nil, fmt.Errorf("invalid value type (must be string or string list) for key %s", key)
+		cmd, err := parseRule(path, vv)
+		if err != nil {
+			return nil, err
+		}
+		return []*Command{cmd}, nil
+	}
+}
+
+// parseRule parses a command, which is either a string or an object (see parseObjectCommand)
+func parseRule(path []any, v any) (*Command, error) {
+	switch vv := v.(type) {
+	case string:
+		cmd, err := parseStringCommand(vv)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", config.FormatPath(path), err)
+		}
+		return cmd, nil
+	case map[string]any:
+		return parseObjectCommand(path, vv)
+	default:
+		return nil, fmt.Errorf("%s: invalid value type (must be string, object or a list of them) for command", config.FormatPath(path))
 	}
 }
 
-func parseRule(v any) (*Command, error) {
-	// only support string now
-	if str, ok := v.(string); ok {
-		return parseStringCommand(str)
+// parseObjectCommand parses a command in object form, e.g.
+//
+//	{"run": "eslint --fix", "absolute": true, "timeout": "30s", "env": {"DEBUG": "1"}, "cwd": "..", "title": "ESLint", "fixer": true, "cache": true, "argfile": true}
+//
+// the options can also be given as the prefixes of `run` like the string form
+func parseObjectCommand(path []any, v map[string]any) (*Command, error) {
+	fieldPath := func(key string) string {
+		return config.FormatPath(append(path[:len(path):len(path)], key))
+	}
+
+	run, ok := v["run"].(string)
+	if !ok {
+		return nil, fmt.Errorf("%s: required and must be a string", fieldPath("run"))
+	}
+
+	cmd, err := parseStringCommand(run)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fieldPath("run"), err)
+	}
+
+	for _, key := range sortedKeys(v) {
+		value := v[key]
+
+		var ok bool
+		switch key {
+		case "run":
+			ok = true
+		case "title":
+			cmd.Title, ok = value.(string)
+		case "fixer":
+			cmd.Fixer, ok = value.(bool)
+		case "cache":
+			cmd.Cache, ok = value.(bool)
+		case "dir", "absolute", "noArgs", "changedLinesOnly", "argfile": // override the prefixes in run
+			var b bool
+			b, ok = value.(bool)
+			switch key {
+			case "argfile":
+				cmd.ArgFile = b
+			case "changedLinesOnly":
+				cmd.ChangedLinesOnly = b
+			case "dir":
+				cmd.Dir = b
+			case "absolute":
+				cmd.Absolute = b
+			case "noArgs":
+				cmd.NoArgs = b
+			}
+		case "prepend":
+			cmd.Prepend, ok = value.(string)
+		case "cwd":
+			cmd.Cwd, ok = value.(string)
+			if ok && filepath.IsAbs(cmd.Cwd) {
+				return nil, fmt.Errorf("%s: must be a relative path", fieldPath(key))
+			}
+		case "timeout":
+			var s string
+			s, ok = value.(string)
+			if ok {
+				cmd.Timeout, err = time.ParseDuration(s)
+				if err != nil || cmd.Timeout < 0 {
+					return nil, fmt.Errorf("%s: invalid duration `%s` (e.g. 30s, 1m)", fieldPath(key), s)
+				}
+			}
+		case "env":
+			var env map[string]any
+			env, ok = value.(map[string]any)
+			if ok {
+				cmd.Env = make(map[string]string, len(env))
+				for name, envValue := range env {
+					s, isString := envValue.(string)
+					if !isString {
+						return nil, fmt.Errorf("%s: must be a string", config.FormatPath(append(path[:len(path):len(path)], key, name)))
+					}
+					cmd.Env[name] = s
+				}
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown option", fieldPath(key))
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("%s: invalid value type", fieldPath(key))
+		}
 	}
 
-	return nil, fmt.Errorf("invalid value type (must be string) for rule")
+	if cmd.Absolute && cmd.NoArgs {
+		return nil, fmt.Errorf("%s: cannot have both absolute and noArgs options", config.FormatPath(path))
+	}
+	if err := cmd.checkPlaceholders(); err != nil {
+		return nil, fmt.Errorf("%s: %w", config.FormatPath(path), err)
+	}
+
+	return cmd, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func parseStringCommand(cmd string) (*Command, error) {
@@ -311,6 +649,12 @@ func parseStringCommand(cmd string) (*Command, error) {
 			case strings.HasPrefix(cmd, "[noArgs]"):
 				result.NoArgs = true
 				cmd = strings.TrimPrefix(cmd, "[noArgs]")
+			case strings.HasPrefix(cmd, "[changedLinesOnly]"):
+				result.ChangedLinesOnly = true
+				cmd = strings.TrimPrefix(cmd, "[changedLinesOnly]")
+			case strings.HasPrefix(cmd, "[argfile]"):
+				result.ArgFile = true
+				cmd = strings.TrimPrefix(cmd, "[argfile]")
 			case strings.HasPrefix(cmd, "[prepend ") || strings.HasPrefix(cmd, "[prepend="):
 				// find next ]
 				i := strings.Index(cmd, "]")
@@ -349,9 +693,8 @@ func parseStringCommand(cmd string) (*Command, error) {
 			return nil, ee.Wrap(err, "cannot get current kitty's executable path")
 		}
 		result.execCommand = kitty + cmd[5:]
-	} else {
-		// TODO use .kitty/.bin/xxx as first priority
 	}
+	// the other commands run the tools in .kitty/.bin first, see toolResolver
 
 	result.Command = cmd
 
@@ -359,29 +702,72 @@ func parseStringCommand(cmd string) (*Command, error) {
 		result.execCommand = result.Command
 	}
 
+	result.parseLineRangeTemplate()
+	if err := result.parsePlaceholders(); err != nil {
+		return nil, fmt.Errorf("command `%s`: %w", cmdIn, err)
+	}
+	if err := result.checkPlaceholders(); err != nil {
+		return nil, fmt.Errorf("command `%s`: %w", cmdIn, err)
+	}
+
 	return result, nil
 }
 
-// groupFilesByConfig map file to specific (deepest level) config
-func groupFilesByConfig(configs []*Config, files Files) map[*Config]Files {
+// groupFilesByConfig map file to specific (deepest level) config,
+// and the files outside the directory of a config to it as well if its patterns starting with ../ match them
+//
+// the files in a submodule (relative to gitDir) only use the configs in the same submodule
+func groupFilesByConfig(configs []*Config, files Files, gitDir string, submodules []string) map[*Config]Files {
 	group := make(map[*Config]Files, len(configs))
-	if len(configs) == 1 {
+	if len(configs) == 1 && len(submodules) == 0 {
 		group[configs[0]] = files
 		return group
 	}
 
-	absolutePathMap := make(map[string]*File, len(files))
-	for _, file := range files {
-		absolutePathMap[file.AbsolutePath()] = file
+	submoduleOfPath := func(p string) string {
+		rel, err := filepath.Rel(gitDir, p)
+		if err != nil {
+			return ""
+		}
+		return submoduleOf(submodules, filepath.ToSlash(rel))
 	}
 
+	// the files keep their order in each group
+	assigned := make(map[*File]bool, len(files))
+
 	for _, config := range configs {
 		d := filepath.Dir(config.Path) + string(filepath.Separator)
 
-		for absolutePath, file := range absolutePathMap {
-			if strings.HasPrefix(absolutePath, d) {
+		submodule := submoduleOfPath(config.Path)
+
+		for _, file := range files {
+			if !assigned[file] && strings.HasPrefix(file.AbsolutePath(), d) && submoduleOf(submodules, file.GitRelativePath()) == submodule {
 				group[config] = append(group[config], file)
-				delete(absolutePathMap, absolutePath)
+				assigned[file] = true
+			}
+		}
+	}
+
+	for _, config := range configs {
+		wd := filepath.Dir(config.Path)
+		d := wd + string(filepath.Separator)
+		submodule := submoduleOfPath(config.Path)
+
+		parentRules := mr.Filter(config.Rules, func(rule *Rule, index int) bool { return rule.Glob.IsParentPattern() })
+		if len(parentRules) == 0 {
+			continue
+		}
+
+		for _, file := range files {
+			if strings.HasPrefix(file.AbsolutePath(), d) || submoduleOf(submodules, file.GitRelativePath()) != submodule {
+				continue
+			}
+
+			for _, rule := range parentRules {
+				if rule.matches(wd, file) {
+					group[config] = append(group[config], file)
+					break
+				}
 			}
 		}
 	}
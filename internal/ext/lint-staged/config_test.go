@@ -0,0 +1,245 @@
+package lintstaged
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfigFormats(t *testing.T) {
+	testCases := map[string]string{
+		".lintstagedrc":      `{"*.go": "gofmt -l", "*.md": ["prettier --check"]}`,
+		".lintstagedrc.json": `{"files": {"*.go": "gofmt -l", "*.md": ["prettier --check"]}}`,
+		".lintstagedrc.yaml": `
+# gofmt only lists files here
+"*.go": gofmt -l
+"*.md":
+  - prettier --check
+`,
+		".lintstagedrc.yml": `
+files:
+  "*.go": gofmt -l
+  "*.md": [prettier --check]
+`,
+		".kittyrc.yaml": `
+lint-staged:
+  "*.go": gofmt -l
+  "*.md": [prettier --check]
+`,
+		"kitty.config.toml": `
+[lint-staged]
+"*.go" = "gofmt -l"
+"*.md" = ["prettier --check"]
+`,
+	}
+
+	for name, content := range testCases {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+
+			c, err := loadConfig(filename)
+			require.NoError(t, err)
+			require.NotNil(t, c)
+			require.Len(t, c.Rules, 2)
+
+			assert.Equal(t, "*.go", c.Rules[0].GlobString)
+			assert.Equal(t, "gofmt -l", c.Rules[0].Commands[0].Command)
+			assert.Equal(t, "*.md", c.Rules[1].GlobString)
+			assert.Equal(t, "prettier --check", c.Rules[1].Commands[0].Command)
+		})
+	}
+}
+
+func TestLoadConfigObjectCommands(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".lintstagedrc.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{
+  "*.js": {"run": "eslint --fix", "absolute": true, "timeout": "30s", "env": {"DEBUG": "1"}, "cwd": "..", "title": "ESLint"},
+  "*.go": ["gofmt -l", {"run": "[dir] go vet", "prepend": "--pkg"}, {"run": "[absolute][changedLinesOnly] golint", "absolute": false}]
+}`), 0644))
+
+	c, err := loadConfig(filename)
+	require.NoError(t, err)
+	require.Len(t, c.Rules, 2)
+
+	goCommands := c.Rules[0].Commands
+	require.Len(t, goCommands, 3)
+	assert.Equal(t, "gofmt -l", goCommands[0].Command)
+	assert.Equal(t, "go vet", goCommands[1].Command)
+	assert.True(t, goCommands[1].Dir)
+	assert.Equal(t, "--pkg", goCommands[1].Prepend)
+	assert.False(t, goCommands[2].Absolute, "the option overrides the prefix")
+	assert.True(t, goCommands[2].ChangedLinesOnly)
+
+	js := c.Rules[1].Commands[0]
+	assert.Equal(t, "eslint --fix", js.Command)
+	assert.Equal(t, "ESLint", js.Title)
+	assert.True(t, js.Absolute)
+	assert.Equal(t, 30*time.Second, js.Timeout)
+	assert.Equal(t, map[string]string{"DEBUG": "1"}, js.Env)
+	assert.Equal(t, "..", js.Cwd)
+}
+
+func TestLoadConfigRuleFilter(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".lintstagedrc.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{
+  "*": {"filter": {"shebang": "^#!.*\\bsh\\b", "language": "Shell", "mime": ["text/*"], "maxSize": "1.5KB", "excludeGenerated": true}, "commands": ["shellcheck", "shfmt -w"]},
+  "*.go": {"commands": "gofmt -l"}
+}`), 0644))
+
+	c, err := loadConfig(filename)
+	require.NoError(t, err)
+	require.Len(t, c.Rules, 2)
+
+	f := c.Rules[0].Filter
+	require.NotNil(t, f)
+	assert.Equal(t, `^#!.*\bsh\b`, f.Shebang.String())
+	assert.Equal(t, []string{"Shell"}, f.Languages)
+	assert.Equal(t, []string{"text/*"}, f.MimeTypes)
+	assert.Equal(t, int64(1536), f.MaxSize)
+	assert.True(t, f.ExcludeGenerated)
+	assert.Len(t, c.Rules[0].Commands, 2)
+
+	assert.Nil(t, c.Rules[1].Filter)
+	assert.Equal(t, "gofmt -l", c.Rules[1].Commands[0].Command)
+}
+
+func TestLoadConfigRuleOn(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".lintstagedrc.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{
+  "migrations/*": {"on": ["deleted", "renamed"], "commands": "regenerate-index"},
+  "api/*": {"on": "deleted", "commands": "false"},
+  "*.go": "gofmt -l"
+}`), 0644))
+
+	c, err := loadConfig(filename)
+	require.NoError(t, err)
+	require.Len(t, c.Rules, 3)
+
+	rules := make(map[string]*Rule)
+	for _, rule := range c.Rules {
+		rules[rule.GlobString] = rule
+	}
+
+	assert.Equal(t, []FileStatus{FileStatusDeleted, FileStatusRenamed}, rules["migrations/*"].On)
+	assert.True(t, rules["migrations/*"].runsOn(FileStatusRenamed))
+	assert.False(t, rules["migrations/*"].runsOn(FileStatusModified))
+	assert.Equal(t, []FileStatus{FileStatusDeleted}, rules["api/*"].On)
+
+	assert.Nil(t, rules["*.go"].On)
+	assert.True(t, rules["*.go"].runsOn(FileStatusUnchanged))
+	assert.True(t, rules["*.go"].runsOn(FileStatusRenamed))
+	assert.False(t, rules["*.go"].runsOn(FileStatusDeleted))
+
+	assert.True(t, hasRulesOn([]*Config{c}, FileStatusDeleted))
+	assert.False(t, hasRulesOn([]*Config{c}, FileStatusAdded))
+}
+
+func TestLoadConfigRuleWorkspace(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), ".lintstagedrc.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{
+  "*.go": {"workspace": true, "commands": "[noArgs] go vet ./..."},
+  "*.py": {"workspace": ["pyproject.toml", "setup.py"], "commands": "ruff check"},
+  "*.md": {"workspace": false, "commands": "markdownlint"}
+}`), 0644))
+
+	c, err := loadConfig(filename)
+	require.NoError(t, err)
+
+	rules := make(map[string]*Rule)
+	for _, rule := range c.Rules {
+		rules[rule.GlobString] = rule
+	}
+
+	assert.Equal(t, []string{"go.mod", "package.json", "Cargo.toml"}, rules["*.go"].Workspace)
+	assert.Equal(t, []string{"pyproject.toml", "setup.py"}, rules["*.py"].Workspace)
+	assert.Nil(t, rules["*.md"].Workspace)
+}
+
+func TestLoadConfigInherit(t *testing.T) {
+	dir := t.TempDir()
+
+	filename := filepath.Join(dir, ".lintstagedrc.json")
+	require.NoError(t, os.WriteFile(filename, []byte(`{"inherit": true, "files": {"*.go": []}}`), 0644))
+	c, err := loadConfig(filename)
+	require.NoError(t, err)
+	assert.True(t, c.Inherit)
+	require.Len(t, c.Rules, 1)
+	assert.Empty(t, c.Rules[0].Commands)
+
+	// a pattern in the short format
+	require.NoError(t, os.WriteFile(filename, []byte(`{"inherit": "echo"}`), 0644))
+	c, err = loadConfig(filename)
+	require.NoError(t, err)
+	assert.False(t, c.Inherit)
+	assert.Equal(t, "inherit", c.Rules[0].GlobString)
+
+	require.NoError(t, os.WriteFile(filename, []byte(`{"inherit": "yes", "files": {"*.go": "gofmt -l"}}`), 0644))
+	_, err = loadConfig(filename)
+	assert.EqualError(t, err, "inherit: must be a boolean")
+}
+
+func TestLoadConfigRuleFilterErrors(t *testing.T) {
+	testCases := map[string]string{
+		`{"*": {"commands": "x", "filter": {"shebang": "("}}}`:     "[\"*\"].filter.shebang: invalid regular expression: error parsing regexp: missing closing ): `(`",
+		`{"*": {"commands": "x", "filter": {"maxSize": "big"}}}`:   "[\"*\"].filter.maxSize: invalid size `big` (e.g. 100KB, 1MB)",
+		`{"*": {"commands": "x", "filter": {"language": [1]}}}`:    `["*"].filter.language: must be a string or a list of strings`,
+		`{"*": {"commands": "x", "filter": {"generated": false}}}`: `["*"].filter.generated: unknown filter`,
+		`{"*": {"commands": "x", "filter": "sh"}}`:                 `["*"].filter: must be an object`,
+		`{"*": {"commands": "x", "on": ["moved"]}}`:                `["*"].on: unknown status "moved" (must be one of: added, modified, renamed, deleted)`,
+		`{"*": {"commands": "x", "on": []}}`:                       `["*"].on: empty list`,
+		`{"*": {"commands": "x", "workspace": 1}}`:                 `["*"].workspace: must be a boolean, a string or a list of strings`,
+		`{"*": {"commands": "x", "workspace": "a/go.mod"}}`:        "[\"*\"].workspace: invalid marker file `a/go.mod` (must be a file name)",
+		`{"*": {"commands": ["x", 1]}}`:                            `["*"].commands[1]: invalid value type (must be string, object or a list of them) for command`,
+		`{"*": {"commands": "x", "run": "y"}}`:                     `["*"].run: unknown option`,
+	}
+
+	for content, expected := range testCases {
+		t.Run(expected, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), ".lintstagedrc.json")
+			require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+
+			_, err := loadConfig(filename)
+			require.Error(t, err)
+			assert.Equal(t, expected, err.Error())
+		})
+	}
+}
+
+func TestLoadConfigObjectCommandErrors(t *testing.T) {
+	testCases := map[string]string{
+		`{"*.go": {"absolute": true}}`:                                   `["*.go"].run: required and must be a string`,
+		`{"*.go": ["gofmt -l", {"run": "go vet", "timeout": "soon"}]}`:   "[\"*.go\"][1].timeout: invalid duration `soon` (e.g. 30s, 1m)",
+		`{"*.go": {"run": "go vet", "env": {"A": 1}}}`:                   `["*.go"].env.A: must be a string`,
+		`{"*.go": {"run": "go vet", "shell": "bash"}}`:                   `["*.go"].shell: unknown option`,
+		`{"*.go": {"run": "go vet", "dir": "yes"}}`:                      `["*.go"].dir: invalid value type`,
+		`{"*.go": {"run": "go test", "absolute": true, "noArgs": true}}`: `["*.go"]: cannot have both absolute and noArgs options`,
+		`{"*.go": {"run": "go vet {pkgs}", "noArgs": true}}`:             `["*.go"]: placeholders of the files cannot be used with noArgs option`,
+		`{"*.go": {"run": "gofmt -l {file}", "argfile": true}}`:          "[\"*.go\"]: argfile cannot be used with `{file}`, `{start}` or `{end}`",
+		`{"*.go": [1]}`: `["*.go"][0]: invalid value type (must be string, object or a list of them) for command`,
+	}
+
+	for content, expected := range testCases {
+		t.Run(expected, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), ".lintstagedrc.json")
+			require.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+
+			_, err := loadConfig(filename)
+			require.Error(t, err)
+			assert.Equal(t, expected, err.Error())
+		})
+	}
+
+	t.Run("kitty config", func(t *testing.T) {
+		filename := filepath.Join(t.TempDir(), ".kittyrc.json")
+		require.NoError(t, os.WriteFile(filename, []byte(`{"lint-staged": {"*.go": {"run": "[foo] go vet"}}}`), 0644))
+
+		_, err := loadConfig(filename)
+		require.Error(t, err)
+		assert.Equal(t, "lint-staged[\"*.go\"].run: command `[foo] go vet` contains unknown options", err.Error())
+	})
+}
@@ -6,6 +6,19 @@ import (
 	"github.com/ImSingee/go-ex/mr"
 )
 
+// FileStatus is how the selected file is changed
+type FileStatus string
+
+const (
+	FileStatusUnchanged FileStatus = "" // selected by --status all without changes
+	FileStatusAdded     FileStatus = "added"
+	FileStatusModified  FileStatus = "modified"
+	FileStatusRenamed   FileStatus = "renamed"
+	FileStatusDeleted   FileStatus = "deleted" // only passed to the rules running on the deleted files, see Rule.On
+)
+
+var fileStatuses = []FileStatus{FileStatusAdded, FileStatusModified, FileStatusRenamed, FileStatusDeleted}
+
 // File
 //
 // it's underlying data is the path relative to the git root
@@ -13,6 +26,9 @@ type File struct {
 	gitRelativePath       string
 	relativePathToGitRoot string
 	absolutePath          string
+
+	status      FileStatus
+	renamedFrom *File // the source of the rename, nil if not renamed
 }
 
 func NewFile(ctx *State, gitRelativePath string) *File {
@@ -38,6 +54,15 @@ func (f File) AbsolutePath() string {
 	return f.absolutePath
 }
 
+func (f File) Status() FileStatus {
+	return f.status
+}
+
+// RenamedFrom returns the source of the rename, or nil if the file is not renamed
+func (f File) RenamedFrom() *File {
+	return f.renamedFrom
+}
+
 // Files is a slice of File
 type Files []*File
 
@@ -57,8 +82,30 @@ func (files Files) AbsolutePaths() []string {
 	})
 }
 
+// RenameSources returns the sources of the renamed files, and the other files as is
+func (files Files) RenameSources() Files {
+	return mr.Map(files, func(in *File, _index int) *File {
+		if in.renamedFrom != nil {
+			return in.renamedFrom
+		}
+		return in
+	})
+}
+
 func NewFiles(ctx *State, relativePathsToGitRoot []string) Files {
 	return Files(mr.Map(relativePathsToGitRoot, func(in string, _index int) *File {
 		return NewFile(ctx, in)
 	}))
 }
+
+// newSelectedFiles returns the files with their statuses from the selection
+func newSelectedFiles(ctx *State, selected []selectedFile) Files {
+	return Files(mr.Map(selected, func(in selectedFile, _index int) *File {
+		file := NewFile(ctx, in.path)
+		file.status = in.status
+		if in.from != "" {
+			file.renamedFrom = NewFile(ctx, in.from)
+		}
+		return file
+	}))
+}
@@ -0,0 +1,463 @@
+package lintstaged
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ImSingee/go-ex/mr"
+	"github.com/ImSingee/go-ex/pp"
+
+	"github.com/ImSingee/kitty/internal/config"
+	"github.com/ImSingee/kitty/internal/lib/git"
+)
+
+// Filter selects the files of a rule by their content, in addition to the glob, e.g.
+//
+//	{"*": {"filter": {"shebang": "^#!.*\\b(ba)?sh\\b", "maxSize": "1MB"}, "commands": "shellcheck"}}
+type Filter struct {
+	Shebang          *regexp.Regexp // matches the first line of the files starting with #!
+	Languages        []string       // see detectLanguage, case-insensitive
+	MimeTypes        []string       // e.g. text/plain, or text/* for all text types
+	MaxSize          int64          // in bytes, 0 means no limit
+	ExcludeGenerated bool           // exclude the files with a generated header or the linguist-generated attribute
+}
+
+func (f *Filter) match(facts *fileFacts) bool {
+	if facts.err != nil {
+		return false
+	}
+	if f.MaxSize > 0 && facts.size > f.MaxSize {
+		return false
+	}
+	if f.ExcludeGenerated && facts.generated {
+		return false
+	}
+	if f.Shebang != nil && (facts.shebang == "" || !f.Shebang.MatchString(facts.shebang)) {
+		return false
+	}
+	if len(f.Languages) != 0 && !slices.ContainsFunc(f.Languages, func(l string) bool { return strings.EqualFold(l, facts.language) }) {
+		return false
+	}
+	if len(f.MimeTypes) != 0 && !slices.ContainsFunc(f.MimeTypes, func(m string) bool { return matchMimeType(m, facts.mimeType) }) {
+		return false
+	}
+	return true
+}
+
+// parseFilter parses the filter of a rule in object form, path is the path of the filter in the config file
+func parseFilter(path []any, v any) (*Filter, error) {
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: must be an object", config.FormatPath(path))
+	}
+
+	fieldPath := func(key string) string {
+		return config.FormatPath(append(path[:len(path):len(path)], key))
+	}
+
+	f := &Filter{}
+	for _, key := range sortedKeys(m) {
+		value := m[key]
+
+		switch key {
+		case "shebang":
+			s, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a string", fieldPath(key))
+			}
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid regular expression: %w", fieldPath(key), err)
+			}
+			f.Shebang = re
+		case "language", "mime":
+			list, err := stringOrList(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", fieldPath(key), err)
+			}
+			if key == "language" {
+				f.Languages = list
+			} else {
+				f.MimeTypes = list
+			}
+		case "maxSize":
+			size, err := parseSize(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", fieldPath(key), err)
+			}
+			f.MaxSize = size
+		case "excludeGenerated":
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%s: must be a boolean", fieldPath(key))
+			}
+			f.ExcludeGenerated = b
+		default:
+			return nil, fmt.Errorf("%s: unknown filter", fieldPath(key))
+		}
+	}
+
+	return f, nil
+}
+
+func stringOrList(v any) ([]string, error) {
+	switch vv := v.(type) {
+	case string:
+		return []string{vv}, nil
+	case []any:
+		result := make([]string, 0, len(vv))
+		for _, item := range vv {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("must be a string or a list of strings")
+			}
+			result = append(result, s)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("must be a string or a list of strings")
+	}
+}
+
+var sizeRegexp = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([KMG]?)(?:I?B)?$`)
+
+// parseSize parses the size in bytes (a number) or with a unit, e.g. 100KB, 1.5MB (1KB = 1024B)
+func parseSize(v any) (int64, error) {
+	switch vv := v.(type) {
+	case float64:
+		if vv < 0 || vv != float64(int64(vv)) {
+			return 0, fmt.Errorf("must be a non-negative integer")
+		}
+		return int64(vv), nil
+	case string:
+		m := sizeRegexp.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(vv)))
+		if m == nil {
+			return 0, fmt.Errorf("invalid size `%s` (e.g. 100KB, 1MB)", vv)
+		}
+		n, _ := strconv.ParseFloat(m[1], 64)
+		switch m[2] {
+		case "K":
+			n *= 1 << 10
+		case "M":
+			n *= 1 << 20
+		case "G":
+			n *= 1 << 30
+		}
+		return int64(n), nil
+	default:
+		return 0, fmt.Errorf("must be a number or a string")
+	}
+}
+
+// matchMimeType reports whether the mime type matches the pattern like text/plain or text/*
+func matchMimeType(pattern, mimeType string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(mimeType, prefix+"/")
+	}
+	return strings.EqualFold(pattern, mimeType)
+}
+
+// factsHeadSize is the size of the beginning of a file read to detect its facts
+const factsHeadSize = 8 << 10
+
+// generatedHeaderRegexp matches the header of generated files, see https://go.dev/s/generatedcode
+var generatedHeaderRegexp = regexp.MustCompile(`(?m)^// Code generated .* DO NOT EDIT\.\r?$`)
+
+// fileFacts is what the filters need to know about a file
+type fileFacts struct {
+	size      int64
+	shebang   string // the first line if it starts with #!
+	language  string
+	mimeType  string
+	generated bool // has a generated header or the linguist-generated attribute
+	err       error
+}
+
+// factsCache reads the facts of the files on demand, every file is read at most once
+type factsCache struct {
+	gitRoot      string
+	submodules   []string // see --recurse-submodules
+	maxArgLength int
+	fromIndex    bool // read the staged content of the files with unstaged changes, for the staged selection
+
+	mu       sync.Mutex
+	facts    map[string]*fileFacts // by git relative path
+	unstaged map[string]bool       // the files with unstaged changes, loaded on demand if fromIndex
+}
+
+func newFactsCache(gitRoot string, submodules []string, maxArgLength int, fromIndex bool) *factsCache {
+	return &factsCache{
+		gitRoot:      gitRoot,
+		submodules:   submodules,
+		maxArgLength: maxArgLength,
+		fromIndex:    fromIndex,
+		facts:        make(map[string]*fileFacts),
+	}
+}
+
+// filter returns the files matching the filter
+func (c *factsCache) filter(f *Filter, files Files) Files {
+	facts := c.get(files)
+
+	return mr.Filter(files, func(in *File, _ int) bool {
+		return f.match(facts[in.GitRelativePath()])
+	})
+}
+
+// get returns the facts of the files, keyed by their git relative paths
+func (c *factsCache) get(files Files) map[string]*fileFacts {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var missing Files
+	for _, file := range files {
+		if _, ok := c.facts[file.GitRelativePath()]; !ok {
+			missing = append(missing, file)
+		}
+	}
+
+	if len(missing) != 0 {
+		if c.fromIndex && c.unstaged == nil {
+			unstaged, err := c.unstagedFiles()
+			if err != nil {
+				pp.EYellowPrintf("%s Cannot get the files with unstaged changes, their working tree content is used for the filters (%s)\n", warning, err.Error())
+			}
+			c.unstaged = unstaged
+		}
+
+		for _, file := range missing {
+			if c.unstaged[file.GitRelativePath()] {
+				c.facts[file.GitRelativePath()] = c.readStagedFileFacts(file.GitRelativePath())
+			} else {
+				c.facts[file.GitRelativePath()] = readFileFacts(file.AbsolutePath())
+			}
+		}
+
+		generated, err := c.linguistGenerated(missing.GitRelativePaths())
+		if err != nil {
+			pp.EYellowPrintf("%s Cannot check the linguist-generated attributes (%s)\n", warning, err.Error())
+		}
+		for _, p := range generated {
+			if facts, ok := c.facts[p]; ok {
+				facts.generated = true
+			}
+		}
+	}
+
+	result := make(map[string]*fileFacts, len(files))
+	for _, file := range files {
+		result[file.GitRelativePath()] = c.facts[file.GitRelativePath()]
+	}
+	return result
+}
+
+// unstagedFiles returns the files (git relative paths) whose working tree content differs from the index
+func (c *factsCache) unstagedFiles() (map[string]bool, error) {
+	result := make(map[string]bool)
+
+	for _, submodule := range append([]string{""}, c.submodules...) {
+		output, err := execGit([]string{"diff", "--name-only", "-z", "--ignore-submodules"}, filepath.Join(c.gitRoot, submodule))
+		if err != nil {
+			return result, err
+		}
+
+		for _, p := range strings.Split(output, "\x00") {
+			if p != "" {
+				result[path.Join(submodule, p)] = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// readStagedFileFacts reads the facts of the content of the file in the index
+func (c *factsCache) readStagedFileFacts(p string) *fileFacts {
+	submodule := submoduleOf(c.submodules, p)
+	rel := strings.TrimPrefix(strings.TrimPrefix(p, submodule), "/")
+
+	result := git.R(filepath.Join(c.gitRoot, submodule), []string{"cat-file", "blob", ":" + rel})
+	if err := result.Err(); err != nil {
+		return &fileFacts{err: err}
+	}
+
+	head := result.Output
+	if len(head) > factsHeadSize {
+		head = head[:factsHeadSize]
+	}
+	return newFileFacts(p, int64(len(result.Output)), head)
+}
+
+// linguistGenerated returns the files with the linguist-generated attribute set in .gitattributes
+func (c *factsCache) linguistGenerated(paths []string) ([]string, error) {
+	var result []string
+
+	// the attributes of the files in a submodule are read from the submodule
+	groups := splitBySubmodule(c.submodules, paths)
+	for _, submodule := range sortedKeys(groups) {
+		for _, chunk := range chunkFiles(groups[submodule], c.maxArgLength) {
+			output, err := execGit(append([]string{"check-attr", "-z", "linguist-generated", "--"}, chunk...), filepath.Join(c.gitRoot, submodule))
+			if err != nil {
+				return nil, err
+			}
+
+			// the output is <path> NUL <attribute> NUL <value> NUL ...
+			parts := strings.Split(output, "\x00")
+			for i := 0; i+2 < len(parts); i += 3 {
+				if value := parts[i+2]; value == "set" || value == "true" {
+					result = append(result, path.Join(submodule, parts[i]))
+				}
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func readFileFacts(filename string) *fileFacts {
+	file, err := os.Open(filename)
+	if err != nil {
+		return &fileFacts{err: err}
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return &fileFacts{err: err}
+	}
+
+	head := make([]byte, factsHeadSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return &fileFacts{err: err}
+	}
+
+	return newFileFacts(filename, stat.Size(), head[:n])
+}
+
+// newFileFacts returns the facts of the file by its size and the beginning of its content (up to factsHeadSize)
+func newFileFacts(filename string, size int64, head []byte) *fileFacts {
+	facts := &fileFacts{size: size}
+
+	if bytes.HasPrefix(head, []byte("#!")) {
+		line, _, _ := bytes.Cut(head, []byte("\n"))
+		facts.shebang = strings.TrimSpace(string(line))
+	}
+	facts.generated = generatedHeaderRegexp.Match(head)
+	facts.language = detectLanguage(filepath.Base(filename), facts.shebang)
+	facts.mimeType = detectMimeType(filename, head)
+
+	return facts
+}
+
+// detectMimeType detects the mime type by the extension, or by the content if the extension is unknown
+func detectMimeType(filename string, head []byte) string {
+	t := mime.TypeByExtension(filepath.Ext(filename))
+	if t == "" {
+		t = http.DetectContentType(head)
+	}
+
+	t, _, _ = strings.Cut(t, ";")
+	return strings.TrimSpace(t)
+}
+
+var languageByExtension = map[string]string{
+	".c":     "C",
+	".h":     "C",
+	".cc":    "C++",
+	".cpp":   "C++",
+	".hpp":   "C++",
+	".cs":    "C#",
+	".css":   "CSS",
+	".scss":  "SCSS",
+	".less":  "Less",
+	".go":    "Go",
+	".html":  "HTML",
+	".htm":   "HTML",
+	".java":  "Java",
+	".kt":    "Kotlin",
+	".js":    "JavaScript",
+	".cjs":   "JavaScript",
+	".mjs":   "JavaScript",
+	".jsx":   "JavaScript",
+	".ts":    "TypeScript",
+	".cts":   "TypeScript",
+	".mts":   "TypeScript",
+	".tsx":   "TypeScript",
+	".json":  "JSON",
+	".lua":   "Lua",
+	".md":    "Markdown",
+	".php":   "PHP",
+	".pl":    "Perl",
+	".proto": "Protocol Buffer",
+	".py":    "Python",
+	".rb":    "Ruby",
+	".rs":    "Rust",
+	".sh":    "Shell",
+	".bash":  "Shell",
+	".zsh":   "Shell",
+	".sql":   "SQL",
+	".swift": "Swift",
+	".toml":  "TOML",
+	".vue":   "Vue",
+	".xml":   "XML",
+	".yaml":  "YAML",
+	".yml":   "YAML",
+}
+
+var languageByFilename = map[string]string{
+	"Dockerfile":  "Dockerfile",
+	"Makefile":    "Makefile",
+	"GNUmakefile": "Makefile",
+	"go.mod":      "Go Module",
+}
+
+var languageByInterpreter = map[string]string{
+	"sh":      "Shell",
+	"bash":    "Shell",
+	"zsh":     "Shell",
+	"dash":    "Shell",
+	"ksh":     "Shell",
+	"node":    "JavaScript",
+	"deno":    "TypeScript",
+	"python":  "Python",
+	"python2": "Python",
+	"python3": "Python",
+	"ruby":    "Ruby",
+	"perl":    "Perl",
+	"php":     "PHP",
+	"lua":     "Lua",
+}
+
+// detectLanguage detects the language by the file name, or by the interpreter of the shebang
+func detectLanguage(name string, shebang string) string {
+	if l, ok := languageByFilename[name]; ok {
+		return l
+	}
+	if l, ok := languageByExtension[strings.ToLower(filepath.Ext(name))]; ok {
+		return l
+	}
+
+	// #!/bin/sh, #!/usr/bin/env bash, #!/usr/bin/env -S node --flag
+	fields := strings.Fields(strings.TrimPrefix(shebang, "#!"))
+	for i, field := range fields {
+		interpreter := path.Base(field)
+		if i == 0 && interpreter == "env" || strings.HasPrefix(field, "-") {
+			continue
+		}
+		return languageByInterpreter[interpreter]
+	}
+
+	return ""
+}
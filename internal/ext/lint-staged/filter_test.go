@@ -0,0 +1,98 @@
+package lintstaged
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFactsCacheFilter(t *testing.T) {
+	repo := newTestRepo(t)
+
+	writeFile(t, repo, ".gitattributes", "vendor/** linguist-generated\n")
+	writeFile(t, repo, "bin/deploy", "#!/usr/bin/env bash\necho deploy\n")
+	writeFile(t, repo, "bin/serve", "#!/usr/bin/env node\nconsole.log(1)\n")
+	writeFile(t, repo, "main.go", "package main\n")
+	writeFile(t, repo, "main_gen.go", "// Code generated by stringer. DO NOT EDIT.\n\npackage main\n")
+	writeFile(t, repo, "crlf_gen.go", "// Code generated by protoc. DO NOT EDIT.\r\n\r\npackage main\r\n")
+	writeFile(t, repo, "vendor/lib.go", "package lib\n")
+	writeFile(t, repo, "big.txt", strings.Repeat("x", 2048))
+	writeFile(t, repo, "logo.png", "\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+
+	ctx := &State{gitRoot: repo}
+	files := NewFiles(ctx, []string{"bin/deploy", "bin/serve", "main.go", "main_gen.go", "crlf_gen.go", "vendor/lib.go", "big.txt", "logo.png", "missing.txt"})
+	c := newFactsCache(repo, nil, defaultMaxArgLength(), false)
+
+	filter := func(f *Filter) []string {
+		return c.filter(f, files).GitRelativePaths()
+	}
+
+	assert.Equal(t, []string{"bin/deploy"}, filter(&Filter{Shebang: regexp.MustCompile(`\bbash\b`)}))
+	assert.Equal(t, []string{"bin/deploy"}, filter(&Filter{Languages: []string{"shell"}}))
+	assert.Equal(t, []string{"bin/serve"}, filter(&Filter{Languages: []string{"JavaScript"}}))
+	assert.Equal(t, []string{"main.go"}, filter(&Filter{Languages: []string{"Go"}, ExcludeGenerated: true}))
+	assert.Equal(t, []string{"logo.png"}, filter(&Filter{MimeTypes: []string{"image/*"}}))
+	assert.NotContains(t, filter(&Filter{MaxSize: 1024}), "big.txt")
+	assert.Contains(t, filter(&Filter{MaxSize: 2048}), "big.txt")
+	assert.NotContains(t, filter(&Filter{}), "missing.txt")
+
+	// the facts are read only once
+	writeFile(t, repo, "main.go", "// Code generated by hand. DO NOT EDIT.\n\npackage main\n")
+	assert.Equal(t, []string{"main.go"}, filter(&Filter{Languages: []string{"Go"}, ExcludeGenerated: true}))
+}
+
+func TestFactsCacheFilterFromIndex(t *testing.T) {
+	repo := newTestRepo(t)
+
+	writeFile(t, repo, "gen.go", "// Code generated by stringer. DO NOT EDIT.\n\npackage main\n")
+	writeFile(t, repo, "main.go", "package main\n")
+	gitRun(t, repo, "add", ".")
+	writeFile(t, repo, "gen.go", "package main\n") // the unstaged changes are ignored
+	writeFile(t, repo, "main.go", "// Code generated by hand. DO NOT EDIT.\n\npackage main\n")
+
+	ctx := &State{gitRoot: repo}
+	files := NewFiles(ctx, []string{"gen.go", "main.go"})
+
+	c := newFactsCache(repo, nil, defaultMaxArgLength(), true)
+	assert.Equal(t, []string{"main.go"}, c.filter(&Filter{ExcludeGenerated: true}, files).GitRelativePaths())
+
+	c = newFactsCache(repo, nil, defaultMaxArgLength(), false)
+	assert.Equal(t, []string{"gen.go"}, c.filter(&Filter{ExcludeGenerated: true}, files).GitRelativePaths())
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := map[any]int64{
+		100.0:    100,
+		"100":    100,
+		"100B":   100,
+		"2KB":    2048,
+		"1.5k":   1536,
+		"1MiB":   1 << 20,
+		"1 GB":   1 << 30,
+		"0.5 mb": 1 << 19,
+	}
+
+	for v, expected := range testCases {
+		size, err := parseSize(v)
+		require.NoError(t, err, v)
+		assert.Equal(t, expected, size, v)
+	}
+
+	for _, v := range []any{-1.0, 1.5, "big", "1TB", true} {
+		_, err := parseSize(v)
+		assert.Error(t, err, v)
+	}
+}
+
+func TestDetectLanguage(t *testing.T) {
+	assert.Equal(t, "Go", detectLanguage("main.go", ""))
+	assert.Equal(t, "TypeScript", detectLanguage("App.TSX", ""))
+	assert.Equal(t, "Makefile", detectLanguage("Makefile", ""))
+	assert.Equal(t, "Shell", detectLanguage("deploy", "#!/bin/sh"))
+	assert.Equal(t, "Python", detectLanguage("manage", "#!/usr/bin/env python3"))
+	assert.Equal(t, "JavaScript", detectLanguage("cli", "#!/usr/bin/env -S node --no-warnings"))
+	assert.Equal(t, "", detectLanguage("README", ""))
+}
@@ -93,21 +93,23 @@ func getDiffCommand(diff, diffFilter string) []string {
 
 	// Docs for -z option:
 	// https://git-scm.com/docs/git-diff#Documentation/git-diff.txt--z
-	return append([]string{"diff", "--name-only", "-z", "--diff-filter=" + diffFilter}, diffArgs...)
+	return append([]string{"diff", "--name-status", "-z", "-M", "--diff-filter=" + diffFilter}, diffArgs...)
 }
 
 func normalizeDiffFilter(diffFilter string) string {
 	if diffFilter == "" {
 		// Docs for --diff-filter option:
 		// https://git-scm.com/docs/git-diff#Documentation/git-diff.txt---diff-filterACDMRTUXB82308203
-		diffFilter = "ACMR"
+		//
+		// the deleted files only go to the rules running on them
+		diffFilter = "ACMRD"
 	}
 
 	return diffFilter
 }
 
 func getStatusDiffCommand(diffFilter string, staged bool) []string {
-	args := []string{"diff", "--name-only", "-z", "--diff-filter=" + normalizeDiffFilter(diffFilter)}
+	args := []string{"diff", "--name-status", "-z", "-M", "--diff-filter=" + normalizeDiffFilter(diffFilter)}
 	if staged {
 		args = append(args, "--staged")
 	}
@@ -117,12 +119,20 @@ func getStatusDiffCommand(diffFilter string, staged bool) []string {
 	return args
 }
 
-func getSelectedFiles(options *Options, gitDir string) ([]string, error) {
-	var files []string
+// selectedFile is a file selected by git with how it's changed
+type selectedFile struct {
+	path   string // relative to the git root
+	status FileStatus
+	from   string // the source of the rename, relative to the git root
+}
+
+// getSelectedFiles returns the selected files, including the deleted ones (if the diff filter allows)
+func getSelectedFiles(options *Options, gitDir string) ([]selectedFile, error) {
+	var files []selectedFile
 	var err error
 
 	if options.Diff != "" {
-		files, err = execGitZ(getDiffCommand(options.Diff, options.DiffFilter), gitDir)
+		files, err = execGitNameStatus(getDiffCommand(options.Diff, options.DiffFilter), gitDir)
 	} else {
 		switch options.SelectionMode() {
 		case SelectionModeStaged:
@@ -135,17 +145,20 @@ func getSelectedFiles(options *Options, gitDir string) ([]string, error) {
 			files, err = getTrackedFiles(options.DiffFilter, gitDir)
 		case SelectionModeChanged:
 			files, err = getChangedFiles(options.DiffFilter, gitDir)
+		case SelectionModeSince:
+			files, err = getSinceFiles(options, gitDir)
 		case SelectionModeAll:
-			tracked, err := getCachedFiles(gitDir)
+			// the changed files keep their statuses
+			changed, err := getChangedFiles(options.DiffFilter, gitDir)
 			if err != nil {
 				return nil, err
 			}
-			untracked, err := getUntrackedFiles(gitDir)
+			cached, err := getCachedFiles(gitDir)
 			if err != nil {
 				return nil, err
 			}
 
-			files = uniqueStrings(append(tracked, untracked...))
+			files = uniqueFiles(append(changed, unchangedFiles(cached)...))
 		default:
 			return nil, fmt.Errorf("unsupported selection mode %q", options.SelectionMode())
 		}
@@ -157,31 +170,81 @@ func getSelectedFiles(options *Options, gitDir string) ([]string, error) {
 	return filterRegularFiles(files, gitDir), nil
 }
 
-func filterRegularFiles(files []string, gitDir string) []string {
-	return mr.Filter(files, func(file string, _ int) bool {
-		info, err := os.Lstat(filepath.Join(gitDir, file))
+// filterRegularFiles keeps the regular files and the deleted files
+func filterRegularFiles(files []selectedFile, gitDir string) []selectedFile {
+	return mr.Filter(files, func(file selectedFile, _ int) bool {
+		if file.status == FileStatusDeleted {
+			return true
+		}
+
+		info, err := os.Lstat(filepath.Join(gitDir, file.path))
 		return err == nil && info.Mode().IsRegular()
 	})
 }
 
+// selectedPaths returns the paths of the selected files which are not deleted
+func selectedPaths(files []selectedFile) []string {
+	var paths []string
+	for _, file := range files {
+		if file.status != FileStatusDeleted {
+			paths = append(paths, file.path)
+		}
+	}
+	return paths
+}
+
 // getStagedFiles returns a list of staged files in relative path to git root
-func getStagedFiles(diffFilter string, gitDir string) ([]string, error) {
-	lines, err := execGitZ(getStatusDiffCommand(diffFilter, true), gitDir)
+func getStagedFiles(diffFilter string, gitDir string) ([]selectedFile, error) {
+	return execGitNameStatus(getStatusDiffCommand(diffFilter, true), gitDir)
+}
+
+func getUnstagedFiles(diffFilter string, gitDir string) ([]selectedFile, error) {
+	return execGitNameStatus(getStatusDiffCommand(diffFilter, false), gitDir)
+}
+
+// execGitNameStatus runs the git diff command with --name-status -z and parses its output
+func execGitNameStatus(args []string, dir string) ([]selectedFile, error) {
+	fields, err := execGitZ(args, dir)
 	if err != nil {
 		return nil, err
 	}
 
-	return lines, nil
+	return parseNameStatus(fields), nil
 }
 
-func getUnstagedFiles(diffFilter string, gitDir string) ([]string, error) {
-	lines, err := execGitZ(getStatusDiffCommand(diffFilter, false), gitDir)
-	if err != nil {
-		return nil, err
+// parseNameStatus parses the fields of `git diff --name-status -z`, e.g. M, a.go, R100, b.go, c.go
+func parseNameStatus(fields []string) []selectedFile {
+	var files []selectedFile
+
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, path := fields[i], fields[i+1]
+		if status == "" {
+			break
+		}
+
+		switch status[0] {
+		case 'A':
+			files = append(files, selectedFile{path: path, status: FileStatusAdded})
+		case 'D':
+			files = append(files, selectedFile{path: path, status: FileStatusDeleted})
+		case 'R', 'C': // followed by the source and the destination
+			if i+2 >= len(fields) {
+				return files
+			}
+			file := selectedFile{path: fields[i+2], status: FileStatusAdded}
+			if status[0] == 'R' {
+				file.status, file.from = FileStatusRenamed, path
+			}
+			files = append(files, file)
+			i++
+		default: // M, T, U
+			files = append(files, selectedFile{path: path, status: FileStatusModified})
+		}
 	}
 
-	return lines, nil
+	return files
 }
+
 func parseGitZOutput(o string) []string {
 	o = strings.TrimSuffix(o, "\x00")
 
@@ -204,7 +267,7 @@ func getCachedFiles(gitDir string) ([]string, error) {
 	return execGitZ([]string{"ls-files", "-z", "--full-name"}, gitDir)
 }
 
-func getTrackedFiles(diffFilter string, gitDir string) ([]string, error) {
+func getTrackedFiles(diffFilter string, gitDir string) ([]selectedFile, error) {
 	staged, err := getStagedFiles(diffFilter, gitDir)
 	if err != nil {
 		return nil, err
@@ -215,10 +278,10 @@ func getTrackedFiles(diffFilter string, gitDir string) ([]string, error) {
 		return nil, err
 	}
 
-	return uniqueStrings(append(staged, unstaged...)), nil
+	return uniqueFiles(append(staged, unstaged...)), nil
 }
 
-func getChangedFiles(diffFilter string, gitDir string) ([]string, error) {
+func getChangedFiles(diffFilter string, gitDir string) ([]selectedFile, error) {
 	tracked, err := getTrackedFiles(diffFilter, gitDir)
 	if err != nil {
 		return nil, err
@@ -228,28 +291,42 @@ func getChangedFiles(diffFilter string, gitDir string) ([]string, error) {
 		return nil, err
 	}
 
-	return uniqueStrings(append(tracked, untracked...)), nil
+	return uniqueFiles(append(tracked, untracked...)), nil
 }
 
-func getUntrackedFiles(gitDir string) ([]string, error) {
-	return execGitZ([]string{"ls-files", "-z", "--full-name", "--others", "--exclude-standard"}, gitDir)
+func getUntrackedFiles(gitDir string) ([]selectedFile, error) {
+	paths, err := execGitZ([]string{"ls-files", "-z", "--full-name", "--others", "--exclude-standard"}, gitDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return mr.Map(paths, func(path string, _ int) selectedFile {
+		return selectedFile{path: path, status: FileStatusAdded}
+	}), nil
+}
+
+func unchangedFiles(paths []string) []selectedFile {
+	return mr.Map(paths, func(path string, _ int) selectedFile {
+		return selectedFile{path: path, status: FileStatusUnchanged}
+	})
 }
 
-func uniqueStrings(in []string) []string {
+// uniqueFiles removes the duplicated files, the first one of each path is kept
+func uniqueFiles(in []selectedFile) []selectedFile {
 	if len(in) == 0 {
 		return nil
 	}
 
 	seen := make(map[string]struct{}, len(in))
-	out := make([]string, 0, len(in))
+	out := make([]selectedFile, 0, len(in))
 	for _, item := range in {
-		if item == "" {
+		if item.path == "" {
 			continue
 		}
-		if _, ok := seen[item]; ok {
+		if _, ok := seen[item.path]; ok {
 			continue
 		}
-		seen[item] = struct{}{}
+		seen[item.path] = struct{}{}
 		out = append(out, item)
 	}
 
@@ -323,6 +323,20 @@ func removeFilesAndIgnoreError(files []string) {
 
 // Get name of backup stash
 func (g *gitWorkflow) getBackupStashIndex() (string, error) {
+	stash, err := g.findBackupStash()
+	if err != nil {
+		return "", err
+	}
+
+	if stash == "" {
+		return "", ee.New("miss lint-staged automatic backup")
+	}
+
+	return stash, nil
+}
+
+// findBackupStash returns the index of the latest backup stash, or empty if there isn't one
+func (g *gitWorkflow) findBackupStash() (string, error) {
 	stashes, err := g.execGitZ("stash", "list", "-z")
 	if err != nil {
 		return "", ee.Wrap(err, "cannot get stash list")
@@ -333,7 +347,7 @@ func (g *gitWorkflow) getBackupStashIndex() (string, error) {
 	})
 
 	if index == -1 {
-		return "", ee.New("miss lint-staged automatic backup")
+		return "", nil
 	}
 
 	return strconv.Itoa(index), nil
@@ -4,13 +4,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/ImSingee/go-ex/mr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
+func TestGetSelectedFilesMarksDeletedFilesForStatuses(t *testing.T) {
 	repo := t.TempDir()
 
 	gitRun(t, repo, "init")
@@ -36,6 +38,7 @@ func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
 		name     string
 		options  *Options
 		expected []string
+		deleted  []string
 	}{
 		{
 			name: "staged",
@@ -44,6 +47,7 @@ func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
 				DiffFilter: "ACMRD",
 			},
 			expected: []string{"staged.txt"},
+			deleted:  []string{"deleted-staged.txt"},
 		},
 		{
 			name: "unstaged",
@@ -52,6 +56,7 @@ func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
 				DiffFilter: "ACMRD",
 			},
 			expected: []string{"modified.txt"},
+			deleted:  []string{"deleted-unstaged.txt"},
 		},
 		{
 			name: "tracked",
@@ -60,6 +65,7 @@ func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
 				DiffFilter: "ACMRD",
 			},
 			expected: []string{"modified.txt", "staged.txt"},
+			deleted:  []string{"deleted-staged.txt", "deleted-unstaged.txt"},
 		},
 		{
 			name: "changed",
@@ -68,6 +74,7 @@ func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
 				DiffFilter: "ACMRD",
 			},
 			expected: []string{"modified.txt", "staged.txt", "untracked.txt"},
+			deleted:  []string{"deleted-staged.txt", "deleted-unstaged.txt"},
 		},
 		{
 			name: "all",
@@ -75,6 +82,7 @@ func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
 				Status: string(SelectionModeAll),
 			},
 			expected: []string{"clean.txt", "modified.txt", "staged.txt", "untracked.txt"},
+			deleted:  []string{"deleted-staged.txt", "deleted-unstaged.txt"},
 		},
 	}
 
@@ -82,13 +90,39 @@ func TestGetSelectedFilesExcludesDeletedFilesForStatuses(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			files, err := getSelectedFiles(tc.options, repo)
 			require.NoError(t, err)
-			assert.ElementsMatch(t, tc.expected, files)
-			assert.NotContains(t, files, "deleted-unstaged.txt")
-			assert.NotContains(t, files, "deleted-staged.txt")
+			assert.ElementsMatch(t, tc.expected, selectedPaths(files))
+
+			var deleted []string
+			for _, file := range files {
+				if file.status == FileStatusDeleted {
+					deleted = append(deleted, file.path)
+				}
+			}
+			assert.ElementsMatch(t, tc.deleted, deleted)
+
+			// the deleted files are not selected without D in the diff filter
+			tc.options.DiffFilter = "ACMR"
+			files, err = getSelectedFiles(tc.options, repo)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, mr.Map(files, func(in selectedFile, _ int) string { return in.path }))
 		})
 	}
 }
 
+func TestParseNameStatus(t *testing.T) {
+	fields := []string{"M", "a.go", "A", "b c.go", "R087", "old.go", "new.go", "C100", "src.go", "copy.go", "D", "gone.go", "T", "link"}
+
+	assert.Equal(t, []selectedFile{
+		{path: "a.go", status: FileStatusModified},
+		{path: "b c.go", status: FileStatusAdded},
+		{path: "new.go", status: FileStatusRenamed, from: "old.go"},
+		{path: "copy.go", status: FileStatusAdded},
+		{path: "gone.go", status: FileStatusDeleted},
+		{path: "link", status: FileStatusModified},
+	}, parseNameStatus(fields))
+	assert.Empty(t, parseNameStatus(nil))
+}
+
 func TestGetSelectedFilesExcludesSymlinksForStatuses(t *testing.T) {
 	repo := t.TempDir()
 
@@ -141,8 +175,9 @@ func TestGetSelectedFilesExcludesSymlinksForStatuses(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			files, err := getSelectedFiles(tc.options, repo)
+			selected, err := getSelectedFiles(tc.options, repo)
 			require.NoError(t, err)
+			files := selectedPaths(selected)
 			assert.ElementsMatch(t, tc.expected, files)
 			assert.NotContains(t, files, "staged-link.txt")
 			assert.NotContains(t, files, "tracked-link.txt")
@@ -151,6 +186,65 @@ func TestGetSelectedFilesExcludesSymlinksForStatuses(t *testing.T) {
 	}
 }
 
+func TestGetSelectedFilesSince(t *testing.T) {
+	origin := newTestRepo(t)
+	gitRun(t, origin, "checkout", "-b", "main")
+
+	writeFile(t, origin, "a.txt", "a\n")
+	writeFile(t, origin, "b.txt", "b\n")
+	gitRun(t, origin, "add", ".")
+	gitRun(t, origin, "commit", "-m", "initial")
+
+	gitRun(t, origin, "checkout", "-b", "feature")
+	gitRun(t, origin, "mv", "b.txt", "renamed.txt")
+	writeFile(t, origin, "c.txt", "c\n")
+	gitRun(t, origin, "add", ".")
+	gitRun(t, origin, "commit", "-m", "feature")
+
+	gitRun(t, origin, "checkout", "main")
+	for i := 0; i < 3; i++ { // diverged, and makes the shallow clone miss the merge base
+		writeFile(t, origin, "main.txt", strings.Repeat("main\n", i+1))
+		gitRun(t, origin, "add", ".")
+		gitRun(t, origin, "commit", "-m", "main")
+	}
+
+	t.Run("explicit ref", func(t *testing.T) {
+		gitRun(t, origin, "checkout", "feature")
+		t.Cleanup(func() { gitRun(t, origin, "checkout", "main") })
+		writeFile(t, origin, "a.txt", "a modified\n") // uncommitted changes are included
+		t.Cleanup(func() { gitRun(t, origin, "checkout", "a.txt") })
+
+		options := &Options{Since: "main"}
+		files, err := getSelectedFiles(options, origin)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []selectedFile{
+			{path: "a.txt", status: FileStatusModified},
+			{path: "c.txt", status: FileStatusAdded},
+			{path: "renamed.txt", status: FileStatusRenamed, from: "b.txt"},
+		}, files)
+		assert.Equal(t, "files changed since main", options.SelectedFilesLabel())
+
+		_, err = getSelectedFiles(&Options{Since: "unknown"}, origin)
+		require.ErrorContains(t, err, "cannot resolve `unknown`")
+	})
+
+	t.Run("auto in shallow clone", func(t *testing.T) {
+		clone := filepath.Join(t.TempDir(), "clone")
+		gitRun(t, origin, "clone", "--quiet", "--depth", "1", "--no-single-branch", "--branch", "feature", "file://"+origin, clone)
+		require.True(t, isShallowRepository(clone))
+
+		for _, env := range []string{"GITHUB_BASE_REF", "CI_MERGE_REQUEST_TARGET_BRANCH_NAME", "BITBUCKET_PR_DESTINATION_BRANCH"} {
+			t.Setenv(env, "")
+		}
+
+		options := &Options{Since: SinceAuto}
+		files, err := getSelectedFiles(options, clone)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"c.txt", "renamed.txt"}, selectedPaths(files))
+		assert.Equal(t, "files changed since origin/main", options.SelectedFilesLabel())
+	})
+}
+
 func gitRun(t *testing.T, dir string, args ...string) {
 	t.Helper()
 
@@ -160,9 +254,28 @@ func gitRun(t *testing.T, dir string, args ...string) {
 	require.NoError(t, err, string(output))
 }
 
+func gitOutput(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	return string(output)
+}
+
+func readFile(t *testing.T, dir string, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	return string(data)
+}
+
 func writeFile(t *testing.T, dir string, name string, content string) {
 	t.Helper()
 
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
 	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
 }
 
@@ -0,0 +1,111 @@
+package lintstaged
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+)
+
+// A config with "inherit": true also runs the rules of its parent config (the nearest one in the ancestor directories)
+// on its files, and the ones the parent inherits. The inherited rules keep matching and running relative to the directory
+// of the config they come from, and a rule overrides the inherited ones with the same pattern (an empty list of commands
+// disables them).
+
+// configRule is a rule with the config it comes from
+type configRule struct {
+	config *Config
+	rule   *Rule
+}
+
+// effectiveRules returns the rules of the config and the inherited ones
+func (c *Config) effectiveRules() []configRule {
+	rules := make([]configRule, 0, len(c.Rules))
+	patterns := make(map[string]bool, len(c.Rules))
+
+	for config := c; config != nil; config = config.Parent {
+		for _, rule := range config.Rules {
+			if patterns[rule.GlobString] { // overridden
+				continue
+			}
+			patterns[rule.GlobString] = true
+
+			rules = append(rules, configRule{config: config, rule: rule})
+		}
+	}
+
+	return rules
+}
+
+// inheritedFrom returns the path (relative to the git root) of the config of the rule if it's inherited by owner
+func (r *ruleTasks) inheritedFrom(state *State, owner *Config) string {
+	if r.config == owner {
+		return ""
+	}
+	return gitRelative(state, r.config.Path)
+}
+
+// linkParentConfigs sets the parents of the configs with inherit, candidates are all the config files deepest first,
+// the parents outside the searched directory are loaded as well
+func linkParentConfigs(configs []*Config, candidates []string, gitDir string, submodules []string) error {
+	loaded := make(map[string]*Config, len(configs))
+	for _, c := range configs {
+		loaded[c.Path] = c
+	}
+
+	submoduleOfPath := func(p string) string {
+		rel, err := filepath.Rel(gitDir, p)
+		if err != nil {
+			return ""
+		}
+		return submoduleOf(submodules, filepath.ToSlash(rel))
+	}
+
+	var link func(c *Config) error
+	link = func(c *Config) error {
+		if !c.Inherit || c.Parent != nil {
+			return nil
+		}
+
+		dir := filepath.Dir(c.Path)
+		submodule := submoduleOfPath(c.Path)
+
+		for _, candidate := range candidates { // deepest first, so the nearest ancestor is found first
+			candidateDir := filepath.Dir(candidate)
+			if candidateDir == dir || !strings.HasPrefix(dir, candidateDir+string(filepath.Separator)) || submoduleOfPath(candidate) != submodule {
+				continue
+			}
+
+			parent, ok := loaded[candidate]
+			if !ok {
+				var err error
+				parent, err = loadConfig(candidate)
+				if err != nil {
+					if errors.Is(err, fs.ErrNotExist) {
+						continue
+					}
+					return ee.Wrapf(err, "cannot load config file %s", candidate)
+				}
+				if parent == nil { // e.g. a kitty config without lint-staged
+					continue
+				}
+				parent.Path = candidate
+				loaded[candidate] = parent
+			}
+
+			c.Parent = parent
+			return link(parent)
+		}
+
+		return nil
+	}
+
+	for _, c := range configs {
+		if err := link(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
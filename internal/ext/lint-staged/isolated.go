@@ -0,0 +1,90 @@
+package lintstaged
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/mr"
+)
+
+// With --isolated, the read-only commands (not fixers) run in a checkout of the index in a temporary directory,
+// so they see exactly the staged content without touching the working tree.
+
+// isolatedPath maps the path in the git root to the same path in the checkout of the index,
+// the paths outside the git root are kept as is
+func (s *State) isolatedPath(p string) string {
+	rel, err := filepath.Rel(s.gitRoot, p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return p
+	}
+	return filepath.Join(s.isolatedRoot, rel)
+}
+
+// isolatedFiles returns the files with their absolute paths in the checkout of the index
+func (s *State) isolatedFiles(files Files) Files {
+	return mr.Map(files, func(in *File, index int) *File {
+		file := *in
+		file.absolutePath = s.isolatedPath(in.absolutePath)
+		return &file
+	})
+}
+
+// checkoutIndex writes all files in the index to the temporary directory
+func (s *State) checkoutIndex() error {
+	_, err := execGit([]string{"checkout-index", "--all", "--force", "--prefix=" + s.isolatedRoot + string(filepath.Separator)}, s.gitRoot)
+	if err != nil {
+		return ee.Wrap(err, "cannot checkout the index")
+	}
+	return nil
+}
+
+// syncIsolated copies the files modified by a fixer to the checkout of the index,
+// so that the read-only commands running later see the fixed content
+func (s *State) syncIsolated(files Files) error {
+	for _, file := range files {
+		target := s.isolatedPath(file.AbsolutePath())
+
+		stat, err := os.Stat(file.AbsolutePath())
+		if os.IsNotExist(err) { // removed by the fixer
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return ee.Wrapf(err, "cannot remove %s", target)
+			}
+			continue
+		}
+		if err != nil {
+			return ee.Wrapf(err, "cannot stat %s", file.AbsolutePath())
+		}
+		data, err := os.ReadFile(file.AbsolutePath())
+		if err != nil {
+			return ee.Wrapf(err, "cannot read %s", file.AbsolutePath())
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return ee.Wrapf(err, "cannot create directory for %s", target)
+		}
+		if err := os.WriteFile(target, data, stat.Mode().Perm()); err != nil {
+			return ee.Wrapf(err, "cannot write %s", target)
+		}
+		if err := os.Chmod(target, stat.Mode().Perm()); err != nil { // WriteFile keeps the mode of existing files
+			return ee.Wrapf(err, "cannot chmod %s", target)
+		}
+	}
+
+	return nil
+}
+
+// hasFixers reports whether any fixer will run on some files, i.e. the working tree may be modified
+func (s *State) hasFixers() bool {
+	for _, c := range s.configTasks {
+		for _, rule := range c.rules {
+			for _, cmd := range rule.commands {
+				if cmd.cmd.Fixer && len(cmd.files) != 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
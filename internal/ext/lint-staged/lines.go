@@ -0,0 +1,388 @@
+package lintstaged
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/mr"
+
+	"github.com/ImSingee/kitty/internal/lib/shells"
+)
+
+// ChangedLinesEnv is the environment variable of the JSON file of the changed lines, see changedLinesFile
+const ChangedLinesEnv = "KITTY_CHANGED_LINES"
+
+// lineRange is a range of changed lines, both inclusive
+type lineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// changedLines is the changed line ranges of the selected files, by git relative path
+//
+// a file without ranges has no changed lines, e.g. an unchanged file with --status all
+type changedLines map[string][]lineRange
+
+func (c changedLines) contains(file string, line int) bool {
+	for _, r := range c[file] {
+		if line >= r.Start && line <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// changedLinesFile is the content of the file in $KITTY_CHANGED_LINES
+type changedLinesFile struct {
+	Root  string       `json:"root"`  // the git root
+	Files changedLines `json:"files"` // by the path relative to the root
+}
+
+// needsChangedLines reports whether the command filters its output by the changed lines,
+// expands {start} and {end}, or reads $KITTY_CHANGED_LINES
+func (c *Command) needsChangedLines() bool {
+	return c.ChangedLinesOnly || c.rangeTemplate != "" || strings.Contains(c.execCommand, ChangedLinesEnv)
+}
+
+// diffBaseArgs returns the arguments of `git diff` to get the changes of the selection,
+// nil if the files are compared with nothing (i.e. all lines are changed)
+func diffBaseArgs(options *Options) []string {
+	if options.Diff != "" {
+		return strings.Fields(options.Diff)
+	}
+
+	switch options.SelectionMode() {
+	case SelectionModeStaged:
+		return []string{"--staged"}
+	case SelectionModeUnstaged:
+		return []string{}
+	case SelectionModeTracked, SelectionModeChanged, SelectionModeAll:
+		return []string{"HEAD"}
+	case SelectionModeSince:
+		return []string{options.sinceBase}
+	default: // untracked
+		return nil
+	}
+}
+
+// getChangedLines returns the changed line ranges of the files in the diff of the selection,
+// all lines of the untracked files are changed
+func getChangedLines(options *Options, gitDir string, submodules []string, files Files) (changedLines, error) {
+	result := make(changedLines, len(files))
+
+	// the sources of the renames are in the diff too, or the renamed files are added entirely
+	renames := make(map[string]string)
+	for _, file := range files {
+		if from := file.RenamedFrom(); from != nil {
+			renames[file.GitRelativePath()] = from.GitRelativePath()
+		}
+	}
+
+	groups := splitBySubmodule(submodules, files.GitRelativePaths())
+	for _, submodule := range sortedKeys(groups) {
+		lines, err := getRepositoryChangedLines(options, filepath.Join(gitDir, submodule), groups[submodule], func(p string) string {
+			from, ok := renames[path.Join(submodule, p)]
+			if !ok {
+				return ""
+			}
+			return strings.TrimPrefix(from, submodule+"/")
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for file, ranges := range lines {
+			result[path.Join(submodule, file)] = ranges
+		}
+	}
+
+	return result, nil
+}
+
+// getRepositoryChangedLines returns the changed line ranges of the files in the repository (or submodule) at dir,
+// the paths (and the sources of the renamed ones, by renamedFrom) are relative to dir
+func getRepositoryChangedLines(options *Options, dir string, paths []string, renamedFrom func(string) string) (changedLines, error) {
+	result := make(changedLines, len(paths))
+	pathspecs := make([]string, len(paths)) // a renamed file with its source, separated by NUL to be in the same chunk
+	for i, p := range paths {
+		result[p] = nil
+		pathspecs[i] = p
+		if from := renamedFrom(p); from != "" {
+			pathspecs[i] += "\x00" + from
+		}
+	}
+
+	untracked := paths // all lines are changed without a diff
+	if base := diffBaseArgs(options); base != nil {
+		for _, chunk := range chunkFiles(pathspecs, options.maxArgLength) {
+			args := mr.Flats(
+				[]string{"-c", "core.quotePath=false", "diff", "-U0", "-M", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/"},
+				base,
+				[]string{"--"},
+				strings.Split(strings.Join(chunk, "\x00"), "\x00"),
+			)
+			output, err := execGitWithIndex(args, dir, "")
+			if err != nil {
+				return nil, ee.Wrap(err, "cannot get diff of the selected files")
+			}
+
+			for file, ranges := range parseDiffRanges(output) {
+				if _, ok := result[file]; ok {
+					result[file] = ranges
+				}
+			}
+		}
+
+		untrackedFiles, err := getUntrackedFiles(dir)
+		if err != nil {
+			return nil, ee.Wrap(err, "cannot get untracked files")
+		}
+		untracked = selectedPaths(untrackedFiles)
+	}
+
+	for _, file := range untracked {
+		if _, ok := result[file]; !ok {
+			continue
+		}
+
+		n, err := countLines(filepath.Join(dir, file))
+		if err != nil {
+			return nil, ee.Wrapf(err, "cannot read %s", file)
+		}
+		if n > 0 {
+			result[file] = []lineRange{{Start: 1, End: n}}
+		}
+	}
+
+	return result, nil
+}
+
+var hunkHeaderRegexp = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,(\d+))? @@`)
+
+// parseDiffRanges parses the ranges of the added lines in the `git diff -U0` output by the new paths
+func parseDiffRanges(diff string) changedLines {
+	result := make(changedLines)
+	file := ""
+
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = parseDiffPath(strings.TrimPrefix(line, "+++ "))
+			if file != "" {
+				result[file] = nil
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			m := hunkHeaderRegexp.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+
+			start, _ := strconv.Atoi(m[1])
+			count := 1
+			if m[2] != "" {
+				count, _ = strconv.Atoi(m[2])
+			}
+			if count == 0 { // only removed lines
+				continue
+			}
+
+			result[file] = append(result[file], lineRange{Start: start, End: start + count - 1})
+		}
+	}
+
+	return result
+}
+
+// parseDiffPath parses the path in the ---/+++ line, empty for /dev/null
+func parseDiffPath(s string) string {
+	s = strings.TrimSuffix(s, "\t") // added if the path contains spaces
+	if strings.HasPrefix(s, `"`) {
+		if unquoted, err := strconv.Unquote(s); err == nil {
+			s = unquoted
+		}
+	}
+
+	if s == "/dev/null" {
+		return ""
+	}
+	_, path, _ := strings.Cut(s, "/") // b/<path>
+	return path
+}
+
+func countLines(filename string) (int, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) == 0 {
+		return 0, nil
+	}
+
+	n := bytes.Count(data, []byte("\n"))
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		n++
+	}
+	return n, nil
+}
+
+// writeChangedLinesFile writes the changed lines to a JSON file in dir for $KITTY_CHANGED_LINES
+func writeChangedLinesFile(dir, gitRoot string, lines changedLines) (string, error) {
+	data, err := json.Marshal(&changedLinesFile{Root: gitRoot, Files: lines})
+	if err != nil {
+		return "", ee.Wrap(err, "cannot encode changed lines")
+	}
+
+	filename := filepath.Join(dir, "changed-lines.json")
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		return "", ee.Wrap(err, "cannot write changed lines")
+	}
+	return filename, nil
+}
+
+var lineRangePlaceholderRegexp = regexp.MustCompile(`\S*\{(?:start|end)\}\S*`)
+
+// parseLineRangeTemplate finds the argument with the {file}, {start} and {end} placeholders,
+// which is expanded for each changed line range instead of appending the files
+func (c *Command) parseLineRangeTemplate() {
+	loc := lineRangePlaceholderRegexp.FindStringIndex(c.execCommand)
+	if loc == nil {
+		return
+	}
+
+	c.rangeTemplate = c.execCommand[loc[0]:loc[1]]
+	c.execCommand = c.execCommand[:loc[0]] + c.execCommand[loc[1]:]
+	c.argsAt = loc[0]
+}
+
+// checkLineRangeTemplate checks the placeholders can be expanded with the other options
+func (c *Command) checkLineRangeTemplate() error {
+	if c.rangeTemplate == "" {
+		return nil
+	}
+
+	if !strings.Contains(c.rangeTemplate, "{file}") {
+		return fmt.Errorf("`{start}` and `{end}` must be in the same argument as `{file}`, e.g. {file}:{start}-{end}")
+	}
+	if lineRangePlaceholderRegexp.MatchString(c.execCommand) {
+		return fmt.Errorf("`{start}` and `{end}` can only be used in one argument")
+	}
+	if c.Dir || c.NoArgs {
+		return fmt.Errorf("`{start}` and `{end}` cannot be used with dir or noArgs options")
+	}
+
+	return nil
+}
+
+// commandRangeArgs returns the (quoted) arguments expanded from the template for each changed line range of the files
+func commandRangeArgs(cmd *Command, dir string, onFiles Files, lines changedLines) []string {
+	var args []string
+
+	for _, file := range onFiles {
+		path := file.AbsolutePath()
+		if !cmd.Absolute {
+			if rel, err := filepath.Rel(dir, path); err == nil {
+				path = rel
+			}
+		}
+
+		for _, r := range lines[file.GitRelativePath()] {
+			arg := strings.NewReplacer(
+				"{file}", path,
+				"{start}", strconv.Itoa(r.Start),
+				"{end}", strconv.Itoa(r.End),
+			).Replace(cmd.rangeTemplate)
+
+			if cmd.Prepend != "" {
+				args = append(args, shells.Join([]string{cmd.Prepend, arg}))
+			} else {
+				args = append(args, shells.Join([]string{arg}))
+			}
+		}
+	}
+
+	return args
+}
+
+// diagnosticRegexp matches the diagnostics like `path:line:` or `path:line:column:`
+var diagnosticRegexp = regexp.MustCompile(`^\s*([^\s:][^:]*):(\d+):`)
+
+// filterChangedLines keeps only the diagnostics on the changed lines in the output of the command,
+// the lines following a diagnostic (e.g. the source code) are kept or dropped with it.
+// The command succeeds if it failed only because of the dropped diagnostics,
+// i.e. the output contained diagnostics and nothing is left after dropping them.
+func (s *State) filterChangedLines(dir string, result *TaskResult) {
+	if !result.cmd.ChangedLinesOnly || result.timedOut {
+		return
+	}
+
+	var filtered bytes.Buffer
+	dropped := 0
+	keep := true
+
+	scanner := bufio.NewScanner(bytes.NewReader(result.output))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if m := diagnosticRegexp.FindStringSubmatch(line); m != nil {
+			if file, ok := s.diagnosticFile(dir, m[1]); ok {
+				n, _ := strconv.Atoi(m[2])
+				keep = s.changedLines.contains(file, n)
+				if !keep {
+					dropped++
+				}
+			} else { // not a selected file, kept as is
+				keep = true
+			}
+		}
+
+		if keep {
+			filtered.WriteString(line)
+			filtered.WriteByte('\n')
+		}
+	}
+	if scanner.Err() != nil { // keep the output as is
+		return
+	}
+
+	result.output = filtered.Bytes()
+
+	// any other output (e.g. a crash or a config error) may be the reason of the failure
+	var exitErr *exec.ExitError
+	if dropped != 0 && len(bytes.TrimSpace(result.output)) == 0 && ee.As(result.err, &exitErr) {
+		result.err = nil
+	}
+}
+
+// diagnosticFile returns the git relative path of the path in a diagnostic, if it's a selected file
+func (s *State) diagnosticFile(dir string, path string) (string, bool) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+
+	for _, root := range []string{s.gitRoot, s.isolatedRoot} {
+		if root == "" {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			continue
+		}
+		if _, ok := s.changedLines[filepath.ToSlash(rel)]; ok {
+			return filepath.ToSlash(rel), true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,133 @@
+package lintstaged
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiffRanges(t *testing.T) {
+	diff := `diff --git a/a.go b/a.go
+index 1111111..2222222 100644
+--- a/a.go
++++ b/a.go
+@@ -3 +3 @@ func a() {
+-	old
++	new
+@@ -10,0 +11,2 @@ func b() {
++	added
++	added
+@@ -20,2 +21,0 @@ func c() {
+-	removed
+-	removed
+diff --git a/new file.go b/new file.go
+new file mode 100644
+--- /dev/null
++++ b/new file.go	
+@@ -0,0 +1,3 @@
++package main
++
++func main() {}
+diff --git a/old.go b/renamed.go
+similarity index 90%
+rename from old.go
+rename to renamed.go
+--- a/old.go
++++ b/renamed.go
+@@ -1 +1 @@
+-package old
++package renamed
+diff --git a/deleted.go b/deleted.go
+deleted file mode 100644
+--- a/deleted.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package deleted
+`
+
+	assert.Equal(t, changedLines{
+		"a.go":        {{Start: 3, End: 3}, {Start: 11, End: 12}},
+		"new file.go": {{Start: 1, End: 3}},
+		"renamed.go":  {{Start: 1, End: 1}},
+	}, parseDiffRanges(diff))
+}
+
+func TestParseLineRangeTemplate(t *testing.T) {
+	cmd, err := parseStringCommand("mylint --lines {file}:{start}-{end} --strict")
+	require.NoError(t, err)
+	assert.Equal(t, "{file}:{start}-{end}", cmd.rangeTemplate)
+
+	files := NewFiles(&State{gitRoot: "/repo"}, []string{"a.go", "b c.go", "d.go"})
+	lines := changedLines{"a.go": {{Start: 1, End: 2}, {Start: 5, End: 5}}, "b c.go": {{Start: 3, End: 4}}}
+
+	args := commandRangeArgs(cmd, "/repo", files, lines)
+	assert.Equal(t, []string{"a.go:1-2", "a.go:5-5", "'b c.go:3-4'"}, args)
+	assert.Equal(t, "mylint --lines a.go:1-2 a.go:5-5 'b c.go:3-4' --strict", cmd.commandLine(args))
+
+	cmd, err = parseStringCommand("[prepend --lines] mylint {file}:{start}")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"--lines 'b c.go:3'"}, commandRangeArgs(cmd, "/repo", files[1:], lines))
+
+	for _, invalid := range []string{
+		"mylint {start}-{end}",
+		"mylint {file}:{start} {file}:{end}",
+		"[dir] mylint {file}:{start}",
+	} {
+		_, err := parseStringCommand(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestFilterChangedLines(t *testing.T) {
+	state := &State{
+		gitRoot:      "/repo",
+		changedLines: changedLines{"sub/a.go": {{Start: 3, End: 4}}, "sub/b.go": nil},
+	}
+	cmd := &Command{ChangedLinesOnly: true}
+
+	exitErr := func() error {
+		return exec.Command("/bin/sh", "-c", "exit 1").Run()
+	}
+
+	result := &TaskResult{
+		cmd: cmd,
+		output: []byte(`checking...
+a.go:1:5: old issue
+	source of line 1
+a.go:3: new issue
+	source of line 3
+/repo/sub/b.go:10:1: old issue
+other.go:1: not selected
+2 issues
+`),
+		err: exitErr(),
+	}
+	state.filterChangedLines("/repo/sub", result)
+	assert.Equal(t, `checking...
+a.go:3: new issue
+	source of line 3
+other.go:1: not selected
+2 issues
+`, string(result.output))
+	assert.Error(t, result.err, "there are diagnostics on the changed lines")
+
+	result = &TaskResult{cmd: cmd, output: []byte("a.go:1: old issue\n"), err: exitErr()}
+	state.filterChangedLines("/repo/sub", result)
+	assert.Equal(t, "", string(result.output))
+	assert.NoError(t, result.err, "all diagnostics are on the unchanged lines")
+
+	result = &TaskResult{cmd: cmd, output: []byte("crashed\n"), err: exitErr()}
+	state.filterChangedLines("/repo/sub", result)
+	assert.Error(t, result.err, "failed without diagnostics")
+
+	result = &TaskResult{cmd: cmd, output: []byte("invalid config\na.go:1: old issue\n"), err: exitErr()}
+	state.filterChangedLines("/repo/sub", result)
+	assert.Equal(t, "invalid config\n", string(result.output))
+	assert.Error(t, result.err, "failed with other output")
+
+	result = &TaskResult{cmd: cmd, output: []byte("other.go:1: not selected\n"), err: exitErr()}
+	state.filterChangedLines("/repo/sub", result)
+	assert.Error(t, result.err, "failed without dropped diagnostics")
+}
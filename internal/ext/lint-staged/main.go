@@ -4,6 +4,10 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/ImSingee/go-ex/ee"
 	"github.com/ImSingee/go-ex/pp"
@@ -31,26 +35,85 @@ func Commands() []*cobra.Command {
 	flags.BoolVar(&o.AllowEmpty, "allow-empty", false, "allow empty commits when tasks revert all staged changes")
 	flags.StringVarP(&o.ConfigPath, "config", "c", "", "path to configuration file")
 	flags.StringVar(&o.Diff, "diff", "", `override the default "--staged" flag of "git diff" to get list of files. Implies "--stash=false"`)
-	flags.StringVar(&o.DiffFilter, "diff-filter", "", `override the default "--diff-filter=ACMR" flag of "git diff" to get list of files`)
+	flags.StringVar(&o.DiffFilter, "diff-filter", "", `override the default "--diff-filter=ACMRD" flag of "git diff" to get list of files (the deleted files only go to the rules with "on": "deleted")`)
 	flags.StringVar(&o.Status, "status", string(SelectionModeStaged), "select files by git status: staged, unstaged, untracked, tracked, changed, or all")
+	flags.StringVar(&o.Since, "since", "", `select the files changed since the merge base with the ref (e.g. origin/main), or "auto" to detect the target or default branch. Implies "--stash=false"`)
+	flags.BoolVar(&o.RecurseSubmodules, "recurse-submodules", false, "also select the files inside the changed submodules, which use the configs inside them")
 	flags.BoolVar(&o.Stash, "stash", true, "enable the backup stash, and revert in case of errors")
 	flags.StringVarP(&o.Shell, "shell", "x", "", "use a custom shell to execute tasks with; defaults to the shell specified in the environment variable $SHELL, or /bin/sh if not set")
 	flags.BoolVarP(&o.Verbose, "verbose", "v", false, "show task output even when tasks succeed; by default only failed output is shown")
-
-	// TODO concurrent
+	flags.DurationVar(&o.Timeout, "timeout", 0, "kill the commands running longer than the duration (e.g. 30s, 1m), unless they have their own timeout; 0 means no timeout")
+	flags.IntVar(&o.MaxArgLength, "max-arg-length", 0, "split the files into chunks so that a command line doesn't exceed the length; defaults to a value suitable for the platform")
+	flags.StringVarP(&o.Concurrent, "concurrent", "p", "true", "the number of commands to run concurrently (in all configs, rules and chunks), or false for serial")
+	flags.BoolVar(&o.Isolated, "isolated", false, "run the commands on a checkout of the index in a temporary directory; only the fixers run in place with the backup stash")
+	flags.BoolVar(&o.FailOnChanges, "fail-on-changes", false, "fail and print the diff if tasks modified any file, instead of adding the modifications to the commit")
+	flags.BoolVar(&o.NoCache, "no-cache", false, `run the commands with the "cache" option on all files, ignoring the result cache`)
+	flags.BoolVar(&o.DryRun, "dry-run", false, "show which config owns which file and which commands would run, without running anything")
+	flags.BoolVar(&o.DryRun, "list", false, `alias of "--dry-run"`)
+	flags.BoolVar(&o.JSON, "json", false, `print the plan of "--dry-run" in JSON`)
+	flags.StringVar(&o.Reporter, "reporter", "", "write a machine-readable report to --report-file: json or junit; defaults to the format by the file extension")
+	flags.StringVar(&o.ReportFile, "report-file", "", "the file to write the report to")
+	flags.BoolVar(&o.Restore, "restore", false, `restore the original state from the backup left by an interrupted run; with "--dry-run" only show the backup`)
+	flags.BoolVar(&o.Force, "force", false, `with "--restore", restore even if HEAD has moved or the files outside the backup are changed, discarding the changes`)
+
+	cmd.AddCommand(cacheCommand())
 
 	return []*cobra.Command{cmd}
 }
 
+func cacheCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the result cache",
+		Args:  cobra.NoArgs,
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "clear",
+		Short: "Remove all cached results",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := clearCache(); err != nil {
+				return err
+			}
+
+			pp.Println(yes, "Cache cleared")
+			return nil
+		},
+	})
+
+	return cmd
+}
+
 type Options struct {
-	AllowEmpty bool
-	ConfigPath string
-	Diff       string
-	DiffFilter string
-	Status     string
-	Stash      bool
-	Shell      string
-	Verbose    bool
+	AllowEmpty        bool
+	ConfigPath        string
+	Diff              string
+	DiffFilter        string
+	Status            string
+	Since             string
+	RecurseSubmodules bool
+	Stash             bool
+	Shell             string
+	Verbose           bool
+	Concurrent        string
+	Timeout           time.Duration
+	MaxArgLength      int
+	Isolated          bool
+	NoCache           bool
+	FailOnChanges     bool
+	Reporter          string
+	ReportFile        string
+	DryRun            bool
+	JSON              bool
+	Restore           bool
+	Force             bool
+
+	sinceRef     string        // resolved from Since, see getSinceFiles
+	sinceBase    string        // the merge base of sinceRef and HEAD
+	concurrency  int           // parsed from Concurrent, see parseConcurrent
+	slots        chan struct{} // shared by all the commands to run at most concurrency processes at the same time, nil for no limit
+	maxArgLength int           // MaxArgLength, or the default one of the platform
 }
 
 func Run(options *Options) error {
@@ -61,12 +124,27 @@ func Run(options *Options) error {
 	// Unset GIT_LITERAL_PATHSPECS to not mess with path interpretation
 	unsetEnv("GIT_LITERAL_PATHSPECS")
 
+	if options.Restore {
+		return restoreBackup(options)
+	}
+
+	startedAt := time.Now()
 	state, err := runAll(options)
 
 	for _, output := range state.output {
 		pp.EPrintln(output)
 	}
 
+	if options.Reporter != "" {
+		r := buildReport(state, options, err == nil, time.Since(startedAt))
+		if reportErr := writeReport(r, options.Reporter, options.ReportFile); reportErr != nil {
+			pp.ERedPrintf("%s Cannot write the report (%s)!\n", x, reportErr.Error())
+			if err == nil {
+				err = ee.Phantom
+			}
+		}
+	}
+
 	return err
 }
 
@@ -75,6 +153,56 @@ func validateOptions(options *Options) error {
 		return err
 	}
 
+	concurrency, err := parseConcurrent(options.Concurrent)
+	if err != nil {
+		return err
+	}
+	options.concurrency = concurrency
+	if concurrency > 0 {
+		options.slots = make(chan struct{}, concurrency)
+	}
+
+	if options.ReportFile != "" && options.Reporter == "" {
+		options.Reporter = ReporterJSON
+		if strings.EqualFold(filepath.Ext(options.ReportFile), ".xml") {
+			options.Reporter = ReporterJUnit
+		}
+	}
+	switch options.Reporter {
+	case "", ReporterJSON, ReporterJUnit:
+	default:
+		return fmt.Errorf("invalid reporter `%s`: must be %s or %s", options.Reporter, ReporterJSON, ReporterJUnit)
+	}
+	if options.Reporter != "" && options.ReportFile == "" {
+		return fmt.Errorf("--report-file is required for --reporter")
+	}
+	if options.DryRun && options.Reporter != "" {
+		return fmt.Errorf("--reporter cannot be used with --dry-run")
+	}
+	if options.Isolated && !options.UsesIndex() {
+		return fmt.Errorf("--isolated can only be used with the staged files, but %s", options.SelectionReason())
+	}
+	if options.RecurseSubmodules && (options.Diff != "" || options.Since != "") {
+		return fmt.Errorf("--recurse-submodules cannot be used with --diff or --since")
+	}
+	if options.RecurseSubmodules && options.Isolated {
+		return fmt.Errorf("--recurse-submodules cannot be used with --isolated")
+	}
+	if options.Restore && (options.JSON || options.Reporter != "") {
+		return fmt.Errorf("--restore cannot be used with --json or --reporter")
+	}
+	if options.Force && !options.Restore {
+		return fmt.Errorf("--force can only be used with --restore")
+	}
+	if options.JSON && !options.DryRun {
+		return fmt.Errorf("--json can only be used with --dry-run")
+	}
+
+	options.maxArgLength = options.MaxArgLength
+	if options.maxArgLength <= 0 {
+		options.maxArgLength = defaultMaxArgLength()
+	}
+
 	if options.Shell == "" {
 		options.Shell = os.Getenv("SHELL")
 		if options.Shell == "" {
@@ -89,3 +217,21 @@ func validateOptions(options *Options) error {
 
 	return nil
 }
+
+// parseConcurrent parses the value of --concurrent to the limit of tasks running at the same time,
+// "true" (or empty) means no limit (0) and "false" means serial (1)
+func parseConcurrent(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "true":
+		return 0, nil
+	case "false":
+		return 1, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid concurrent value `%s`: must be true, false or a positive number", s)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,28 @@
+package lintstaged
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConcurrent(t *testing.T) {
+	for s, expected := range map[string]int{
+		"":      0,
+		"true":  0,
+		"TRUE":  0,
+		"false": 1,
+		"1":     1,
+		"4":     4,
+	} {
+		n, err := parseConcurrent(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, expected, n, s)
+	}
+
+	for _, s := range []string{"0", "-1", "yes", "2.5"} {
+		_, err := parseConcurrent(s)
+		assert.Error(t, err, s)
+	}
+}
@@ -0,0 +1,186 @@
+package lintstaged
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/mr"
+
+	"github.com/ImSingee/kitty/internal/lib/shells"
+)
+
+const (
+	// FilesEnv is the environment variable of the files the command runs on, separated by newlines
+	//
+	// it's not set if the list is too long, use FilesFileEnv instead
+	FilesEnv = "KITTY_FILES"
+	// FilesFileEnv is the environment variable of the path of a file listing the files the command runs on, one per line
+	FilesFileEnv = "KITTY_FILES_FILE"
+)
+
+// filePlaceholder is replaced by each file, the command runs once for each file
+const filePlaceholder = "{file}"
+
+// fromPlaceholder is replaced by the source of each renamed file (or the file itself if it's not renamed), with {file}
+const fromPlaceholder = "{from}"
+
+// filesPlaceholderRegexp matches the placeholders replaced by all the arguments:
+//
+//   - {files}: the files, like the arguments appended by default
+//   - {dirs}: the directories of the files, like [dir]
+//   - {pkgs}: the directories of the files as Go packages, e.g. ./foo/bar
+//   - {relfiles}: the files relative to the git root
+var filesPlaceholderRegexp = regexp.MustCompile(`\{(?:files|dirs|pkgs|relfiles)\}`)
+
+// argFilePlaceholder is shown instead of the path of the argument file in the plan
+const argFilePlaceholder = "<argfile>"
+
+// parsePlaceholders finds the placeholder of the file arguments in the command,
+// which are inserted there instead of being appended
+func (c *Command) parsePlaceholders() error {
+	c.perFile = strings.Contains(c.execCommand, filePlaceholder)
+	c.renameSource = strings.Contains(c.execCommand, fromPlaceholder)
+
+	locs := filesPlaceholderRegexp.FindAllStringIndex(c.execCommand, -1)
+	if len(locs) == 0 {
+		return nil
+	}
+	if len(locs) > 1 {
+		return fmt.Errorf("only one of `{files}`, `{dirs}`, `{pkgs}` and `{relfiles}` can be used")
+	}
+
+	start, end := locs[0][0], locs[0][1]
+	placeholder := c.execCommand[start:end]
+	if (start > 0 && !unicode.IsSpace(rune(c.execCommand[start-1]))) || (end < len(c.execCommand) && !unicode.IsSpace(rune(c.execCommand[end]))) {
+		return fmt.Errorf("`%s` must be a separate argument", placeholder)
+	}
+	if c.rangeTemplate != "" {
+		return fmt.Errorf("`%s` cannot be used with `{start}` and `{end}`", placeholder)
+	}
+
+	c.placeholder = strings.Trim(placeholder, "{}")
+	c.execCommand = c.execCommand[:start] + c.execCommand[end:]
+	c.argsAt = start
+
+	return nil
+}
+
+// checkPlaceholders checks the placeholders and [argfile] can be used with the other options
+func (c *Command) checkPlaceholders() error {
+	if err := c.checkLineRangeTemplate(); err != nil {
+		return err
+	}
+
+	if c.renameSource && !c.perFile {
+		return fmt.Errorf("`{from}` can only be used with `{file}`")
+	}
+	if c.perFile {
+		switch {
+		case c.rangeTemplate != "":
+			return fmt.Errorf("`{file}` must be in the same argument as `{start}` and `{end}`")
+		case c.placeholder != "":
+			return fmt.Errorf("`{file}` cannot be used with `{%s}`", c.placeholder)
+		case c.Dir:
+			return fmt.Errorf("`{file}` cannot be used with dir option")
+		}
+	}
+	if (c.perFile || c.placeholder != "") && c.NoArgs {
+		return fmt.Errorf("placeholders of the files cannot be used with noArgs option")
+	}
+
+	if c.ArgFile {
+		switch {
+		case c.NoArgs:
+			return fmt.Errorf("cannot have both argfile and noArgs options")
+		case c.perFile || c.rangeTemplate != "":
+			return fmt.Errorf("argfile cannot be used with `{file}`, `{start}` or `{end}`")
+		}
+	}
+
+	return nil
+}
+
+// commandChunks returns the (quoted) arguments of each run of the command on the files,
+// argFile is the path of the argument file for [argfile]
+//
+// with {from}, each chunk is the file and the source of the rename
+func commandChunks(cmd *Command, dir string, files Files, lines changedLines, argFile string, maxArgLength int) [][]string {
+	switch {
+	case cmd.ArgFile:
+		return [][]string{{argFileArg(cmd, argFile)}}
+	case cmd.perFile:
+		var sourceArgs []string
+		if cmd.renameSource {
+			sourceArgs = commandFileArgs(cmd, dir, files.RenameSources())
+		}
+		return mr.Map(commandFileArgs(cmd, dir, files), func(arg string, index int) []string {
+			if cmd.renameSource {
+				return []string{arg, sourceArgs[index]}
+			}
+			return []string{arg}
+		})
+	case cmd.rangeTemplate != "":
+		return chunkFiles(commandRangeArgs(cmd, dir, files, lines), maxArgLength)
+	default:
+		return chunkFiles(commandFileArgs(cmd, dir, files), maxArgLength)
+	}
+}
+
+// argFileArg returns the (quoted) argument of the argument file, `@<path>` or `<prepend> <path>`
+func argFileArg(cmd *Command, path string) string {
+	if cmd.Prepend != "" {
+		return shells.Join([]string{cmd.Prepend, path})
+	}
+	return shells.Join([]string{"@" + path})
+}
+
+// writeCommandFiles writes the list of the files for $KITTY_FILES_FILE (and the argument file for [argfile])
+// to the temporary directory, it returns the environment variables of the command and the path of the argument file
+func (s *State) writeCommandFiles(cmd *Command, dir string, files Files, maxArgLength int) (env []string, argFile string, err error) {
+	if s.tmpDir == "" {
+		return nil, "", nil
+	}
+
+	paths := commandPaths(&Command{Absolute: cmd.Absolute}, dir, files)
+	list := strings.Join(paths, "\n")
+
+	filesFile, err := writeTempList(s.tmpDir, "files-*.txt", paths)
+	if err != nil {
+		return nil, "", err
+	}
+	env = append(env, FilesFileEnv+"="+filesFile)
+	// the list is passed as the arguments too, so the variable is kept well below the limit
+	if filesEnv := FilesEnv + "=" + list; maxArgLength <= 0 || len(filesEnv) <= maxArgLength/4 {
+		env = append(env, filesEnv)
+	}
+
+	if cmd.ArgFile {
+		argFile, err = writeTempList(s.tmpDir, "argfile-*.txt", commandPaths(cmd, dir, files))
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return env, argFile, nil
+}
+
+// writeTempList writes the lines to a new file in dir and returns its path
+func writeTempList(dir, pattern string, lines []string) (string, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return "", ee.Wrap(err, "cannot create file list")
+	}
+	defer f.Close()
+
+	for _, line := range lines {
+		if _, err := f.WriteString(line + "\n"); err != nil {
+			return "", ee.Wrap(err, "cannot write file list")
+		}
+	}
+
+	return f.Name(), nil
+}
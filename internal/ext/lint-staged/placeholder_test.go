@@ -0,0 +1,148 @@
+package lintstaged
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePlaceholders(t *testing.T) {
+	files := NewFiles(&State{gitRoot: "/repo"}, []string{"a/b.go", "a/c d.go", "e/f/g.go", "h.go"})
+
+	testCases := []struct {
+		command string
+		chunks  [][]string
+		lines   []string
+	}{
+		{
+			command: "go vet {pkgs} -v",
+			chunks:  [][]string{{"./a", "./e/f", "."}},
+			lines:   []string{"go vet ./a ./e/f . -v"},
+		},
+		{
+			command: "[absolute] ls {dirs}",
+			chunks:  [][]string{{"/repo/a", "/repo/e/f", "/repo"}},
+			lines:   []string{"ls /repo/a /repo/e/f /repo"},
+		},
+		{
+			command: "[prepend -f] lint {files} --strict",
+			chunks:  [][]string{{"-f a/b.go", "-f 'a/c d.go'", "-f e/f/g.go", "-f h.go"}},
+			lines:   []string{"lint -f a/b.go -f 'a/c d.go' -f e/f/g.go -f h.go --strict"},
+		},
+		{
+			command: "cat {file} > {file}.out",
+			chunks:  [][]string{{"a/b.go"}, {"'a/c d.go'"}, {"e/f/g.go"}, {"h.go"}},
+			lines:   []string{"cat a/b.go > a/b.go.out", "cat 'a/c d.go' > 'a/c d.go'.out", "cat e/f/g.go > e/f/g.go.out", "cat h.go > h.go.out"},
+		},
+		{
+			command: "[argfile] javac",
+			chunks:  [][]string{{"'@<argfile>'"}},
+			lines:   []string{"javac '@<argfile>'"},
+		},
+		{
+			command: "[argfile][prepend --files-from] tool {files} --check",
+			chunks:  [][]string{{"--files-from '<argfile>'"}},
+			lines:   []string{"tool --files-from '<argfile>' --check"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.command, func(t *testing.T) {
+			cmd, err := parseStringCommand(tc.command)
+			require.NoError(t, err)
+
+			chunks := commandChunks(cmd, "/repo", files, nil, argFilePlaceholder, 0)
+			assert.Equal(t, tc.chunks, chunks)
+
+			c := &commandTasks{cmd: cmd, files: files, chunks: chunks}
+			assert.Equal(t, tc.lines, c.commandLines())
+		})
+	}
+
+	t.Run("from", func(t *testing.T) {
+		renamed := newSelectedFiles(&State{gitRoot: "/repo"}, []selectedFile{
+			{path: "a/new.go", status: FileStatusRenamed, from: "old.go"},
+			{path: "b.go", status: FileStatusModified},
+		})
+
+		cmd, err := parseStringCommand("git log --follow {from} -- {file}")
+		require.NoError(t, err)
+
+		chunks := commandChunks(cmd, "/repo/a", renamed, nil, "", 0)
+		assert.Equal(t, [][]string{{"new.go", "../old.go"}, {"../b.go", "../b.go"}}, chunks)
+
+		c := &commandTasks{cmd: cmd, files: renamed, chunks: chunks}
+		assert.Equal(t, []string{"git log --follow ../old.go -- new.go", "git log --follow ../b.go -- ../b.go"}, c.commandLines())
+	})
+
+	t.Run("relfiles", func(t *testing.T) {
+		cmd, err := parseStringCommand("lint {relfiles}")
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{{"a/b.go", "'a/c d.go'"}}, commandChunks(cmd, "/repo/a", files[:2], nil, "", 0), "relative to the git root")
+	})
+
+	for _, invalid := range []string{
+		"lint {files} {dirs}",
+		"lint --files={files}",
+		"lint {file}:{start} {file}",
+		"lint {file} {files}",
+		"[dir] lint {file}",
+		"[noArgs] lint {files}",
+		"[argfile][noArgs] lint",
+		"[argfile] lint {file}:{start}-{end}",
+		"lint {from} {files}",
+	} {
+		_, err := parseStringCommand(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestWriteCommandFilesEnv(t *testing.T) {
+	state := &State{gitRoot: "/repo", tmpDir: t.TempDir()}
+	const maxArgLength = 131072
+
+	// a list close to the limit is only passed in the file, the arguments already take most of it
+	var paths []string
+	for length := 0; length < maxArgLength-100; length += 10 {
+		paths = append(paths, fmt.Sprintf("f%08d", len(paths)))
+	}
+	files := NewFiles(state, paths)
+
+	env, _, err := state.writeCommandFiles(&Command{}, "/repo", files, maxArgLength)
+	require.NoError(t, err)
+	require.Len(t, env, 1)
+	assert.True(t, strings.HasPrefix(env[0], FilesFileEnv+"="))
+
+	env, _, err = state.writeCommandFiles(&Command{}, "/repo", files[:len(files)/5], maxArgLength)
+	require.NoError(t, err)
+	require.Len(t, env, 2)
+	assert.LessOrEqual(t, len(env[1]), maxArgLength/4)
+	assert.True(t, strings.HasPrefix(env[1], FilesEnv+"=f00000000\n"))
+}
+
+func TestCommandChunksMaxArgLength(t *testing.T) {
+	options := &Options{}
+	require.NoError(t, validateOptions(options))
+	cmd, err := parseStringCommand("eslint --fix")
+	require.NoError(t, err)
+
+	state := &State{gitRoot: "/repo"}
+	var paths []string
+	for i := 0; i < 3*platformArgLimit()/20; i++ {
+		paths = append(paths, fmt.Sprintf("src/file-%09d.js", i))
+	}
+
+	// the chunks fill the budget, and the scripts stay well below the limit of the platform
+	budget := options.maxArgLength
+	chunks := commandChunks(cmd, "/repo", NewFiles(state, paths), nil, "", commandMaxArgLength(options, cmd))
+	require.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks[:len(chunks)-1] {
+		script := cmd.commandLine(chunk)
+		assert.LessOrEqual(t, len(script), budget)
+		assert.Greater(t, len(script), budget-len(paths[0])-1)
+		assert.LessOrEqual(t, len(options.Shell)+len("-c")+len(script)+3, platformArgLimit()-2048)
+	}
+}
@@ -0,0 +1,173 @@
+package lintstaged
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/exstrings"
+	"github.com/ImSingee/go-ex/mr"
+	"github.com/ImSingee/go-ex/pp"
+)
+
+// plan is what would run for the selected files, shown by --dry-run
+type plan struct {
+	Selection string            `json:"selection"`
+	Files     []string          `json:"files"`
+	Deleted   []string          `json:"deleted,omitempty"` // the deleted files in Files
+	Renamed   map[string]string `json:"renamed,omitempty"` // the sources of the renamed files in Files
+	Configs   []*configPlan     `json:"configs"`
+}
+
+type configPlan struct {
+	Path    string      `json:"path"` // relative to the git root
+	Files   []string    `json:"files"`
+	Ignored []string    `json:"ignored"` // by .kittyignore or .lintstagedignore
+	Rules   []*rulePlan `json:"rules"`
+}
+
+type rulePlan struct {
+	Pattern  string         `json:"pattern"`
+	From     string         `json:"from,omitempty"` // the config of the rule if inherited, relative to the git root
+	Files    []string       `json:"files"`
+	Commands []*commandPlan `json:"commands"`
+}
+
+type commandPlan struct {
+	Command      string   `json:"command"`
+	Title        string   `json:"title,omitempty"`
+	Cwd          string   `json:"cwd"`                // relative to the git root
+	CommandLines []string `json:"commandLines"`       // one for each chunk, empty if there are no files
+	Isolated     bool     `json:"isolated,omitempty"` // runs in the checkout of the index
+}
+
+func buildPlan(state *State, options *Options, files Files) *plan {
+	p := &plan{
+		Selection: options.SelectedFilesLabel(),
+		Files:     files.GitRelativePaths(),
+		Configs:   make([]*configPlan, 0, len(state.configTasks)),
+	}
+	for _, f := range files {
+		switch {
+		case f.Status() == FileStatusDeleted:
+			p.Deleted = append(p.Deleted, f.GitRelativePath())
+		case f.RenamedFrom() != nil:
+			if p.Renamed == nil {
+				p.Renamed = make(map[string]string)
+			}
+			p.Renamed[f.GitRelativePath()] = f.RenamedFrom().GitRelativePath()
+		}
+	}
+
+	for _, c := range state.configTasks {
+		cp := &configPlan{
+			Path:  gitRelative(state, c.config.Path),
+			Files: c.files.GitRelativePaths(),
+			Ignored: Files(mr.Filter(c.files, func(in *File, index int) bool {
+				return state.ignoreChecker.ShouldIgnore(in.GitRelativePath())
+			})).GitRelativePaths(),
+			Rules: make([]*rulePlan, 0, len(c.rules)),
+		}
+
+		for _, rule := range c.rules {
+			rp := &rulePlan{
+				Pattern: rule.rule.GlobString,
+				From:    rule.inheritedFrom(state, c.config),
+				Files:   rule.files.GitRelativePaths(),
+				Commands: mr.Map(rule.commands, func(cmd *commandTasks, index int) *commandPlan {
+					return &commandPlan{
+						Command:      cmd.cmd.Command,
+						Title:        cmd.cmd.Title,
+						Cwd:          gitRelative(state, cmd.dir),
+						CommandLines: cmd.commandLines(),
+						Isolated:     cmd.isolated,
+					}
+				}),
+			}
+
+			cp.Rules = append(cp.Rules, rp)
+		}
+
+		p.Configs = append(p.Configs, cp)
+	}
+
+	return p
+}
+
+// fileStatus returns the description of the file if it's deleted or renamed
+func (p *plan) fileStatus(f string) string {
+	if exstrings.InStringList(p.Deleted, f) {
+		return " (deleted)"
+	}
+	if from, ok := p.Renamed[f]; ok {
+		return " (renamed from " + from + ")"
+	}
+	return ""
+}
+
+// gitRelative returns the slash-separated path relative to the git root
+func gitRelative(state *State, path string) string {
+	rel, err := filepath.Rel(state.gitRoot, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func printPlan(p *plan, asJSON bool) error {
+	if asJSON {
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return ee.Wrap(err, "cannot encode plan")
+		}
+		pp.Println(string(data))
+		return nil
+	}
+
+	if len(p.Files) == 0 {
+		pp.BluePrintln(info, "No "+p.Selection+" found.")
+		return nil
+	}
+
+	pp.Printf("%d %s, nothing will be run (dry run)\n", len(p.Files), p.Selection)
+
+	for _, c := range p.Configs {
+		pp.Println()
+		pp.Println(c.Path + symGray(fmt.Sprintf(" - %d files", len(c.Files))))
+		for _, f := range c.Files {
+			if exstrings.InStringList(c.Ignored, f) {
+				pp.Println("  " + symGray(f+" (ignored)"))
+			} else {
+				pp.Println("  " + f + symGray(p.fileStatus(f)))
+			}
+		}
+
+		for _, rule := range c.Rules {
+			from := ""
+			if rule.From != "" {
+				from = " (from " + rule.From + ")"
+			}
+
+			if len(rule.Files) == 0 {
+				pp.Println(symGray("  " + rule.Pattern + " - no files" + from))
+				continue
+			}
+
+			pp.Println("  " + rule.Pattern + symGray(fmt.Sprintf(" - %d files", len(rule.Files))+from))
+			for _, cmd := range rule.Commands {
+				title := cmd.Command
+				if cmd.Title != "" {
+					title = cmd.Title + symGray(" ("+cmd.Command+")")
+				}
+				pp.Println("    " + title + symGray(" in "+cmd.Cwd))
+
+				for _, line := range cmd.CommandLines {
+					pp.Println("      " + symGray("$ ") + line)
+				}
+			}
+		}
+	}
+
+	return nil
+}
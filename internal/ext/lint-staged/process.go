@@ -0,0 +1,72 @@
+package lintstaged
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// killGracePeriod is how long to wait after SIGTERM before SIGKILL
+var killGracePeriod = 5 * time.Second
+
+var errInterrupted = errors.New("interrupted")
+
+// timeoutError is returned when a command runs longer than its timeout
+type timeoutError struct {
+	timeout time.Duration
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.timeout)
+}
+
+// runCommand runs the command in its own process group and returns its combined output
+//
+// when ctx is done (timeout or interrupt), the whole process group receives SIGTERM,
+// and SIGKILL if it's still running after killGracePeriod.
+// The output pipes are closed killGracePeriod after the command exits, even if a process escaped the group still holds them.
+func runCommand(ctx context.Context, p *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	output := &bytes.Buffer{}
+	p.Stdout = output
+	p.Stderr = output
+	p.WaitDelay = killGracePeriod
+	setProcessGroup(p)
+
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if err := p.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return output.Bytes(), err
+	case <-ctx.Done():
+	}
+
+	_ = terminateProcessGroup(p, false)
+	select {
+	case <-done:
+	case <-time.After(killGracePeriod):
+		_ = terminateProcessGroup(p, true)
+		<-done
+	}
+
+	if parent.Err() == nil { // done because of the timeout
+		return output.Bytes(), &timeoutError{timeout: timeout}
+	}
+	return output.Bytes(), errInterrupted
+}
@@ -0,0 +1,109 @@
+//go:build !windows
+
+package lintstaged
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommand(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		output, err := runCommand(context.Background(), exec.Command("/bin/sh", "-c", "echo out; echo err >&2"), time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "out\nerr\n", string(output))
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		_, err := runCommand(context.Background(), exec.Command("/bin/sh", "-c", "exit 3"), 0)
+		var exitErr *exec.ExitError
+		require.True(t, ee.As(err, &exitErr))
+		assert.Equal(t, 3, exitErr.ExitCode())
+	})
+
+	t.Run("timeout kills the process group", func(t *testing.T) {
+		pidFile := filepath.Join(t.TempDir(), "pid")
+
+		start := time.Now()
+		output, err := runCommand(context.Background(), exec.Command("/bin/sh", "-c", "echo started; sleep 30 & echo $! > "+pidFile+"; wait"), 200*time.Millisecond)
+		require.Error(t, err)
+		assert.Equal(t, "timed out after 200ms", err.Error())
+		assert.True(t, ee.As(err, new(*timeoutError)))
+		assert.Equal(t, "started\n", string(output))
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		assertProcessExited(t, pidFile)
+	})
+
+	t.Run("SIGKILL after grace period", func(t *testing.T) {
+		defer func(d time.Duration) { killGracePeriod = d }(killGracePeriod)
+		killGracePeriod = 200 * time.Millisecond
+
+		pidFile := filepath.Join(t.TempDir(), "pid")
+
+		start := time.Now()
+		_, err := runCommand(context.Background(), exec.Command("/bin/sh", "-c", "trap '' TERM; echo $$ > "+pidFile+"; sleep 30; sleep 30"), 200*time.Millisecond)
+		require.Error(t, err)
+		assert.True(t, ee.As(err, new(*timeoutError)))
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		assertProcessExited(t, pidFile)
+	})
+
+	t.Run("escaped process holding the output", func(t *testing.T) {
+		defer func(d time.Duration) { killGracePeriod = d }(killGracePeriod)
+		killGracePeriod = 200 * time.Millisecond
+
+		pidFile := filepath.Join(t.TempDir(), "pid")
+
+		start := time.Now()
+		_, err := runCommand(context.Background(), exec.Command("/bin/sh", "-c", "setsid sh -c 'echo $$ > "+pidFile+"; exec sleep 30' & sleep 30"), 200*time.Millisecond)
+		require.Error(t, err)
+		assert.True(t, ee.As(err, new(*timeoutError)))
+		assert.Less(t, time.Since(start), 5*time.Second)
+
+		data, err := os.ReadFile(pidFile) // the escaped process is not killed by runCommand
+		require.NoError(t, err)
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		require.NoError(t, err)
+		_ = syscall.Kill(pid, syscall.SIGKILL)
+	})
+
+	t.Run("interrupt", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := runCommand(ctx, exec.Command("/bin/sh", "-c", "sleep 30"), 0)
+		assert.ErrorIs(t, err, errInterrupted)
+	})
+}
+
+func assertProcessExited(t *testing.T, pidFile string) {
+	t.Helper()
+
+	data, err := os.ReadFile(pidFile)
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		if syscall.Kill(pid, 0) == syscall.ESRCH {
+			return true
+		}
+
+		// killed but not reaped yet (zombie)
+		stat, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+		return err == nil && strings.Contains(string(stat), ") Z ")
+	}, 2*time.Second, 20*time.Millisecond, "process %d should be killed", pid)
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+
+package lintstaged
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes the command run in its own process group,
+// so all the processes it spawns can be terminated together
+func setProcessGroup(p *exec.Cmd) {
+	if p.SysProcAttr == nil {
+		p.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	p.SysProcAttr.Setpgid = true
+}
+
+// terminateProcessGroup sends SIGTERM (or SIGKILL if force) to the process group of the started command
+func terminateProcessGroup(p *exec.Cmd, force bool) error {
+	sig := syscall.SIGTERM
+	if force {
+		sig = syscall.SIGKILL
+	}
+
+	return syscall.Kill(-p.Process.Pid, sig)
+}
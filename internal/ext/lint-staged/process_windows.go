@@ -0,0 +1,12 @@
+package lintstaged
+
+import (
+	"os/exec"
+)
+
+func setProcessGroup(p *exec.Cmd) {}
+
+// terminateProcessGroup kills the process directly since there're no process groups or SIGTERM on Windows
+func terminateProcessGroup(p *exec.Cmd, force bool) error {
+	return p.Process.Kill()
+}
@@ -0,0 +1,390 @@
+package lintstaged
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/mr"
+
+	"github.com/ImSingee/kitty/internal/lib/tl"
+)
+
+const (
+	ReporterJSON  = "json"
+	ReporterJUnit = "junit"
+)
+
+const (
+	reportStatusSuccess = "success"
+	reportStatusFailed  = "failed"
+	reportStatusSkipped = "skipped"
+)
+
+// report is the machine-readable result of a run: config -> rule -> command
+type report struct {
+	Success   bool            `json:"success"`
+	Selection string          `json:"selection"`
+	Duration  float64         `json:"duration"` // in seconds
+	Configs   []*configReport `json:"configs"`
+}
+
+type configReport struct {
+	Path       string        `json:"path"` // relative to the git root
+	Files      []string      `json:"files"`
+	Status     string        `json:"status"`
+	SkipReason string        `json:"skipReason,omitempty"`
+	Duration   float64       `json:"duration"`
+	Rules      []*ruleReport `json:"rules"`
+}
+
+type ruleReport struct {
+	Pattern    string           `json:"pattern"`
+	From       string           `json:"from,omitempty"` // the config of the rule if inherited
+	Files      []string         `json:"files"`
+	Status     string           `json:"status"`
+	SkipReason string           `json:"skipReason,omitempty"`
+	Duration   float64          `json:"duration"`
+	Commands   []*commandReport `json:"commands"`
+}
+
+type commandReport struct {
+	Command    string   `json:"command"`
+	Title      string   `json:"title,omitempty"`
+	Files      []string `json:"files"`
+	Status     string   `json:"status"`
+	SkipReason string   `json:"skipReason,omitempty"`
+	Duration   float64  `json:"duration"`
+
+	execReport               // if the files are not split into chunks
+	Chunks     []*execReport `json:"chunks,omitempty"` // if the files are split into chunks
+}
+
+// execReport is the result of one execution of a command
+type execReport struct {
+	CommandLine string  `json:"commandLine,omitempty"`
+	Status      string  `json:"status,omitempty"` // only for chunks
+	Duration    float64 `json:"duration,omitempty"`
+	ExitCode    *int    `json:"exitCode,omitempty"` // nil if the command didn't exit by itself
+	TimedOut    bool    `json:"timedOut,omitempty"`
+	Error       string  `json:"error,omitempty"`
+	Output      string  `json:"output,omitempty"`
+}
+
+// buildReport builds the report from the generated tasks and their results
+func buildReport(state *State, options *Options, success bool, duration time.Duration) *report {
+	results := make(map[*tl.Task]*tl.Result)
+	if state.result != nil {
+		indexResults(state.result, results)
+	}
+
+	r := &report{
+		Success:   success,
+		Selection: options.SelectedFilesLabel(),
+		Duration:  duration.Seconds(),
+		Configs:   make([]*configReport, 0, len(state.configTasks)),
+	}
+
+	for _, c := range state.configTasks {
+		cr := &configReport{
+			Path:  c.config.Path,
+			Files: c.files.GitRelativePaths(),
+			Rules: make([]*ruleReport, 0, len(c.rules)),
+		}
+		if rel, err := filepath.Rel(state.gitRoot, c.config.Path); err == nil {
+			cr.Path = filepath.ToSlash(rel)
+		}
+
+		for _, rule := range c.rules {
+			rr := &ruleReport{
+				Pattern:  rule.rule.GlobString,
+				From:     rule.inheritedFrom(state, c.config),
+				Files:    rule.files.GitRelativePaths(),
+				Commands: make([]*commandReport, 0, len(rule.commands)),
+			}
+			rr.Status, rr.SkipReason, rr.Duration = taskStatus(results, rule.task, rule.files)
+
+			for _, cmd := range rule.commands {
+				rr.Commands = append(rr.Commands, buildCommandReport(state, results, cmd))
+			}
+
+			cr.Rules = append(cr.Rules, rr)
+		}
+
+		if c.task != nil {
+			cr.Status, cr.SkipReason, cr.Duration = taskStatus(results, c.task, c.files)
+		} else {
+			cr.Status, cr.SkipReason, cr.Duration = aggregateStatus(cr.Rules)
+		}
+
+		r.Configs = append(r.Configs, cr)
+	}
+
+	return r
+}
+
+func buildCommandReport(state *State, results map[*tl.Task]*tl.Result, cmd *commandTasks) *commandReport {
+	cr := &commandReport{
+		Command: cmd.cmd.Command,
+		Title:   cmd.cmd.Title,
+		Files:   cmd.files.GitRelativePaths(),
+	}
+	cr.Status, cr.SkipReason, cr.Duration = taskStatus(results, cmd.task, cmd.files)
+
+	if cmd.task.Id() == "" { // never used by the task list
+		return cr
+	}
+
+	v, ok := state.taskResults.Load(cmd.task.Id())
+	if !ok {
+		return cr
+	}
+	result := v.(*TaskResult)
+
+	if len(result.chunks) == 0 {
+		cr.execReport = *buildExecReport(result)
+		return cr
+	}
+
+	cr.Chunks = mr.Map(result.chunks, func(chunk *TaskResult, index int) *execReport {
+		if chunk == nil {
+			return &execReport{Status: reportStatusSkipped}
+		}
+
+		er := buildExecReport(chunk)
+		er.Status = reportStatusSuccess
+		if chunk.err != nil {
+			er.Status = reportStatusFailed
+		}
+		return er
+	})
+
+	return cr
+}
+
+func buildExecReport(result *TaskResult) *execReport {
+	er := &execReport{
+		CommandLine: result.fullCommandAndArgs,
+		Duration:    result.duration.Seconds(),
+		ExitCode:    exitCode(result.err),
+		TimedOut:    result.timedOut,
+		Output:      strings.ToValidUTF8(string(bytes.TrimSpace(result.output)), "\uFFFD"),
+	}
+	if result.err != nil {
+		er.Error = result.err.Error()
+	}
+	return er
+}
+
+// exitCode returns the exit code of the command, or nil if it was killed or not started
+func exitCode(err error) *int {
+	code := 0
+	if err == nil {
+		return &code
+	}
+
+	var exitErr *exec.ExitError
+	if ee.As(err, &exitErr) && exitErr.Exited() {
+		code = exitErr.ExitCode()
+		return &code
+	}
+
+	return nil
+}
+
+func indexResults(result *tl.Result, index map[*tl.Task]*tl.Result) {
+	if result.Task != nil {
+		index[result.Task] = result
+	}
+
+	for _, sub := range result.SubResults {
+		if sub != nil {
+			indexResults(sub, index)
+		}
+	}
+}
+
+// taskStatus returns the status, the skip reason and the duration (in seconds) of the task
+func taskStatus(results map[*tl.Task]*tl.Result, task *tl.Task, files Files) (string, string, float64) {
+	result, ok := results[task]
+	switch {
+	case (!ok || !result.Enabled) && len(files) == 0:
+		return reportStatusSkipped, "no files", 0
+	case !ok || !result.Enabled:
+		return reportStatusSkipped, "not run", 0
+	case result.Error:
+		return reportStatusFailed, "", result.Duration.Seconds()
+	case result.Skipped:
+		reason := result.SkipReason
+		if reason == "" && len(files) == 0 {
+			reason = "no files"
+		}
+		return reportStatusSkipped, reason, result.Duration.Seconds()
+	default:
+		return reportStatusSuccess, "", result.Duration.Seconds()
+	}
+}
+
+// aggregateStatus returns the status of a config without its own task from its rules
+func aggregateStatus(rules []*ruleReport) (string, string, float64) {
+	status, reason, duration := reportStatusSkipped, "no rules", 0.0
+
+	for _, rule := range rules {
+		duration = max(duration, rule.Duration) // the rules may run concurrently
+
+		switch {
+		case rule.Status == reportStatusFailed:
+			status, reason = reportStatusFailed, ""
+		case rule.Status == reportStatusSuccess && status != reportStatusFailed:
+			status, reason = reportStatusSuccess, ""
+		case status == reportStatusSkipped:
+			reason = rule.SkipReason
+		}
+	}
+
+	return status, reason, duration
+}
+
+// writeReport writes the report in the format of the reporter to the report file
+func writeReport(r *report, reporter, filename string) error {
+	var data []byte
+	var err error
+
+	switch reporter {
+	case ReporterJSON:
+		data, err = json.MarshalIndent(r, "", "  ")
+	case ReporterJUnit:
+		data, err = xml.MarshalIndent(buildJUnitReport(r), "", "  ")
+		data = append([]byte(xml.Header), data...)
+	default:
+		return fmt.Errorf("unknown reporter `%s`", reporter)
+	}
+	if err != nil {
+		return ee.Wrap(err, "cannot encode report")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return ee.Wrap(err, "cannot create directory for report file")
+	}
+	if err := os.WriteFile(filename, append(data, '\n'), 0644); err != nil {
+		return ee.Wrap(err, "cannot write report file")
+	}
+
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// buildJUnitReport converts the report to JUnit XML, with a test suite for each rule and a test case for each command
+func buildJUnitReport(r *report) *junitTestSuites {
+	suites := &junitTestSuites{
+		Name: "lint-staged",
+		Time: junitTime(r.Duration),
+	}
+
+	for _, c := range r.Configs {
+		for _, rule := range c.Rules {
+			suite := junitTestSuite{
+				Name: c.Path + " " + rule.Pattern,
+				Time: junitTime(rule.Duration),
+			}
+
+			for _, cmd := range rule.Commands {
+				tc := junitTestCase{
+					Name:      cmd.Command,
+					ClassName: c.Path + " " + rule.Pattern,
+					Time:      junitTime(cmd.Duration),
+					SystemOut: junitOutput(cmd),
+				}
+
+				switch cmd.Status {
+				case reportStatusFailed:
+					message := "failed"
+					if cmd.Error != "" {
+						message = cmd.Error
+					}
+					tc.Failure = &junitMessage{Message: message, Text: tc.SystemOut}
+					suite.Failures++
+				case reportStatusSkipped:
+					tc.Skipped = &junitMessage{Message: cmd.SkipReason}
+					suite.Skipped++
+				}
+
+				suite.Tests++
+				suite.TestCases = append(suite.TestCases, tc)
+			}
+
+			suites.Tests += suite.Tests
+			suites.Failures += suite.Failures
+			suites.Skipped += suite.Skipped
+			suites.Suites = append(suites.Suites, suite)
+		}
+	}
+
+	return suites
+}
+
+// junitOutput returns the command lines and outputs of the command
+func junitOutput(cmd *commandReport) string {
+	execs := cmd.Chunks
+	if len(execs) == 0 {
+		execs = []*execReport{&cmd.execReport}
+	}
+
+	parts := make([]string, 0, len(execs))
+	for _, e := range execs {
+		if e.CommandLine == "" {
+			continue
+		}
+
+		part := "$ " + e.CommandLine
+		if e.Output != "" {
+			part += "\n" + e.Output
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, "\n\n")
+}
+
+func junitTime(seconds float64) string {
+	return fmt.Sprintf("%.3f", seconds)
+}
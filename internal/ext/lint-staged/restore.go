@@ -0,0 +1,220 @@
+package lintstaged
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/mr"
+	"github.com/ImSingee/go-ex/pp"
+	"github.com/ImSingee/go-ex/set"
+)
+
+// If lint-staged is killed while running the tasks, the backup stash (and the patch of the unstaged changes)
+// are left behind. --restore restores the original state from them, like the revert after a failed task.
+
+// backup is what a run of lint-staged leaves before running the tasks, see gitWorkflow.prepare
+type backup struct {
+	stash string // the index of the backup stash, empty if not found
+	patch string // the path of the patch of the unstaged changes, empty if not found
+}
+
+func (b *backup) empty() bool {
+	return b.stash == "" && b.patch == ""
+}
+
+// findBackup finds the backup left by an interrupted run
+func (g *gitWorkflow) findBackup() (*backup, error) {
+	stash, err := g.findBackupStash()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &backup{stash: stash}
+	if patch := g.getGitConfigDirFilepath(PatchUnstaged); fileExists(patch) {
+		b.patch = patch
+	}
+	return b, nil
+}
+
+// warnStaleBackup warns if there's a backup left by an interrupted run, which would be mixed up with the new one
+func warnStaleBackup(gitDir, gitConfigDir string) {
+	g := &gitWorkflow{root: gitDir, gitConfigDir: gitConfigDir, logger: slog.Default()}
+
+	b, err := g.findBackup()
+	if err != nil {
+		slog.Debug("Cannot check the stale backup", "error", err)
+		return
+	}
+	if !b.empty() {
+		pp.EYellowPrintf("%s Found the backup of an interrupted lint-staged run, use `kitty @lint-staged --restore` to restore it.\n", warning)
+	}
+}
+
+// restoreBackup restores the original index, working tree and merge status from the backup of an interrupted run,
+// with --dry-run it only shows the backup
+func restoreBackup(options *Options) error {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ee.Wrap(err, "cannot get current working directory")
+	}
+
+	gitDir, gitConfigDir, err := resolveGitRepo(cwd)
+	if err != nil || gitDir == "" {
+		pp.ERedPrintln(x, "Current directory is not a git directory!")
+		return ee.Phantom
+	}
+
+	g := &gitWorkflow{root: gitDir, gitConfigDir: gitConfigDir, logger: slog.Default()}
+
+	b, err := g.findBackup()
+	if err != nil {
+		return err
+	}
+	if b.empty() {
+		pp.BluePrintln(info, "No backup of lint-staged found.")
+		return nil
+	}
+
+	g.showBackup(b)
+	if options.DryRun {
+		return nil
+	}
+
+	if b.stash == "" {
+		// the unstaged changes were hidden without the backup stash (--stash=false)
+		if err := g.restoreUnstagedChanges(); err != nil {
+			return ee.Wrapf(err, "cannot restore the unstaged changes, please apply %s manually", b.patch)
+		}
+		_ = os.Remove(b.patch)
+
+		pp.GreenPrintln(yes, "Restored the unstaged changes.")
+		return nil
+	}
+
+	// the reset would discard the work done since the interrupted run
+	if !options.Force {
+		if err := g.checkRestorable(b.stash); err != nil {
+			pp.ERedPrintf("%s Cannot restore the backup: %s.\n", x, err.Error())
+			pp.ERedPrintln("Please commit or stash the changes first, or use `kitty @lint-staged --restore --force` to discard them.")
+			return ee.Phantom
+		}
+	}
+
+	// the merge status is still there until the reset
+	if err := g.backupMergeStatus(); err != nil {
+		return err
+	}
+	g.deletedFiles, err = g.getStashDeletedFiles(b.stash)
+	if err != nil {
+		return err
+	}
+
+	state := getInitialState(cwd, options)
+	if err := g.restoreOriginalState(state); err != nil {
+		return ee.Wrapf(err, "cannot restore the original state, the backup is kept in stash@{%s}", b.stash)
+	}
+	if err := g.cleanup(); err != nil {
+		return err
+	}
+
+	pp.GreenPrintln(yes, "Restored the original state.")
+	return nil
+}
+
+// checkRestorable returns an error if restoring the backup stash would discard anything not in it:
+// HEAD moved since the backup, or the tracked files outside the backup are changed
+func (g *gitWorkflow) checkRestorable(stash string) error {
+	ref := "stash@{" + stash + "}"
+
+	head, err := g.execGit("rev-parse", "HEAD")
+	if err != nil {
+		return ee.Wrap(err, "cannot get HEAD")
+	}
+	base, err := g.execGit("rev-parse", ref+"^1")
+	if err != nil {
+		return ee.Wrap(err, "cannot get the base of the backup stash")
+	}
+	if head != base {
+		return fmt.Errorf("HEAD has moved since the backup was created on %s", shortHash(base))
+	}
+
+	backupFiles := set.New[string]()
+	for _, args := range [][]string{{ref + "^1", ref}, {ref + "^1", ref + "^2"}} {
+		files, err := g.execGitZ(append([]string{"diff", "--name-only", "-z", "--no-renames"}, args...)...)
+		if err != nil {
+			return ee.Wrap(err, "cannot get the files in the backup stash")
+		}
+		backupFiles.Add(files...)
+	}
+
+	changedFiles := set.New[string]()
+	for _, args := range [][]string{{"HEAD"}, {"--cached", "HEAD"}} {
+		files, err := g.execGitZ(append([]string{"diff", "--name-only", "-z", "--no-renames", "--ignore-submodules"}, args...)...)
+		if err != nil {
+			return ee.Wrap(err, "cannot get the changed files")
+		}
+		changedFiles.Add(files...)
+	}
+	if others := changedFiles.Difference(backupFiles).All(); len(others) != 0 {
+		sort.Strings(others)
+		return fmt.Errorf("the files outside the backup are changed since the interrupted run: %s", strings.Join(others, ", "))
+	}
+
+	return nil
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// showBackup prints the files in the backup
+func (g *gitWorkflow) showBackup(b *backup) {
+	if b.stash != "" {
+		created, _ := g.execGit("show", "--no-patch", "--format=%cr", "stash@{"+b.stash+"}")
+		pp.Printf("Backup stash stash@{%s} (%s, created %s):\n", b.stash, stashMessage, created)
+
+		stat, err := g.execGit("stash", "show", "--stat", "--no-color", b.stash)
+		if err != nil {
+			stat = err.Error()
+		}
+		pp.Println(stat)
+	}
+
+	if b.patch != "" {
+		pp.Printf("Unstaged changes of the partially staged files (%s):\n", b.patch)
+
+		stat, err := g.execGit("apply", "--stat", b.patch)
+		if err != nil {
+			stat = err.Error()
+		}
+		pp.Println(stat)
+	}
+}
+
+// getStashDeletedFiles returns the (absolute) files deleted in the working tree but not in the index of the stash,
+// which are resurrected when the stash is applied
+func (g *gitWorkflow) getStashDeletedFiles(stash string) ([]string, error) {
+	ref := "stash@{" + stash + "}"
+
+	files, err := g.execGitZ("diff", "--name-only", "-z", "--no-renames", "--diff-filter=D", ref+"^2", ref)
+	if err != nil {
+		return nil, ee.Wrap(err, "cannot get deleted files in the backup stash")
+	}
+
+	return mr.Map(files, func(file string, _ int) string {
+		return filepath.Join(g.root, file)
+	}), nil
+}
+
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return err == nil
+}
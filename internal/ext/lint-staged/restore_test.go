@@ -0,0 +1,123 @@
+package lintstaged
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// interruptedRun leaves the repository like a run of lint-staged killed while running the tasks
+func interruptedRun(t *testing.T, repo string, withStash bool) *gitWorkflow {
+	t.Helper()
+
+	g := &gitWorkflow{root: repo, gitConfigDir: filepath.Join(repo, ".git"), manageIndex: true, logger: slog.Default()}
+	state := getInitialState(repo, &Options{})
+	state.shouldBackup = withStash
+
+	require.NoError(t, g.prepare(state))
+	require.NoError(t, g.hideUnstagedChanges())
+	writeFile(t, repo, "a.txt", "a fixed by task\n")
+
+	return g
+}
+
+func TestRestoreBackup(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, repo, "a.txt", "a\n")
+	writeFile(t, repo, "b.txt", "b\n")
+	writeFile(t, repo, "d.txt", "d\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a staged\n")
+	gitRun(t, repo, "add", "a.txt")
+	writeFile(t, repo, "a.txt", "a staged\na unstaged\n")
+	writeFile(t, repo, "b.txt", "b unstaged\n")
+	require.NoError(t, os.Remove(filepath.Join(repo, "d.txt")))
+	writeFile(t, repo, ".git/MERGE_MSG", "merge message\n")
+
+	g := interruptedRun(t, repo, true)
+	b, err := g.findBackup()
+	require.NoError(t, err)
+	assert.Equal(t, "0", b.stash)
+	assert.FileExists(t, b.patch)
+
+	require.NoError(t, runInDir(t, repo, &Options{Restore: true, DryRun: true}))
+	assert.Equal(t, "a fixed by task\n", readFile(t, repo, "a.txt"), "nothing is restored with --dry-run")
+
+	require.NoError(t, runInDir(t, repo, &Options{Restore: true}))
+	assert.Equal(t, "a staged\na unstaged\n", readFile(t, repo, "a.txt"))
+	assert.Equal(t, "a staged\n", gitOutput(t, repo, "show", ":a.txt"))
+	assert.Equal(t, "b unstaged\n", readFile(t, repo, "b.txt"))
+	assert.NoFileExists(t, filepath.Join(repo, "d.txt"), "the deleted files are not resurrected")
+	assert.Equal(t, "merge message\n", readFile(t, repo, ".git/MERGE_MSG"))
+
+	assert.Equal(t, "", gitOutput(t, repo, "stash", "list"))
+	assert.NoFileExists(t, b.patch)
+
+	require.NoError(t, runInDir(t, repo, &Options{Restore: true}), "no backup")
+}
+
+func TestRestoreBackupPatchOnly(t *testing.T) {
+	repo := newTestRepo(t)
+	writeFile(t, repo, "a.txt", "a\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a staged\n")
+	gitRun(t, repo, "add", "a.txt")
+	writeFile(t, repo, "a.txt", "a staged\na unstaged\n")
+
+	interruptedRun(t, repo, false)
+	writeFile(t, repo, "a.txt", "a staged\n") // the modification is reverted, or the patch cannot apply
+
+	require.NoError(t, runInDir(t, repo, &Options{Restore: true}))
+	assert.Equal(t, "a staged\na unstaged\n", readFile(t, repo, "a.txt"))
+	assert.NoFileExists(t, filepath.Join(repo, ".git", PatchUnstaged))
+}
+
+func TestRestoreBackupRefused(t *testing.T) {
+	setup := func(t *testing.T) string {
+		repo := newTestRepo(t)
+		writeFile(t, repo, "a.txt", "a\n")
+		writeFile(t, repo, "b.txt", "b\n")
+		gitRun(t, repo, "add", ".")
+		gitRun(t, repo, "commit", "-m", "initial")
+
+		writeFile(t, repo, "a.txt", "a staged\n")
+		gitRun(t, repo, "add", "a.txt")
+		interruptedRun(t, repo, true)
+		return repo
+	}
+
+	t.Run("changes outside the backup", func(t *testing.T) {
+		repo := setup(t)
+		writeFile(t, repo, "b.txt", "b edited after the interrupted run\n")
+
+		require.Error(t, runInDir(t, repo, &Options{Restore: true}))
+		assert.Equal(t, "b edited after the interrupted run\n", readFile(t, repo, "b.txt"), "the unrelated work is kept")
+		assert.Equal(t, "a fixed by task\n", readFile(t, repo, "a.txt"))
+		assert.NotEqual(t, "", gitOutput(t, repo, "stash", "list"), "the backup is kept")
+
+		require.NoError(t, runInDir(t, repo, &Options{Restore: true, Force: true}))
+		assert.Equal(t, "a staged\n", readFile(t, repo, "a.txt"))
+		assert.Equal(t, "b\n", readFile(t, repo, "b.txt"))
+	})
+
+	t.Run("HEAD moved", func(t *testing.T) {
+		repo := setup(t)
+		writeFile(t, repo, "c.txt", "c\n")
+		gitRun(t, repo, "add", "c.txt")
+		gitRun(t, repo, "commit", "--quiet", "-m", "newer")
+
+		require.Error(t, runInDir(t, repo, &Options{Restore: true}))
+		assert.Equal(t, "c\n", gitOutput(t, repo, "show", "HEAD:c.txt"))
+		assert.Equal(t, "c\n", readFile(t, repo, "c.txt"), "the newer commit is not reverted")
+		assert.Equal(t, "a fixed by task\n", readFile(t, repo, "a.txt"))
+		assert.NotEqual(t, "", gitOutput(t, repo, "stash", "list"), "the backup is kept")
+	})
+}
@@ -12,15 +12,14 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ImSingee/go-ex/ee"
 	"github.com/ImSingee/go-ex/exbytes"
-	"github.com/ImSingee/go-ex/exlist"
 	"github.com/ImSingee/go-ex/exstrings"
 	"github.com/ImSingee/go-ex/mr"
 	"github.com/ImSingee/go-ex/pp"
 
-	"github.com/ImSingee/kitty/internal/lib/glob"
 	"github.com/ImSingee/kitty/internal/lib/shells"
 	"github.com/ImSingee/kitty/internal/lib/tl"
 )
@@ -43,6 +42,8 @@ func runAll(options *Options) (*State, error) {
 	}
 	ctx.gitRoot = gitDir
 
+	warnStaleBackup(gitDir, gitConfigDir)
+
 	// Test whether we have any commits or not.
 	// Stashing must be disabled with no initial commit.
 	_, err = execGit([]string{"log", "-1"}, gitDir)
@@ -51,26 +52,51 @@ func runAll(options *Options) (*State, error) {
 	// Lint-staged will create a backup stash only when there's an initial commit,
 	// and when using the default staged-file selection.
 	ctx.shouldBackup = hasInitialCommit && options.Stash && options.UsesIndex()
-	if !ctx.shouldBackup {
+	if !ctx.shouldBackup && !options.DryRun {
 		pp.EYellowPrintln(skippingBackup(hasInitialCommit, options))
 	}
 
 	// get selected files (relative path)
-	relativeFiles, err := getSelectedFiles(options, gitDir)
+	selectedFiles, err := getSelectedFiles(options, gitDir)
 	if err != nil {
 		ctx.errors.Add(ErrGetSelectedFiles)
 		return ctx, ee.Wrap(err, "cannot get selected files")
 	}
-	slog.Debug("Loaded selected files in git", "files", relativeFiles, "label", options.SelectedFilesLabel())
+	if options.RecurseSubmodules {
+		ctx.submodules, err = getSubmodules(gitDir)
+		if err == nil {
+			var submoduleFiles []selectedFile
+			submoduleFiles, err = getSubmoduleFiles(options, gitDir, ctx.submodules)
+			selectedFiles = append(selectedFiles, submoduleFiles...)
+		}
+		if err != nil {
+			ctx.errors.Add(ErrGetSelectedFiles)
+			return ctx, ee.Wrap(err, "cannot get selected files")
+		}
+		slog.Debug("Resolved submodules", "submodules", ctx.submodules)
+	}
+	slog.Debug("Loaded selected files in git", "files", selectedFiles, "label", options.SelectedFilesLabel())
+
+	// the deleted files are only passed to the rules running on them, all other steps use the existing files
+	allFiles := newSelectedFiles(ctx, selectedFiles)
+	files := Files(mr.Filter(allFiles, func(in *File, index int) bool {
+		return in.Status() != FileStatusDeleted
+	}))
+	noFiles := func() (*State, error) {
+		if options.DryRun {
+			return ctx, printPlan(buildPlan(ctx, options, files), options.JSON)
+		}
 
-	files := NewFiles(ctx, relativeFiles)
-	// If there are no files avoid executing any lint-staged logic
-	if len(files) == 0 {
 		pp.BluePrintln(info, "No "+options.SelectedFilesLabel()+" found.")
 		return ctx, nil
 	}
 
-	foundConfigs, err := searchConfigs(cwd, gitDir, options.ConfigPath)
+	// If there are no files avoid executing any lint-staged logic
+	if len(allFiles) == 0 {
+		return noFiles()
+	}
+
+	foundConfigs, err := searchConfigs(cwd, gitDir, options.ConfigPath, ctx.submodules)
 	if err != nil {
 		return ctx, ee.Wrap(err, "cannot load configs")
 	}
@@ -80,7 +106,19 @@ func runAll(options *Options) (*State, error) {
 		return ctx, ee.New("no configuration found")
 	}
 
-	filesByConfig := groupFilesByConfig(foundConfigs, files)
+	if len(files) != len(allFiles) && !hasRulesOn(foundConfigs, FileStatusDeleted) {
+		allFiles = files
+		if len(files) == 0 {
+			return noFiles()
+		}
+	}
+
+	// an explicit config is used for all files
+	configSubmodules := ctx.submodules
+	if options.ConfigPath != "" {
+		configSubmodules = nil
+	}
+	filesByConfig := groupFilesByConfig(foundConfigs, allFiles, gitDir, configSubmodules)
 	if debug() {
 		usedConfigsCount := len(filesByConfig)
 		debugFilesByConfig := make(map[string][]string, len(filesByConfig))
@@ -98,8 +136,63 @@ func runAll(options *Options) (*State, error) {
 		pp.ERedPrintf("%s Cannot load ignore rules (%s)!\n", x, err.Error())
 		return ctx, ee.Phantom
 	}
+	ctx.facts = newFactsCache(gitDir, ctx.submodules, options.maxArgLength, options.SelectionMode() == SelectionModeStaged)
+	ctx.workspaces = newWorkspaceFinder(gitDir)
+	if !options.NoCache && !options.DryRun {
+		ctx.cache = newResultCache(gitConfigDir, gitDir)
+	}
+	ctx.tools = newToolResolver(gitDir, !options.DryRun)
+
+	if options.Isolated && !options.DryRun {
+		ctx.isolatedRoot, err = os.MkdirTemp("", "kitty-lint-staged-")
+		if err != nil {
+			return ctx, ee.Wrap(err, "cannot create temporary directory")
+		}
+		defer os.RemoveAll(ctx.isolatedRoot)
+	}
+
+	// the diff is only computed if any command needs it
+	if usesChangedLines(foundConfigs) {
+		ctx.changedLines, err = getChangedLines(options, gitDir, ctx.submodules, files)
+		if err != nil {
+			pp.EYellowPrintf("%s Cannot get the changed lines (%s)\n", warning, err.Error())
+		}
+	}
+
+	subTasks := generateTasksToRun(ctx, filesByConfig, options)
+
+	// only show what would run, without touching the stash or the index
+	if options.DryRun {
+		return ctx, printPlan(buildPlan(ctx, options, allFiles), options.JSON)
+	}
+
+	ctx.tmpDir, err = os.MkdirTemp("", "kitty-lint-staged-")
+	if err != nil {
+		return ctx, ee.Wrap(err, "cannot create temporary directory")
+	}
+	defer os.RemoveAll(ctx.tmpDir)
+
+	if ctx.changedLines != nil {
+		filename, err := writeChangedLinesFile(ctx.tmpDir, gitDir, ctx.changedLines)
+		if err != nil {
+			return ctx, err
+		}
+		ctx.env = append(ctx.env, ChangedLinesEnv+"="+filename)
+	}
+
+	// without fixers nothing is modified, so the stash and the index are left alone
+	if options.Isolated && !ctx.hasFixers() {
+		ctx.inPlace = false
+		ctx.shouldBackup = false
+	}
 
-	chunkedFilenamesArray := chunkFiles(files.RelativePathsToGitRoot(), defaultMaxArgLength())
+	// the backup stash and the index of the repository leave the submodules alone
+	topLevelFiles := ctx.topLevelFiles(files)
+	if len(topLevelFiles) == 0 {
+		ctx.inPlace = false
+		ctx.shouldBackup = false
+	}
+	chunkedFilenamesArray := chunkFiles(topLevelFiles.RelativePathsToGitRoot(), options.maxArgLength)
 	slog.Debug("Get chunked filenames arrays", "groupCount", len(chunkedFilenamesArray), "arrays", chunkedFilenamesArray)
 
 	gw := &gitWorkflow{
@@ -132,14 +225,15 @@ func runAll(options *Options) (*State, error) {
 		}
 	}
 
-	subTasks := generateTasksToRun(ctx, filesByConfig, options)
-
 	tasks := []*tl.Task{
 		{
 			Title: "Preparing lint-staged...",
 			Run: func(callback tl.TaskCallback) error {
 				return gw.prepare(ctx)
 			},
+			Enable: func() bool {
+				return ctx.inPlace
+			},
 			PostRun: handleInternalError,
 		},
 		{
@@ -154,6 +248,21 @@ func runAll(options *Options) (*State, error) {
 				ctx.errors.Add(ErrHideUnstagedChanges)
 			}),
 		},
+		{
+			Title: "Checking out staged files to a temporary directory...",
+			Run: func(callback tl.TaskCallback) error {
+				if ctx.internalError {
+					callback.Skip("internal error")
+					return nil
+				}
+
+				return ctx.checkoutIndex()
+			},
+			Enable: func() bool {
+				return ctx.isolatedRoot != ""
+			},
+			PostRun: handleInternalError,
+		},
 		{
 			Title: "Running tasks for selected files...",
 			Run: func(callback tl.TaskCallback) error {
@@ -162,14 +271,36 @@ func runAll(options *Options) (*State, error) {
 					return nil
 				}
 
+				// the unstaged changes are hidden already, so the staged files can be compared with the index
+				if options.FailOnChanges {
+					snapshot, err := takeSnapshot(gitDir, topLevelFiles, options.UsesIndex(), options.maxArgLength)
+					if err != nil {
+						return err
+					}
+					ctx.snapshot = snapshot
+				}
+
+				// configs and rules run concurrently, commands of one rule run one by one
 				callback.AddSubTaskList(tl.NewTaskList(
 					subTasks,
 					tl.WithExitOnError(true),
+					tl.WithConcurrent(options.concurrency),
 				))
 
-				return nil // TODO concurrent
+				return nil
+			},
+			PostRun: func(result *tl.Result) {
+				if ctx.snapshot != nil {
+					defer ctx.snapshot.remove()
+
+					if err := checkModifications(ctx, options); err != nil {
+						result.Error = true
+						result.Err = err
+					}
+				}
+
+				handleTaskError(result)
 			},
-			PostRun: handleTaskError,
 			Options: []tl.OptionApplier{
 				tl.WithExitOnError(false),
 			},
@@ -190,6 +321,9 @@ func runAll(options *Options) (*State, error) {
 
 				return gw.applyModifications(ctx)
 			},
+			Enable: func() bool {
+				return ctx.inPlace
+			},
 			PostRun: handleInternalError,
 		},
 		{
@@ -257,7 +391,7 @@ func runAll(options *Options) (*State, error) {
 	}
 
 	runner := tl.New(tasks, tl.WithExitOnError(true))
-	err = runner.Run()
+	ctx.result, err = runner.Run()
 
 	printTaskResults(ctx.taskResults, options)
 
@@ -265,194 +399,379 @@ func runAll(options *Options) (*State, error) {
 }
 
 func generateTasksToRun(ctx *State, config map[*Config]Files, options *Options) []*tl.Task {
-	type ConfigEntries struct {
-		Config *Config
-		Files  Files
-		Tasks  []*tl.Task
-	}
-
-	all := make([]*ConfigEntries, 0, len(config))
+	all := make([]*configTasks, 0, len(config))
 
 	for config, files := range config {
-		tasks := generateTasksForConfig(ctx, config, files, options)
-
-		all = append(all, &ConfigEntries{
-			Config: config,
-			Files:  files,
-			Tasks:  tasks,
-		})
+		all = append(all, generateTasksForConfig(ctx, config, files, options))
 	}
 
 	sort.Slice(all, func(i, j int) bool {
-		return strings.Compare(all[i].Config.Path, all[j].Config.Path) < 0
+		return strings.Compare(all[i].config.Path, all[j].config.Path) < 0
 	})
 
+	ctx.configTasks = all
+
 	if len(all) == 1 {
-		return all[0].Tasks
+		return all[0].tasks()
 	}
 
-	return mr.Map(all, func(in *ConfigEntries, index int) *tl.Task {
-		configPath := in.Config.Path
+	return mr.Map(all, func(in *configTasks, index int) *tl.Task {
+		configPath := in.config.Path
 
 		if relativeConfigPath, _ := filepath.Rel(ctx.wd, configPath); relativeConfigPath != "" {
 			configPath = relativeConfigPath
 		}
 
-		return &tl.Task{
-			Title: configPath + symGray(fmt.Sprintf(" - %d files", len(in.Files))),
+		in.task = &tl.Task{
+			Title: configPath + symGray(fmt.Sprintf(" - %d files", len(in.files))),
 			Run: func(callback tl.TaskCallback) error {
-				callback.AddSubTask(in.Tasks...)
+				callback.AddSubTaskList(tl.NewTaskList(in.tasks(), tl.WithConcurrent(options.concurrency)))
 				return nil
 			},
 		}
+		return in.task
 	})
 }
 
-func generateTasksForConfig(ctx *State, config *Config, files Files, options *Options) []*tl.Task {
-	type RuleConfigEntries struct {
-		Rule string
-	}
-
-	if len(config.Rules) == 0 {
-		return []*tl.Task{{
-			Title: "No Rules",
-			Run: func(callback tl.TaskCallback) error {
-				return nil
-			},
-		}}
+func generateTasksForConfig(ctx *State, config *Config, files Files, options *Options) *configTasks {
+	return &configTasks{
+		config: config,
+		files:  files,
+		rules: mr.Map(config.effectiveRules(), func(r configRule, index int) *ruleTasks {
+			ruleFiles := files
+			if r.config != config { // not the files outside the directory, which are only given to the config by its ../ patterns
+				d := filepath.Dir(config.Path) + string(filepath.Separator)
+				ruleFiles = mr.Filter(files, func(in *File, index int) bool { return strings.HasPrefix(in.AbsolutePath(), d) })
+			}
+			return generateTaskForRule(ctx, r.config, r.rule, ruleFiles, options)
+		}),
 	}
+}
 
+func generateTaskForRule(ctx *State, config *Config, rule *Rule, files Files, options *Options) *ruleTasks {
 	wd := filepath.Dir(config.Path)
 
-	if len(config.Rules) == 1 {
-		return []*tl.Task{generateTaskForRule(ctx, wd, config.Rules[0], files, options)}
-	}
-
-	return mr.Map(config.Rules, func(rule *Rule, index int) *tl.Task {
-		return generateTaskForRule(ctx, wd, rule, files, options)
-	})
-}
-
-func generateTaskForRule(ctx *State, wd string, rule *Rule, files Files, options *Options) *tl.Task {
 	files = mr.Filter(files, func(in *File, index int) bool {
-		return strings.HasPrefix(in.AbsolutePath(), wd+string(filepath.Separator))
+		return !ctx.ignoreChecker.ShouldIgnore(in.GitRelativePath()) && rule.runsOn(in.Status())
 	})
 	files = mr.Filter(files, func(in *File, index int) bool {
-		return !ctx.ignoreChecker.ShouldIgnore(in.GitRelativePath())
-	})
-	files = mr.Filter(files, func(in *File, index int) bool {
-		// TODO the glob only support simple case now, will make it enhance
-		return glob.Match(rule.GlobString, rule.Glob, in.AbsolutePath())
+		return rule.matches(wd, in)
 	})
+	if rule.Filter != nil { // never matches the deleted files
+		files = ctx.facts.filter(rule.Filter, mr.Filter(files, func(in *File, index int) bool {
+			return in.Status() != FileStatusDeleted
+		}))
+	}
 
 	suffix := fmt.Sprintf(" - %d files", len(files))
 	if len(files) == 0 {
 		suffix = " - no files"
 	}
 
-	cmdTasks := mr.Map(rule.Commands, func(cmd *Command, index int) *tl.Task {
-		return generateTaskForCommand(ctx, wd, cmd, files, options)
-	})
+	commandTaskList := func(commands []*commandTasks) []*tl.Task {
+		return mr.Map(commands, func(in *commandTasks, index int) *tl.Task { return in.task })
+	}
 
-	return &tl.Task{
-		Title: rule.GlobString + symGray(suffix),
-		Run: func(callback tl.TaskCallback) error {
-			if len(files) == 0 {
-				callback.Skip("")
-				return nil
-			}
+	// with the workspace option, the commands run in each workspace on its files, and the workspaces run concurrently
+	var commands []*commandTasks
+	var workspaceTasks []*tl.Task
+	if rule.Workspace == nil {
+		commands = mr.Map(rule.Commands, func(cmd *Command, index int) *commandTasks {
+			return generateTaskForCommand(ctx, config, cmd, wd, files, options)
+		})
+	} else {
+		dirs, filesByWorkspace := ctx.workspaces.groupFilesByWorkspace(files, rule.Workspace, wd)
+		for _, dir := range dirs {
+			dir, workspaceFiles := dir, filesByWorkspace[dir]
+
+			workspaceCommands := mr.Map(rule.Commands, func(cmd *Command, index int) *commandTasks {
+				return generateTaskForCommand(ctx, config, cmd, dir, workspaceFiles, options)
+			})
+			commands = append(commands, workspaceCommands...)
+
+			workspaceTasks = append(workspaceTasks, &tl.Task{
+				Title: gitRelative(ctx, dir) + symGray(fmt.Sprintf(" - %d files", len(workspaceFiles))),
+				Run: func(callback tl.TaskCallback) error {
+					callback.AddSubTask(commandTaskList(workspaceCommands)...)
+					return nil
+				},
+			})
+		}
+	}
 
-			callback.AddSubTask(cmdTasks...)
-			return nil
-		},
-		PostRun: func(result *tl.Result) {
-			if !result.Error && !options.Verbose {
-				result.Hide = true
-			}
+	return &ruleTasks{
+		config:   config,
+		rule:     rule,
+		files:    files,
+		commands: commands,
+		task: &tl.Task{
+			Title: rule.GlobString + symGray(suffix),
+			Run: func(callback tl.TaskCallback) error {
+				if len(files) == 0 {
+					callback.Skip("")
+					return nil
+				}
+
+				if workspaceTasks != nil {
+					callback.AddSubTaskList(tl.NewTaskList(workspaceTasks, tl.WithConcurrent(options.concurrency)))
+					return nil
+				}
+
+				callback.AddSubTask(commandTaskList(commands)...)
+				return nil
+			},
+			PostRun: func(result *tl.Result) {
+				if !result.Error && !options.Verbose {
+					result.Hide = true
+				}
+			},
 		},
 	}
 }
 
-func generateTaskForCommand(state *State, wd string, cmd *Command, onFiles Files, options *Options) *tl.Task {
-	return &tl.Task{
-		Title: cmd.Command + symGray(fmt.Sprintf(" - %d files", len(onFiles))),
+// generateTaskForCommand generates the task of the command on the files,
+// wd is the config's directory, or the workspace with the workspace option
+func generateTaskForCommand(state *State, config *Config, cmd *Command, wd string, onFiles Files, options *Options) *commandTasks {
+	title := cmd.Title
+	if title == "" {
+		title = cmd.Command
+	}
+
+	dir := wd
+	if cmd.Cwd != "" {
+		dir = filepath.Join(wd, cmd.Cwd)
+	}
+
+	// the read-only commands see the staged content only, the fixers keep running in place
+	isolated := options.Isolated && !cmd.Fixer
+	runDir, runFiles := dir, onFiles
+	if isolated && state.isolatedRoot != "" {
+		runDir, runFiles = state.isolatedPath(dir), state.isolatedFiles(onFiles)
+	}
+
+	// the files are split into chunks to avoid exceeding the max argument length
+	maxArgLength := func(cmd *Command) int {
+		return commandMaxArgLength(options, cmd)
+	}
+	planCmd := state.tools.installedCommand(cmd)
+	chunks := commandChunks(planCmd, runDir, runFiles, state.changedLines, argFilePlaceholder, maxArgLength(planCmd))
+
+	// the contents are hashed before running to skip the cached ones, and to find the ones modified by the command
+	cacheKey := ""
+	if cmd.Cache && state.cache != nil {
+		cacheKey = state.cache.commandKey(config, cmd)
+	}
+	trackContents := state.cache != nil && (cmd.Cache || cmd.Fixer)
+	var ranFiles Files
+	var ranBlobs, ranKeys []string
+
+	task := &tl.Task{
+		Title: title + symGray(fmt.Sprintf(" - %d files", len(onFiles))),
 		Run: func(callback tl.TaskCallback) (err error) {
 			if len(onFiles) == 0 {
 				callback.Skip("")
 				return nil
 			}
 
-			shell := options.Shell
+			files := runFiles
+			if trackContents {
+				ranFiles, ranBlobs = runFiles, state.cache.blobs(runFiles)
+			}
+			if cacheKey != "" {
+				var uncachedBlobs, uncachedKeys []string
+				var uncached Files
+				for i, file := range ranFiles {
+					key := state.cache.fileKey(cacheKey, cmd, file, state.changedLines)
+					if !state.cache.has(key, ranBlobs[i]) {
+						uncached = append(uncached, file)
+						uncachedBlobs = append(uncachedBlobs, ranBlobs[i])
+						uncachedKeys = append(uncachedKeys, key)
+					}
+				}
+
+				if len(uncached) == 0 {
+					ranFiles, ranBlobs = nil, nil
+					callback.Skip("cached")
+					return nil
+				}
+				ranFiles, ranBlobs, ranKeys = uncached, uncachedBlobs, uncachedKeys
+				files = uncached
+			}
+
+			// the tool is installed on demand, then runs from .kitty/.bin
+			cmd, err := state.tools.command(cmd)
+			if err != nil {
+				return err
+			}
 
-			// --prepend xxx
+			filesEnv, argFile, err := state.writeCommandFiles(cmd, runDir, files, options.maxArgLength)
+			if err != nil {
+				return err
+			}
+			env := mr.Flats(state.env, filesEnv)
+			chunks := commandChunks(cmd, runDir, files, state.changedLines, argFile, maxArgLength(cmd))
 
-			fileArgs := ""
-			if cmd.NoArgs {
-				// do nothing
-			} else if cmd.Absolute {
-				args := onFiles.AbsolutePaths()
+			if cmd.rangeTemplate != "" && len(mr.Flats(chunks...)) == 0 {
+				callback.Skip("no changed lines")
+				return nil
+			}
 
-				if cmd.Dir {
-					args = mr.Map(args, func(f string, index int) string {
-						return filepath.Dir(f)
-					})
-					args = exstrings.DeDuplicate(args)
+			if len(chunks) <= 1 {
+				result := runCommandChunk(cmd, runDir, chunks, env, options)
+				state.filterChangedLines(runDir, result)
+				state.taskResults.Store(callback.GetTask().Id(), result)
+
+				return result.err
+			}
+
+			results := make([]*TaskResult, len(chunks))
+			chunkTasks := make([]*tl.Task, len(chunks))
+			for i, chunk := range chunks {
+				i, chunk := i, chunk
+
+				title := fmt.Sprintf("chunk %d/%d", i+1, len(chunks)) + symGray(fmt.Sprintf(" - %d files", len(chunk)))
+				if cmd.perFile {
+					title = files[i].GitRelativePath()
 				}
 
-				if cmd.Prepend != "" {
-					args = mr.ZipFlat(exlist.Repeat(len(args), cmd.Prepend), args)
+				chunkTasks[i] = &tl.Task{
+					Title: title,
+					Run: func(callback tl.TaskCallback) error {
+						results[i] = runCommandChunk(cmd, runDir, [][]string{chunk}, env, options)
+						state.filterChangedLines(runDir, results[i])
+						return results[i].err
+					},
 				}
+			}
 
-				fileArgs = shells.Join(args)
-			} else {
-				args := onFiles.AbsolutePaths()
+			callback.AddSubTaskList(tl.NewTaskList(
+				chunkTasks,
+				tl.WithExitOnError(false),
+				tl.WithConcurrent(options.concurrency),
+			))
+			state.taskResults.Store(callback.GetTask().Id(), &TaskResult{
+				cmd:    cmd,
+				chunks: results, // filled when the chunks finish
+			})
 
-				if cmd.Dir {
-					args = mr.Map(args, func(f string, index int) string {
-						return filepath.Dir(f)
-					})
-					args = exstrings.DeDuplicate(args)
-				}
+			return nil
+		},
+		PostRun: func(result *tl.Result) {
+			if r, ok := state.taskResults.Load(result.Task.Id()); ok {
+				r.(*TaskResult).aggregateChunks()
+			}
 
-				args = mr.Map(args, func(f string, index int) string {
-					return strings.TrimPrefix(f, wd+string(filepath.Separator))
-				})
+			if trackContents && len(ranFiles) != 0 {
+				state.cache.update(ranKeys, ranFiles, ranBlobs, !result.Error)
+			}
 
-				if cmd.Prepend != "" {
-					args = mr.ZipFlat(exlist.Repeat(len(args), cmd.Prepend), args)
+			if cmd.Fixer && state.isolatedRoot != "" && !result.Skipped {
+				if err := state.syncIsolated(onFiles); err != nil {
+					result.Error = true
+					result.Err = err
 				}
-
-				fileArgs = shells.Join(args)
 			}
+		},
+	}
 
-			fullCommandAndArgs := cmd.execCommand + " " + fileArgs
+	return &commandTasks{
+		cmd:      cmd,
+		files:    onFiles,
+		dir:      dir,
+		chunks:   chunks,
+		task:     task,
+		isolated: isolated,
+		planCmd:  planCmd,
+	}
+}
 
-			p := exec.Command(shell, "-c", fullCommandAndArgs)
-			p.Dir = wd
+// commandFileArgs returns the (quoted) arguments for each file passed to the command
+func commandFileArgs(cmd *Command, dir string, onFiles Files) []string {
+	if cmd.NoArgs {
+		return nil
+	}
 
-			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
-			defer stop()
+	return mr.Map(commandPaths(cmd, dir, onFiles), func(f string, index int) string {
+		if cmd.Prepend != "" { // --prepend xxx
+			return shells.Join([]string{cmd.Prepend, f})
+		}
+		return shells.Join([]string{f})
+	})
+}
 
-			go func() {
-				<-ctx.Done()
+// commandPaths returns the paths passed to the command for the files, before quoting
+func commandPaths(cmd *Command, dir string, onFiles Files) []string {
+	if cmd.placeholder == "relfiles" {
+		return onFiles.GitRelativePaths()
+	}
 
-				if process := p.Process; process != nil {
-					_ = process.Kill()
-				}
-			}()
+	paths := onFiles.AbsolutePaths()
 
-			output, err := p.CombinedOutput()
+	if cmd.Dir || cmd.placeholder == "dirs" || cmd.placeholder == "pkgs" {
+		paths = mr.Map(paths, func(f string, index int) string {
+			return filepath.Dir(f)
+		})
+		paths = exstrings.DeDuplicate(paths)
+	}
 
-			result := &TaskResult{
-				cmd:                cmd,
-				fullCommandAndArgs: fullCommandAndArgs,
-				output:             output,
-				err:                err,
+	if !cmd.Absolute {
+		paths = mr.Map(paths, func(f string, index int) string {
+			if rel, err := filepath.Rel(dir, f); err == nil {
+				return rel
 			}
-			state.taskResults.Store(callback.GetTask().Id(), result)
+			return f
+		})
+	}
 
-			return err
-		},
+	if cmd.placeholder == "pkgs" { // a relative package must start with ./ or ../
+		paths = mr.Map(paths, func(p string, index int) string {
+			if filepath.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "."+string(filepath.Separator)) || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+				return filepath.ToSlash(p)
+			}
+			return "./" + filepath.ToSlash(p)
+		})
+	}
+
+	return paths
+}
+
+// runCommandChunk runs the command once with the file arguments of the chunks
+func runCommandChunk(cmd *Command, dir string, chunks [][]string, env []string, options *Options) *TaskResult {
+	fullCommandAndArgs := cmd.commandLine(mr.Flats(chunks...))
+
+	p := exec.Command(options.Shell, "-c", fullCommandAndArgs)
+	p.Dir = dir
+	if len(cmd.Env) != 0 || len(env) != 0 {
+		p.Env = append(os.Environ(), env...)
+		for _, name := range sortedKeys(cmd.Env) {
+			p.Env = append(p.Env, name+"="+cmd.Env[name])
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	timeout := cmd.Timeout
+	if timeout == 0 {
+		timeout = options.Timeout
+	}
+
+	// --concurrent limits the processes of the whole run, not only the tasks of each list
+	if options.slots != nil {
+		options.slots <- struct{}{}
+		defer func() { <-options.slots }()
+	}
+
+	startedAt := time.Now()
+	output, err := runCommand(ctx, p, timeout)
+
+	return &TaskResult{
+		cmd:                cmd,
+		fullCommandAndArgs: fullCommandAndArgs,
+		output:             output,
+		err:                err,
+		timedOut:           ee.As(err, new(*timeoutError)),
+		duration:           time.Since(startedAt),
 	}
 }
 
@@ -479,29 +798,28 @@ func printTaskResults(results *sync.Map, options *Options) {
 
 		cmd := result.cmd.Command
 
-		output := exbytes.ToString(bytes.TrimSpace(result.output))
-		output = strings.ToValidUTF8(output, "\uFFFD")
-
 		// print title
 		if success {
 			pp.GreenPrintf("%s %s success:\n", icon, cmd)
+		} else if result.timedOut {
+			pp.RedPrintf("%s %s timed out:\n", icon, cmd)
 		} else {
 			pp.RedPrintf("%s %s failed:\n", icon, cmd)
 		}
 
-		// print full command
-		pp.Println(symGray(result.fullCommandAndArgs))
-
-		// print error
-		if result.err != nil {
-			pp.RedPrintf("Error: %v\n", result.err)
+		if len(result.chunks) == 0 {
+			printTaskOutput(result)
+			return true
 		}
 
-		// print output
-		if len(output) == 0 {
-			pp.Println(symGray("no output"))
-		} else {
-			pp.Println(output)
+		for i, chunk := range result.chunks {
+			if chunk == nil {
+				pp.Println(symGray(fmt.Sprintf("chunk %d/%d: skipped", i+1, len(result.chunks))))
+				continue
+			}
+
+			pp.Println(symGray(fmt.Sprintf("chunk %d/%d:", i+1, len(result.chunks))))
+			printTaskOutput(chunk)
 		}
 
 		return true
@@ -511,3 +829,23 @@ func printTaskResults(results *sync.Map, options *Options) {
 		pp.Println()
 	}
 }
+
+func printTaskOutput(result *TaskResult) {
+	output := exbytes.ToString(bytes.TrimSpace(result.output))
+	output = strings.ToValidUTF8(output, "\uFFFD")
+
+	// print full command
+	pp.Println(symGray(result.fullCommandAndArgs))
+
+	// print error
+	if result.err != nil {
+		pp.RedPrintf("Error: %v\n", result.err)
+	}
+
+	// print output
+	if len(output) == 0 {
+		pp.Println(symGray("no output"))
+	} else {
+		pp.Println(output)
+	}
+}
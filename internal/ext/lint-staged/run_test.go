@@ -0,0 +1,650 @@
+package lintstaged
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ImSingee/go-ex/mr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunChunks(t *testing.T) {
+	repo := newTestRepo(t)
+	calls := filepath.Join(t.TempDir(), "calls.log")
+
+	command := "echo call >> " + calls + "; echo"
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "`+command+`"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	for _, name := range []string{"a1.txt", "a2.txt", "a3.txt", "a4.txt", "a5.txt"} {
+		writeFile(t, repo, name, name+"\n")
+	}
+	gitRun(t, repo, "add", ".")
+
+	// each chunk can hold 2 files ("a1.txt a2.txt")
+	err := runInDir(t, repo, &Options{Stash: true, Concurrent: "false", MaxArgLength: len(command) + 1 + 14})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(calls)
+	require.NoError(t, err)
+	assert.Equal(t, 3, strings.Count(string(data), "call"))
+}
+
+func TestRunConcurrentLimit(t *testing.T) {
+	repo := newTestRepo(t)
+	log := filepath.Join(t.TempDir(), "running.log")
+
+	// each command records when it starts and ends
+	command := `[noArgs] echo + >> ` + log + `; sleep 0.2; echo - >> ` + log
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "`+command+`", "*.md": "`+command+`"}`)
+	writeFile(t, repo, "sub/.lintstagedrc.json", `{"*.txt": "`+command+`", "*.md": "`+command+`"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	for _, name := range []string{"a.txt", "a.md", "sub/a.txt", "sub/a.md"} {
+		writeFile(t, repo, name, name+"\n")
+	}
+	gitRun(t, repo, "add", ".")
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true, Concurrent: "2"}))
+
+	running, maxRunning := 0, 0
+	for _, event := range strings.Fields(readFile(t, filepath.Dir(log), "running.log")) {
+		if event == "+" {
+			running++
+		} else {
+			running--
+		}
+		maxRunning = max(maxRunning, running)
+	}
+	assert.Equal(t, 2, maxRunning, "the configs and the rules share the limit")
+}
+
+func TestRunGlobRelativeToConfig(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+
+	writeFile(t, repo, "sub/.lintstagedrc.json", `{"lib/**/*.js": "echo >> `+filepath.Join(logs, "js.log")+`", "!(*.md)": "echo >> `+filepath.Join(logs, "other.log")+`"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	for _, name := range []string{"lib/a.js", "sub/lib/b.js", "sub/lib/c/.d.js", "sub/e.js", "sub/f.md"} {
+		writeFile(t, repo, name, name+"\n")
+	}
+	gitRun(t, repo, "add", ".")
+
+	err := runInDir(t, repo, &Options{Stash: true, Concurrent: "false"})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(logs, "js.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "lib/b.js lib/c/.d.js\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(logs, "other.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "e.js lib/b.js lib/c/.d.js\n", string(data))
+}
+
+func TestRunParentPatterns(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	log := func(name string) string { return filepath.Join(logs, name) }
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.md": "echo >> `+log("root.log")+`"}`)
+	writeFile(t, repo, "sub/.lintstagedrc.json", `{"inherit": true, "files": {"../docs/*.md": "echo >> `+log("parent.log")+`"}}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	for _, name := range []string{"c.md", "docs/a.md", "sub/b.js"} {
+		writeFile(t, repo, name, name+"\n")
+	}
+	gitRun(t, repo, "add", ".")
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true, Concurrent: "false"}))
+	assert.Equal(t, "../docs/a.md\n", readFile(t, logs, "parent.log"), "matched by the pattern starting with ../")
+	assert.Equal(t, "c.md docs/a.md\n", readFile(t, logs, "root.log"), "the inherited rules don't run on the files outside the directory")
+}
+
+func TestRunReport(t *testing.T) {
+	repo := newTestRepo(t)
+	reports := t.TempDir()
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": ["echo checked", "[noArgs] exit 3"], "*.md": "echo"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	gitRun(t, repo, "add", ".")
+
+	err := runInDir(t, repo, &Options{Stash: true, Concurrent: "false", ReportFile: filepath.Join(reports, "report.json")})
+	require.Error(t, err)
+
+	data, err := os.ReadFile(filepath.Join(reports, "report.json"))
+	require.NoError(t, err)
+
+	var r report
+	require.NoError(t, json.Unmarshal(data, &r))
+	assert.False(t, r.Success)
+	assert.Equal(t, "staged files", r.Selection)
+	require.Len(t, r.Configs, 1)
+
+	c := r.Configs[0]
+	assert.Equal(t, ".lintstagedrc.json", c.Path)
+	assert.Equal(t, []string{"a.txt"}, c.Files)
+	assert.Equal(t, reportStatusFailed, c.Status)
+	require.Len(t, c.Rules, 2)
+
+	md := c.Rules[0]
+	assert.Equal(t, "*.md", md.Pattern)
+	assert.Equal(t, reportStatusSkipped, md.Status)
+	assert.Equal(t, "no files", md.SkipReason)
+	assert.Equal(t, reportStatusSkipped, md.Commands[0].Status)
+
+	txt := c.Rules[1]
+	assert.Equal(t, reportStatusFailed, txt.Status)
+	require.Len(t, txt.Commands, 2)
+
+	echo := txt.Commands[0]
+	assert.Equal(t, reportStatusSuccess, echo.Status)
+	assert.Equal(t, []string{"a.txt"}, echo.Files)
+	assert.Equal(t, "echo checked a.txt", echo.CommandLine)
+	assert.Equal(t, "checked a.txt", echo.Output)
+	require.NotNil(t, echo.ExitCode)
+	assert.Equal(t, 0, *echo.ExitCode)
+
+	exit := txt.Commands[1]
+	assert.Equal(t, reportStatusFailed, exit.Status)
+	require.NotNil(t, exit.ExitCode)
+	assert.Equal(t, 3, *exit.ExitCode)
+	assert.Equal(t, "exit status 3", exit.Error)
+
+	// JUnit
+	gitRun(t, repo, "add", ".")
+	err = runInDir(t, repo, &Options{Stash: true, Concurrent: "false", ReportFile: filepath.Join(reports, "report.xml")})
+	require.Error(t, err)
+
+	data, err = os.ReadFile(filepath.Join(reports, "report.xml"))
+	require.NoError(t, err)
+
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(data, &suites))
+	assert.Equal(t, 3, suites.Tests)
+	assert.Equal(t, 1, suites.Failures)
+	assert.Equal(t, 1, suites.Skipped)
+	require.Len(t, suites.Suites, 2)
+	assert.Equal(t, ".lintstagedrc.json *.txt", suites.Suites[1].Name)
+	assert.Equal(t, "exit 3", suites.Suites[1].TestCases[1].Name)
+	require.NotNil(t, suites.Suites[1].TestCases[1].Failure)
+	assert.Equal(t, "exit status 3", suites.Suites[1].TestCases[1].Failure.Message)
+}
+
+func TestRunDryRun(t *testing.T) {
+	repo := newTestRepo(t)
+	calls := filepath.Join(t.TempDir(), "calls.log")
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": ["echo call >> `+calls+`; echo", {"run": "[dir][prepend --dir] ls", "title": "List"}], "*.md": "echo", "*.go": "[noArgs] go vet"}`)
+	writeFile(t, repo, "sub/.lintstagedrc.json", `{"*.txt": "cat"}`)
+	writeFile(t, repo, ".kittyignore", "vendor\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	writeFile(t, repo, "lib/b c.txt", "b\n")
+	writeFile(t, repo, "sub/d.txt", "d\n")
+	writeFile(t, repo, "main.go", "package main\n")
+	writeFile(t, repo, "vendor/e.txt", "e\n")
+	gitRun(t, repo, "add", ".")
+	writeFile(t, repo, "a.txt", "a modified\n") // partially staged
+
+	options := &Options{Stash: true, DryRun: true}
+	require.NoError(t, runInDir(t, repo, options))
+
+	assert.NoFileExists(t, calls, "no commands run")
+	assert.Equal(t, "", gitOutput(t, repo, "stash", "list"), "the stash is not touched")
+	assert.Equal(t, "a modified\n", readFile(t, repo, "a.txt"))
+
+	state, err := runAll(options)
+	require.NoError(t, err)
+
+	p := buildPlan(state, options, nil)
+	require.Len(t, p.Configs, 2)
+
+	root := p.Configs[0]
+	assert.Equal(t, ".lintstagedrc.json", root.Path)
+	assert.Equal(t, []string{"a.txt", "lib/b c.txt", "main.go", "vendor/e.txt"}, root.Files)
+	assert.Equal(t, []string{"vendor/e.txt"}, root.Ignored)
+	require.Len(t, root.Rules, 3)
+
+	goRule := root.Rules[0]
+	assert.Equal(t, []string{"main.go"}, goRule.Files)
+	assert.Equal(t, []string{"go vet"}, goRule.Commands[0].CommandLines)
+
+	assert.Empty(t, root.Rules[1].Files)
+	assert.Empty(t, root.Rules[1].Commands[0].CommandLines)
+
+	txtRule := root.Rules[2]
+	assert.Equal(t, []string{"a.txt", "lib/b c.txt"}, txtRule.Files)
+	assert.Equal(t, []string{"echo call >> " + calls + "; echo a.txt 'lib/b c.txt'"}, txtRule.Commands[0].CommandLines)
+	assert.Equal(t, "List", txtRule.Commands[1].Title)
+	assert.Equal(t, []string{"ls --dir . --dir lib"}, txtRule.Commands[1].CommandLines)
+
+	sub := p.Configs[1]
+	assert.Equal(t, "sub/.lintstagedrc.json", sub.Path)
+	assert.Equal(t, []string{"sub/d.txt"}, sub.Files)
+	assert.Equal(t, "sub", sub.Rules[0].Commands[0].Cwd)
+	assert.Equal(t, []string{"cat d.txt"}, sub.Rules[0].Commands[0].CommandLines)
+}
+
+func TestRunIsolated(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	log := filepath.Join(logs, "lint.log")
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "pwd >> `+log+`; cat >> `+log+`"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	gitRun(t, repo, "add", ".")
+	writeFile(t, repo, "a.txt", "a modified\n") // partially staged
+
+	err := runInDir(t, repo, &Options{Stash: true, Isolated: true})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(readFile(t, logs, "lint.log")), "\n")
+	require.Len(t, lines, 2)
+	assert.NotEqual(t, repo, lines[0], "the command runs in a temporary directory")
+	assert.NoDirExists(t, lines[0], "the temporary directory is removed")
+	assert.Equal(t, "a", lines[1], "the staged content is linted")
+
+	assert.Equal(t, "", gitOutput(t, repo, "stash", "list"), "nothing is stashed without fixers")
+	assert.Equal(t, "a modified\n", readFile(t, repo, "a.txt"))
+
+	t.Run("fixer", func(t *testing.T) {
+		require.NoError(t, os.Remove(log))
+
+		writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": [{"run": "[noArgs] tr a-z A-Z < b.txt > b.tmp && mv b.tmp b.txt", "fixer": true}, "cat >> `+log+`"]}`)
+		writeFile(t, repo, "b.txt", "b\n")
+		gitRun(t, repo, "add", ".lintstagedrc.json", "b.txt")
+
+		err := runInDir(t, repo, &Options{Stash: true, Isolated: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, "a\nB\n", readFile(t, logs, "lint.log"), "the read-only commands see the fixed content")
+		assert.Equal(t, "B\n", gitOutput(t, repo, "show", ":b.txt"), "the modifications of the fixer are staged")
+		assert.Equal(t, "a modified\n", readFile(t, repo, "a.txt"))
+	})
+}
+
+func TestRunCache(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	log := filepath.Join(logs, "lint.log")
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": {"run": "echo >> `+log+`", "cache": true}}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	writeFile(t, repo, "b.txt", "b\n")
+	gitRun(t, repo, "add", ".")
+
+	run := func(options *Options) {
+		t.Helper()
+		options.Stash = true
+		require.NoError(t, runInDir(t, repo, options))
+	}
+
+	run(&Options{})
+	run(&Options{})
+	assert.Equal(t, "a.txt b.txt\n", readFile(t, logs, "lint.log"), "the cached files are skipped")
+
+	writeFile(t, repo, "b.txt", "b modified\n")
+	gitRun(t, repo, "add", ".")
+	run(&Options{})
+	assert.Equal(t, "a.txt b.txt\nb.txt\n", readFile(t, logs, "lint.log"), "only the modified files run")
+
+	run(&Options{NoCache: true})
+	assert.Equal(t, "a.txt b.txt\nb.txt\na.txt b.txt\n", readFile(t, logs, "lint.log"))
+
+	require.NoError(t, os.Chdir(repo))
+	require.NoError(t, clearCache())
+	assert.NoDirExists(t, filepath.Join(repo, ".git", CacheDir))
+	run(&Options{})
+	assert.Equal(t, "a.txt b.txt\nb.txt\na.txt b.txt\na.txt b.txt\n", readFile(t, logs, "lint.log"))
+}
+
+func TestRunFailOnChanges(t *testing.T) {
+	repo := newTestRepo(t)
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "[noArgs] tr a-z A-Z < a.txt > a.tmp && mv a.tmp a.txt"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	gitRun(t, repo, "add", ".")
+
+	options := &Options{Stash: true, FailOnChanges: true}
+	require.Error(t, runInDir(t, repo, options))
+	assert.Equal(t, "a\n", gitOutput(t, repo, "show", ":a.txt"), "the modifications are not added")
+	assert.Equal(t, "a\n", readFile(t, repo, "a.txt"), "the original state is restored")
+
+	state, err := runAll(options)
+	require.Error(t, err)
+	require.Len(t, state.output, 1)
+	assert.Contains(t, state.output[0], "Tasks modified 1 files")
+	assert.Contains(t, state.output[0], "-a\n+A")
+
+	t.Run("not index", func(t *testing.T) {
+		writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "[noArgs] tr a-z A-Z < a.txt > a.tmp && mv a.tmp a.txt && echo x >> other.log"}`)
+
+		options := &Options{Status: string(SelectionModeChanged), FailOnChanges: true}
+		require.NoError(t, validateOptions(options))
+		state, err := runAll(options)
+		require.Error(t, err)
+		require.Len(t, state.output, 2)
+		assert.Contains(t, state.output[0], "Tasks modified 1 files outside the changed files:\n  other.log")
+		assert.Contains(t, state.output[1], "-a\n+A")
+
+		options.FailOnChanges = false
+		state, err = runAll(options)
+		require.NoError(t, err)
+		assert.Nil(t, state.snapshot, "no snapshot without --fail-on-changes")
+		assert.Empty(t, state.output)
+	})
+}
+
+func TestRunChangedLines(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	log := filepath.Join(logs, "lines.log")
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": ["echo {file}:{start}-{end} >> `+log+`", "[noArgs] cp $`+ChangedLinesEnv+` `+filepath.Join(logs, "lines.json")+`"]}`)
+	writeFile(t, repo, "a.txt", "1\n2\n3\n4\n5\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "1\n2\nthree\n4\n5\n6\n7\n")
+	writeFile(t, repo, "b.txt", "b\n")
+	gitRun(t, repo, "add", "a.txt", "b.txt")
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true}))
+	assert.Equal(t, "a.txt:3-3 a.txt:6-7 b.txt:1-1\n", readFile(t, logs, "lines.log"))
+
+	var content changedLinesFile
+	require.NoError(t, json.Unmarshal([]byte(readFile(t, logs, "lines.json")), &content))
+	assert.Equal(t, changedLinesFile{
+		Root: repo,
+		Files: changedLines{
+			"a.txt": {{Start: 3, End: 3}, {Start: 6, End: 7}},
+			"b.txt": {{Start: 1, End: 1}},
+		},
+	}, content)
+
+	t.Run("changedLinesOnly", func(t *testing.T) {
+		writeFile(t, repo, ".lintstagedrc.json", `{"a.txt": "[changedLinesOnly][noArgs] echo a.txt:1: old issue; exit 1"}`)
+		require.NoError(t, runInDir(t, repo, &Options{Stash: true}), "the diagnostic on the unchanged line is ignored")
+
+		writeFile(t, repo, ".lintstagedrc.json", `{"a.txt": "[changedLinesOnly][noArgs] echo a.txt:1: old issue; echo a.txt:6: new issue; exit 1"}`)
+		require.Error(t, runInDir(t, repo, &Options{Stash: true}))
+	})
+
+	t.Run("renamed file", func(t *testing.T) {
+		repo := newTestRepo(t)
+		log := filepath.Join(t.TempDir(), "lines.log")
+
+		writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "echo {file}:{start}-{end} >> `+log+`"}`)
+		writeFile(t, repo, "old.txt", "1\n2\n3\n4\n5\n6\n7\n8\n")
+		gitRun(t, repo, "add", ".")
+		gitRun(t, repo, "commit", "-m", "initial")
+
+		gitRun(t, repo, "mv", "old.txt", "new.txt")
+		writeFile(t, repo, "new.txt", "1\n2\nthree\n4\n5\n6\n7\n8\n")
+		gitRun(t, repo, "add", "new.txt")
+
+		require.NoError(t, runInDir(t, repo, &Options{Stash: true}))
+		assert.Equal(t, "new.txt:3-3\n", readFile(t, filepath.Dir(log), "lines.log"), "only the edited line of the renamed file")
+	})
+
+	t.Run("only computed when needed", func(t *testing.T) {
+		options := &Options{Stash: true, DryRun: true}
+
+		writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "echo"}`)
+		require.NoError(t, runInDir(t, repo, options))
+		state, err := runAll(options)
+		require.NoError(t, err)
+		assert.Nil(t, state.changedLines)
+
+		writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "echo", "a.txt": "[changedLinesOnly] echo"}`)
+		state, err = runAll(options)
+		require.NoError(t, err)
+		assert.Equal(t, []lineRange{{Start: 3, End: 3}, {Start: 6, End: 7}}, state.changedLines["a.txt"])
+	})
+}
+
+func TestRunPlaceholders(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	log := func(name string) string { return filepath.Join(logs, name) }
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": [
+  "echo start {files} end >> `+log("files.log")+`",
+  "echo {file}: >> `+log("file.log")+`",
+  "[argfile] sh `+log("argfile.sh")+`",
+  "[noArgs] cp $`+FilesFileEnv+` `+log("env-file.log")+` && printf '%s\\n' \"$`+FilesEnv+`\" > `+log("env.log")+`"
+]}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	writeFile(t, repo, "b c.txt", "b\n")
+	gitRun(t, repo, "add", ".")
+	writeFile(t, logs, "argfile.sh", `cat "${1#@}" > `+log("argfile.log"))
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true, Concurrent: "false"}))
+	assert.Equal(t, "start a.txt b c.txt end\n", readFile(t, logs, "files.log"))
+	assert.Equal(t, "a.txt\nb c.txt\n", readFile(t, logs, "argfile.log"))
+	assert.Equal(t, "a.txt:\nb c.txt:\n", readFile(t, logs, "file.log"))
+	assert.Equal(t, "a.txt\nb c.txt\n", readFile(t, logs, "env-file.log"))
+	assert.Equal(t, "a.txt\nb c.txt\n", readFile(t, logs, "env.log"))
+}
+
+func TestRunDeletedAndRenamedFiles(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	log := func(name string) string { return filepath.Join(logs, name) }
+
+	writeFile(t, repo, ".lintstagedrc.json", `{
+  "*.txt": "echo >> `+log("all.log")+`",
+  "docs/*": {"on": ["deleted", "renamed"], "commands": ["echo >> `+log("events.log")+`", "echo {from} {file} >> `+log("renames.log")+`"]}
+}`)
+	writeFile(t, repo, "docs/a.txt", "a\n")
+	writeFile(t, repo, "docs/b.txt", "b\n")
+	writeFile(t, repo, "c.txt", "c\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	gitRun(t, repo, "rm", "--quiet", "docs/a.txt")
+	gitRun(t, repo, "mv", "docs/b.txt", "docs/renamed.txt")
+	writeFile(t, repo, "c.txt", "c modified\n")
+	gitRun(t, repo, "add", "c.txt")
+
+	options := &Options{Stash: true, DryRun: true}
+	require.NoError(t, runInDir(t, repo, options))
+	state, err := runAll(options)
+	require.NoError(t, err)
+	p := buildPlan(state, options, state.configTasks[0].files)
+	assert.Equal(t, []string{"docs/a.txt"}, p.Deleted)
+	assert.Equal(t, map[string]string{"docs/renamed.txt": "docs/b.txt"}, p.Renamed)
+	assert.Equal(t, " (renamed from docs/b.txt)", p.fileStatus("docs/renamed.txt"))
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true, Concurrent: "false"}))
+	assert.Equal(t, "c.txt docs/renamed.txt\n", readFile(t, logs, "all.log"), "the deleted files are not passed to the other rules")
+	assert.Equal(t, "docs/a.txt docs/renamed.txt\n", readFile(t, logs, "events.log"))
+	assert.Equal(t, "docs/a.txt docs/a.txt\ndocs/b.txt docs/renamed.txt\n", readFile(t, logs, "renames.log"))
+
+	assert.Equal(t, "M\tc.txt\nD\tdocs/a.txt\nR100\tdocs/b.txt\tdocs/renamed.txt\n", gitOutput(t, repo, "diff", "--staged", "--name-status"), "the index is kept")
+}
+
+func TestRunWorkspaces(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	script := filepath.Join(logs, "log.sh")
+	writeFile(t, logs, "log.sh", `echo "$(pwd) $*" >> `+filepath.Join(logs, "workspaces.log"))
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": {"workspace": true, "commands": "sh `+script+`"}}`)
+	writeFile(t, repo, "mod/go.mod", "module example.com/mod\n")
+	writeFile(t, repo, "web/package.json", "{}\n")
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	writeFile(t, repo, "mod/b.txt", "b\n")
+	writeFile(t, repo, "mod/pkg/c.txt", "c\n")
+	writeFile(t, repo, "web/src/d.txt", "d\n")
+	gitRun(t, repo, "add", ".")
+
+	options := &Options{Stash: true, DryRun: true}
+	require.NoError(t, runInDir(t, repo, options))
+	state, err := runAll(options)
+	require.NoError(t, err)
+	commands := buildPlan(state, options, nil).Configs[0].Rules[0].Commands
+	require.Len(t, commands, 3)
+	assert.Equal(t, []string{".", "mod", "web"}, mr.Map(commands, func(in *commandPlan, index int) string { return in.Cwd }))
+	assert.Equal(t, []string{"sh " + script + " b.txt pkg/c.txt"}, commands[1].CommandLines)
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true, Concurrent: "false"}))
+	assert.Equal(t, repo+" a.txt\n"+filepath.Join(repo, "mod")+" b.txt pkg/c.txt\n"+filepath.Join(repo, "web")+" src/d.txt\n", readFile(t, logs, "workspaces.log"))
+}
+
+func TestRunInheritConfigs(t *testing.T) {
+	repo := newTestRepo(t)
+	logs := t.TempDir()
+	log := func(name string) string { return filepath.Join(logs, name) }
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "echo >> `+log("txt.log")+`", "*.md": "echo >> `+log("md.log")+`"}`)
+	writeFile(t, repo, "sub/.lintstagedrc.json", `{"inherit": true, "files": {"*.md": "echo >> `+log("sub-md.log")+`"}}`)
+	writeFile(t, repo, "other/.lintstagedrc.json", `{"*.go": "true"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	writeFile(t, repo, "a.txt", "a\n")
+	writeFile(t, repo, "sub/b.txt", "b\n")
+	writeFile(t, repo, "sub/c.md", "c\n")
+	writeFile(t, repo, "other/d.txt", "d\n")
+	gitRun(t, repo, "add", ".")
+
+	options := &Options{Stash: true, DryRun: true}
+	require.NoError(t, runInDir(t, repo, options))
+	state, err := runAll(options)
+	require.NoError(t, err)
+	var sub *configPlan
+	for _, c := range buildPlan(state, options, nil).Configs {
+		if c.Path == "sub/.lintstagedrc.json" {
+			sub = c
+		}
+	}
+	require.NotNil(t, sub)
+	assert.Equal(t, []string{"*.md", "*.txt"}, mr.Map(sub.Rules, func(in *rulePlan, index int) string { return in.Pattern }), "the inherited *.md is overridden")
+	assert.Equal(t, []string{"", ".lintstagedrc.json"}, mr.Map(sub.Rules, func(in *rulePlan, index int) string { return in.From }))
+	assert.Equal(t, ".", sub.Rules[1].Commands[0].Cwd, "the inherited rules run in the directory of their config")
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true, Concurrent: "false"}))
+	assert.ElementsMatch(t, []string{"a.txt", "sub/b.txt"}, strings.Fields(readFile(t, logs, "txt.log")), "other/d.txt is not matched without inherit")
+	assert.Equal(t, "c.md\n", readFile(t, logs, "sub-md.log"))
+	assert.NoFileExists(t, log("md.log"))
+}
+
+func TestRunRepositoryTools(t *testing.T) {
+	repo := newTestRepo(t)
+	log := filepath.Join(t.TempDir(), "tool.log")
+
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": ["kitty-test-tool --check", "kitty-test-tool {files} --fail"]}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	tool := filepath.Join(repo, ".kitty", ".bin", "kitty-test-tool")
+	writeFile(t, repo, ".kitty/.bin/kitty-test-tool", "#!/bin/sh\necho \"$@\" >> "+log+"\ncase \"$*\" in *--fail) exit 1;; esac\n")
+	require.NoError(t, os.Chmod(tool, 0755))
+
+	writeFile(t, repo, "a.txt", "a\n")
+	gitRun(t, repo, "add", "a.txt")
+
+	require.Error(t, runInDir(t, repo, &Options{Stash: true, Concurrent: "false"}))
+	assert.Equal(t, "--check a.txt\na.txt --fail\n", readFile(t, filepath.Dir(log), "tool.log"), "the tool not in PATH runs from .kitty/.bin")
+
+	options := &Options{Stash: true, Concurrent: "false", Shell: "/bin/sh"}
+	require.NoError(t, validateOptions(options))
+	state, err := runAll(options)
+	require.Error(t, err)
+
+	var failed *TaskResult
+	state.taskResults.Range(func(key, value any) bool {
+		if r := value.(*TaskResult); r.err != nil {
+			failed = r
+		}
+		return true
+	})
+	require.NotNil(t, failed)
+	assert.Equal(t, tool+" a.txt --fail", failed.fullCommandAndArgs, "the resolved path is shown")
+
+	options = &Options{DryRun: true, Shell: "/bin/sh"}
+	require.NoError(t, validateOptions(options))
+	state, err = runAll(options)
+	require.NoError(t, err)
+	commands := buildPlan(state, options, nil).Configs[0].Rules[0].Commands
+	assert.Equal(t, []string{tool + " --check a.txt"}, commands[0].CommandLines)
+	assert.Equal(t, []string{tool + " a.txt --fail"}, commands[1].CommandLines)
+}
+
+func TestCommandFileArgs(t *testing.T) {
+	files := NewFiles(&State{gitRoot: "/repo"}, []string{"a/b.go", "a/c d.go", "e.go"})
+
+	assert.Equal(t, []string{"b.go", "'c d.go'"}, commandFileArgs(&Command{}, "/repo/a", files[:2]))
+	assert.Equal(t, []string{"a/b.go", "'a/c d.go'", "e.go"}, commandFileArgs(&Command{}, "/repo", files))
+	assert.Equal(t, []string{"../e.go"}, commandFileArgs(&Command{}, "/repo/a", files[2:]))
+	assert.Equal(t, []string{"/repo/a/b.go", "/repo/e.go"}, commandFileArgs(&Command{Absolute: true}, "/repo", Files{files[0], files[2]}))
+	assert.Equal(t, []string{"a", "."}, commandFileArgs(&Command{Dir: true}, "/repo", files))
+	assert.Equal(t, []string{"--file a/b.go", "--file e.go"}, commandFileArgs(&Command{Prepend: "--file"}, "/repo", Files{files[0], files[2]}))
+	assert.Nil(t, commandFileArgs(&Command{NoArgs: true}, "/repo", files))
+}
+
+// newTestRepo creates an empty git repository
+func newTestRepo(t *testing.T) string {
+	t.Helper()
+
+	repo := t.TempDir()
+	if real, err := filepath.EvalSymlinks(repo); err == nil {
+		repo = real
+	}
+
+	gitRun(t, repo, "init")
+	gitRun(t, repo, "config", "user.name", "Test User")
+	gitRun(t, repo, "config", "user.email", "test@example.com")
+
+	return repo
+}
+
+// runInDir runs lint-staged in dir
+func runInDir(t *testing.T, dir string, options *Options) error {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+
+	if options.Shell == "" {
+		options.Shell = "/bin/sh"
+	}
+
+	return Run(options)
+}
@@ -11,9 +11,13 @@ const (
 	SelectionModeTracked   SelectionMode = "tracked"
 	SelectionModeChanged   SelectionMode = "changed"
 	SelectionModeAll       SelectionMode = "all"
+	SelectionModeSince     SelectionMode = "since" // by --since, not a valid --status
 )
 
 func (o *Options) SelectionMode() SelectionMode {
+	if o.Since != "" {
+		return SelectionModeSince
+	}
 	if o.Status == "" {
 		return SelectionModeStaged
 	}
@@ -26,6 +30,16 @@ func (o *Options) UsesIndex() bool {
 }
 
 func (o *Options) ValidateSelectionMode() error {
+	if o.Since != "" {
+		if o.Status != "" && SelectionMode(o.Status) != SelectionModeStaged {
+			return fmt.Errorf("--since cannot be used together with --status=%s", o.Status)
+		}
+		if o.Diff != "" {
+			return fmt.Errorf("--since cannot be used together with --diff")
+		}
+		return nil
+	}
+
 	switch o.SelectionMode() {
 	case SelectionModeStaged, SelectionModeUnstaged, SelectionModeUntracked, SelectionModeTracked, SelectionModeChanged, SelectionModeAll:
 		// ok
@@ -44,6 +58,9 @@ func (o *Options) SelectionReason() string {
 	if o.Diff != "" {
 		return "`--diff` was used"
 	}
+	if o.Since != "" {
+		return "`--since` was used"
+	}
 	if o.SelectionMode() != SelectionModeStaged {
 		return fmt.Sprintf("`--status=%s` was used", o.SelectionMode())
 	}
@@ -67,6 +84,12 @@ func (o *Options) SelectedFilesLabel() string {
 		return "changed files"
 	case SelectionModeAll:
 		return "all files"
+	case SelectionModeSince:
+		ref := o.sinceRef // resolved from --since auto
+		if ref == "" {
+			ref = o.Since
+		}
+		return "files changed since " + ref
 	default:
 		return "staged files"
 	}
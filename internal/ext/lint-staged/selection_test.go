@@ -70,4 +70,22 @@ func TestOptionsSelectionMode(t *testing.T) {
 
 		require.Error(t, options.ValidateSelectionMode())
 	})
+
+	t.Run("since uses working tree only", func(t *testing.T) {
+		options := &Options{Since: "origin/main", Status: string(SelectionModeStaged)}
+
+		require.NoError(t, options.ValidateSelectionMode())
+		assert.Equal(t, SelectionModeSince, options.SelectionMode())
+		assert.False(t, options.UsesIndex())
+		assert.Equal(t, "`--since` was used", options.SelectionReason())
+		assert.Equal(t, "files changed since origin/main", options.SelectedFilesLabel())
+
+		options = &Options{Since: SinceAuto, sinceRef: "origin/dev"}
+		assert.Equal(t, "files changed since origin/dev", options.SelectedFilesLabel())
+	})
+
+	t.Run("since cannot combine with diff or status", func(t *testing.T) {
+		require.Error(t, (&Options{Since: "main", Diff: "HEAD"}).ValidateSelectionMode())
+		require.Error(t, (&Options{Since: "main", Status: string(SelectionModeAll)}).ValidateSelectionMode())
+	})
 }
@@ -0,0 +1,113 @@
+package lintstaged
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/pp"
+)
+
+// SinceAuto is the value of --since to detect the ref, see detectSinceRef
+const SinceAuto = "auto"
+
+const (
+	sinceDeepenBy      = 50 // commits fetched in the first deepening, doubled every time
+	sinceDeepenTimes   = 5
+	shallowCloneHint   = "fetch more history (e.g. `git fetch --unshallow`, or `fetch-depth: 0` for actions/checkout)"
+	sinceDefaultRemote = "origin"
+)
+
+// getSinceFiles returns the files changed since the merge base of the ref and HEAD, including the uncommitted changes
+func getSinceFiles(options *Options, gitDir string) ([]selectedFile, error) {
+	ref := options.Since
+	if ref == SinceAuto {
+		var err error
+		ref, err = detectSinceRef(gitDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+	options.sinceRef = ref
+
+	base, err := getMergeBase(ref, gitDir)
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("Resolved merge base", "ref", ref, "mergeBase", base)
+	options.sinceBase = base
+
+	return execGitNameStatus([]string{"diff", "--name-status", "-z", "-M", "--diff-filter=" + normalizeDiffFilter(options.DiffFilter), base}, gitDir)
+}
+
+// detectSinceRef detects the branch to compare with for --since auto, in order:
+// the target branch of the pull request on CI, the default branch of the remote,
+// the upstream of the current branch, then main or master
+func detectSinceRef(gitDir string) (string, error) {
+	var candidates []string
+
+	for _, env := range []string{"GITHUB_BASE_REF", "CI_MERGE_REQUEST_TARGET_BRANCH_NAME", "BITBUCKET_PR_DESTINATION_BRANCH"} {
+		if branch := os.Getenv(env); branch != "" {
+			candidates = append(candidates, sinceDefaultRemote+"/"+branch, branch)
+		}
+	}
+
+	if head, err := execGit([]string{"symbolic-ref", "--quiet", "--short", "refs/remotes/" + sinceDefaultRemote + "/HEAD"}, gitDir); err == nil && head != "" {
+		candidates = append(candidates, head)
+	}
+	if upstream, err := execGit([]string{"rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}"}, gitDir); err == nil && upstream != "" {
+		candidates = append(candidates, upstream)
+	}
+
+	candidates = append(candidates,
+		sinceDefaultRemote+"/main", sinceDefaultRemote+"/master",
+		"main", "master",
+	)
+
+	for _, ref := range candidates {
+		if refExists(ref, gitDir) {
+			return ref, nil
+		}
+	}
+
+	return "", ee.New("cannot detect the branch for `--since auto`, please specify it (e.g. `--since origin/main`)")
+}
+
+func refExists(ref, gitDir string) bool {
+	_, err := execGit([]string{"rev-parse", "--verify", "--quiet", ref + "^{commit}"}, gitDir)
+	return err == nil
+}
+
+// getMergeBase returns the merge base of the ref and HEAD, the shallow clones are deepened until it's found
+func getMergeBase(ref, gitDir string) (string, error) {
+	if !refExists(ref, gitDir) {
+		return "", fmt.Errorf("cannot resolve `%s` for `--since`, is it fetched?", ref)
+	}
+
+	for i := 0; ; i++ {
+		base, err := execGit([]string{"merge-base", ref, "HEAD"}, gitDir)
+		if err == nil && base != "" {
+			return base, nil
+		}
+
+		if !isShallowRepository(gitDir) {
+			return "", fmt.Errorf("`%s` and HEAD have no common ancestor", ref)
+		}
+		if i == sinceDeepenTimes {
+			return "", fmt.Errorf("cannot find the merge base of `%s` and HEAD in the shallow clone, please %s", ref, shallowCloneHint)
+		}
+
+		depth := sinceDeepenBy << i
+		pp.EYellowPrintf("%s Shallow clone, fetching %d more commits to find the merge base of %s and HEAD...\n", warning, depth, ref)
+		if _, err := execGit([]string{"fetch", "--quiet", "--deepen=" + strconv.Itoa(depth), sinceDefaultRemote}, gitDir); err != nil {
+			return "", ee.Wrapf(err, "cannot deepen the shallow clone, please %s", shallowCloneHint)
+		}
+	}
+}
+
+func isShallowRepository(gitDir string) bool {
+	shallow, _ := execGit([]string{"rev-parse", "--is-shallow-repository"}, gitDir)
+	return shallow == "true"
+}
@@ -2,18 +2,35 @@ package lintstaged
 
 import (
 	"sync"
+	"time"
 
+	"github.com/ImSingee/go-ex/mr"
 	"github.com/ImSingee/go-ex/set"
+
+	"github.com/ImSingee/kitty/internal/lib/tl"
 )
 
 type State struct {
 	wd           string
 	gitRoot      string
 	shouldBackup bool
+	inPlace      bool     // the working tree may be modified, false if --isolated and there are no fixers
+	isolatedRoot string   // the checkout of the index for --isolated, empty if not used
+	tmpDir       string   // for the files passed to the commands, empty if the tasks are not run
+	submodules   []string // the initialized submodules relative to the git root, deepest first, with --recurse-submodules
 
 	hasPartiallyStagedFiles bool
 	taskResults             *sync.Map
 	ignoreChecker           *IgnoreChecker
+	facts                   *factsCache       // for the rule filters
+	workspaces              *workspaceFinder  // for the rules with the workspace option
+	cache                   *resultCache      // nil if --no-cache or --dry-run
+	tools                   *toolResolver     // the tools in .kitty/.bin
+	snapshot                *worktreeSnapshot // before running the tasks
+	changedLines            changedLines      // of the selected files
+	env                     []string          // extra environment variables for all commands
+	configTasks             []*configTasks
+	result                  *tl.Result // nil if the tasks are not run
 
 	output        []string // all outputs will print to stderr at end
 	errors        *set.Set[error]
@@ -26,13 +43,96 @@ type TaskResult struct {
 	fullCommandAndArgs string
 	output             []byte
 	err                error
+	timedOut           bool
+	duration           time.Duration
+
+	chunks []*TaskResult // results of every chunk if the files are split into chunks
+}
+
+// aggregateChunks sets the error of the result from its chunks
+func (r *TaskResult) aggregateChunks() {
+	for _, chunk := range r.chunks {
+		if chunk == nil { // skipped
+			continue
+		}
+
+		if chunk.err != nil && r.err == nil {
+			r.err = chunk.err
+		}
+		if chunk.timedOut {
+			r.timedOut = true
+		}
+	}
 }
 
 func getInitialState(wd string, options *Options) *State {
 	return &State{
 		wd:                      wd,
+		inPlace:                 true,
 		hasPartiallyStagedFiles: false,
 		taskResults:             &sync.Map{},
 		errors:                  set.New[error](),
 	}
 }
+
+// configTasks is the tasks generated for a config file, kept to build the report
+type configTasks struct {
+	config *Config
+	files  Files
+	task   *tl.Task // nil if it's the only config
+	rules  []*ruleTasks
+}
+
+// tasks returns the tasks of the rules
+func (c *configTasks) tasks() []*tl.Task {
+	if len(c.rules) == 0 {
+		return []*tl.Task{{
+			Title: "No Rules",
+			Run: func(callback tl.TaskCallback) error {
+				return nil
+			},
+		}}
+	}
+
+	return mr.Map(c.rules, func(in *ruleTasks, index int) *tl.Task {
+		return in.task
+	})
+}
+
+type ruleTasks struct {
+	config   *Config // the config of the rule, differs from the one of the files if inherited
+	rule     *Rule
+	files    Files
+	task     *tl.Task
+	commands []*commandTasks
+}
+
+type commandTasks struct {
+	cmd    *Command
+	files  Files
+	dir    string     // the working directory
+	chunks [][]string // the file arguments of each run
+	task   *tl.Task
+
+	isolated bool     // runs in the checkout of the index, see --isolated
+	planCmd  *Command // the command with the tool already installed, shown in the plan
+}
+
+// commandLines returns the command lines to run, one for each chunk
+func (c *commandTasks) commandLines() []string {
+	if len(c.files) == 0 {
+		return []string{}
+	}
+	cmd := c.cmd
+	if c.planCmd != nil {
+		cmd = c.planCmd
+	}
+
+	if len(c.chunks) == 0 { // noArgs
+		return []string{cmd.commandLine(nil)}
+	}
+
+	return mr.Map(c.chunks, func(chunk []string, index int) string {
+		return cmd.commandLine(chunk)
+	})
+}
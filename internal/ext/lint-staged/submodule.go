@@ -0,0 +1,121 @@
+package lintstaged
+
+import (
+	"log/slog"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ImSingee/go-ex/ee"
+	"github.com/ImSingee/go-ex/mr"
+)
+
+// With --recurse-submodules, the files inside the changed submodules are selected by the same status as the repository,
+// and matched with the configs inside the same submodule. The git commands run in the submodule containing the files,
+// while the backup stash and the index of the repository never touch the submodules.
+
+// getSubmodules returns the paths of the initialized submodules (recursively) relative to the git root, deepest first
+func getSubmodules(gitDir string) ([]string, error) {
+	output, err := execGit([]string{"submodule", "foreach", "--quiet", "--recursive", `echo "$displaypath"`}, gitDir)
+	if err != nil {
+		return nil, ee.Wrap(err, "cannot list submodules")
+	}
+	if output == "" {
+		return nil, nil
+	}
+
+	submodules := strings.Split(output, "\n")
+	sort.SliceStable(submodules, func(i, j int) bool {
+		return strings.Count(submodules[i], "/") > strings.Count(submodules[j], "/")
+	})
+	return submodules, nil
+}
+
+// getSubmoduleFiles returns the selected files inside the changed submodules, relative to the git root
+func getSubmoduleFiles(options *Options, gitDir string, submodules []string) ([]selectedFile, error) {
+	var result []selectedFile
+	for _, submodule := range submodules {
+		changed, err := isSubmoduleChanged(gitDir, submodules, submodule)
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			slog.Debug("Skipped unchanged submodule", "submodule", submodule)
+			continue
+		}
+
+		files, err := getSelectedFiles(options, filepath.Join(gitDir, submodule))
+		if err != nil {
+			return nil, ee.Wrapf(err, "cannot get selected files in submodule %s", submodule)
+		}
+
+		for _, file := range files {
+			file.path = path.Join(submodule, file.path)
+			if file.from != "" {
+				file.from = path.Join(submodule, file.from)
+			}
+			result = append(result, file)
+		}
+	}
+	return result, nil
+}
+
+// isSubmoduleChanged reports whether the gitlink of the submodule is changed in the repository (or submodule) containing it,
+// or the submodule has its own staged, unstaged or untracked changes
+func isSubmoduleChanged(gitDir string, submodules []string, submodule string) (bool, error) {
+	parent := submoduleOf(submodules, submodule)
+	gitlink := strings.TrimPrefix(submodule, parent+"/")
+
+	for _, check := range []struct {
+		dir  string
+		args []string
+	}{
+		{filepath.Join(gitDir, parent), []string{"status", "--porcelain", "--", gitlink}},
+		{filepath.Join(gitDir, submodule), []string{"status", "--porcelain"}},
+	} {
+		output, err := execGit(check.args, check.dir)
+		if err != nil {
+			return false, ee.Wrapf(err, "cannot get status of submodule %s", submodule)
+		}
+		if output != "" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// submoduleOf returns the submodule containing the path (relative to the git root), or empty if it's not in a submodule
+func submoduleOf(submodules []string, p string) string {
+	for _, submodule := range submodules { // deepest first
+		if strings.HasPrefix(p, submodule+"/") {
+			return submodule
+		}
+	}
+	return ""
+}
+
+// splitBySubmodule groups the paths (relative to the git root) by the submodules containing them, empty for the repository itself,
+// the paths in each group are relative to the root of the submodule
+func splitBySubmodule(submodules []string, paths []string) map[string][]string {
+	result := make(map[string][]string)
+	for _, p := range paths {
+		submodule := submoduleOf(submodules, p)
+		if submodule != "" {
+			p = strings.TrimPrefix(p, submodule+"/")
+		}
+		result[submodule] = append(result[submodule], p)
+	}
+	return result
+}
+
+// topLevelFiles returns the files not in any submodule
+func (s *State) topLevelFiles(files Files) Files {
+	if len(s.submodules) == 0 {
+		return files
+	}
+
+	return mr.Filter(files, func(in *File, index int) bool {
+		return submoduleOf(s.submodules, in.GitRelativePath()) == ""
+	})
+}
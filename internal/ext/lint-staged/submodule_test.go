@@ -0,0 +1,89 @@
+package lintstaged
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunRecurseSubmodules(t *testing.T) {
+	logs := t.TempDir()
+	log := func(name string) string { return filepath.Join(logs, name) }
+
+	lib := newTestRepo(t)
+	writeFile(t, lib, ".lintstagedrc.json", `{"*.txt": "[absolute] echo >> `+log("lib.log")+`"}`)
+	writeFile(t, lib, "a.txt", "a\n")
+	gitRun(t, lib, "add", ".")
+	gitRun(t, lib, "commit", "-m", "initial")
+
+	repo := newTestRepo(t)
+	writeFile(t, repo, ".lintstagedrc.json", `{"*.txt": "echo >> `+log("root.log")+`"}`)
+	gitRun(t, repo, "add", ".")
+	gitRun(t, repo, "-c", "protocol.file.allow=always", "submodule", "add", "--quiet", lib, "libs/lib")
+	gitRun(t, repo, "commit", "-m", "initial")
+
+	sub := filepath.Join(repo, "libs", "lib")
+	writeFile(t, sub, "a.txt", "a modified\n")
+	writeFile(t, sub, "b.txt", "b\n")
+	gitRun(t, sub, "add", ".")
+	writeFile(t, repo, "c.txt", "c\n")
+	gitRun(t, repo, "add", "c.txt")
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true}))
+	assert.Equal(t, "c.txt\n", readFile(t, logs, "root.log"), "the files in submodules are not selected by default")
+	assert.NoFileExists(t, log("lib.log"))
+
+	require.NoError(t, runInDir(t, repo, &Options{Stash: true, RecurseSubmodules: true}))
+	assert.Equal(t, "c.txt\nc.txt\n", readFile(t, logs, "root.log"), "the config of the repository is not used in the submodule")
+	assert.Equal(t, filepath.Join(sub, "a.txt")+" "+filepath.Join(sub, "b.txt")+"\n", readFile(t, logs, "lib.log"))
+
+	assert.Equal(t, "", gitOutput(t, repo, "stash", "list"))
+	assert.Equal(t, "a.txt\nb.txt\n", gitOutput(t, sub, "diff", "--staged", "--name-only"), "the index of the submodule is left alone")
+
+	t.Run("only submodule", func(t *testing.T) {
+		gitRun(t, repo, "commit", "-m", "c")
+
+		require.NoError(t, runInDir(t, repo, &Options{Stash: true, RecurseSubmodules: true}))
+		assert.Equal(t, "c.txt\nc.txt\n", readFile(t, logs, "root.log"))
+		assert.Contains(t, readFile(t, logs, "lib.log"), "\n"+filepath.Join(sub, "a.txt"))
+	})
+
+	t.Run("unchanged submodules", func(t *testing.T) {
+		other := newTestRepo(t)
+		writeFile(t, other, ".lintstagedrc.json", `{"*.txt": "echo >> `+log("other.log")+`"}`)
+		writeFile(t, other, "a.txt", "a\n")
+		gitRun(t, other, "add", ".")
+		gitRun(t, other, "commit", "-m", "initial")
+		gitRun(t, repo, "-c", "protocol.file.allow=always", "submodule", "add", "--quiet", other, "libs/other")
+		gitRun(t, repo, "commit", "-m", "other")
+
+		require.NoError(t, runInDir(t, repo, &Options{Status: string(SelectionModeAll), RecurseSubmodules: true}))
+		assert.NoFileExists(t, log("other.log"), "the files of the unchanged submodule are not selected")
+		assert.Contains(t, readFile(t, logs, "lib.log"), filepath.Join(sub, "b.txt"), "the submodule with changes is selected")
+
+		writeFile(t, filepath.Join(repo, "libs", "other"), "b.txt", "b\n")
+		require.NoError(t, runInDir(t, repo, &Options{Status: string(SelectionModeAll), RecurseSubmodules: true}))
+		assert.Equal(t, "b.txt a.txt\n", readFile(t, logs, "other.log"), "the submodule with untracked files is selected")
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		assert.Error(t, validateOptions(&Options{RecurseSubmodules: true, Since: "main"}))
+		assert.Error(t, validateOptions(&Options{RecurseSubmodules: true, Isolated: true}))
+	})
+}
+
+func TestSplitBySubmodule(t *testing.T) {
+	submodules := []string{"libs/a/nested", "libs/a", "libs/b"}
+
+	assert.Equal(t, "libs/a/nested", submoduleOf(submodules, "libs/a/nested/x.go"))
+	assert.Equal(t, "libs/a", submoduleOf(submodules, "libs/a/y.go"))
+	assert.Equal(t, "", submoduleOf(submodules, "libs/ab/z.go"))
+
+	assert.Equal(t, map[string][]string{
+		"":              {"main.go", "libs/ab/z.go"},
+		"libs/a":        {"y.go"},
+		"libs/a/nested": {"x.go"},
+	}, splitBySubmodule(submodules, []string{"main.go", "libs/a/y.go", "libs/a/nested/x.go", "libs/ab/z.go"}))
+}
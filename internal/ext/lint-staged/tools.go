@@ -0,0 +1,126 @@
+package lintstaged
+
+import (
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/ImSingee/go-ex/ee"
+
+	"github.com/ImSingee/kitty/internal/lib/shells"
+	"github.com/ImSingee/kitty/internal/tools"
+)
+
+// toolNameRegexp matches the first words of the commands which can be tools in .kitty/.bin
+var toolNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9._+-]*$`)
+
+// toolResolver resolves the first words of the commands to the tools in .kitty/.bin of the repository,
+// so the versions configured for the repository are used instead of the ones in PATH
+type toolResolver struct {
+	root    string
+	install bool // install (or update) the configured tools on demand
+
+	mu    sync.Mutex
+	paths map[string]string // by name, empty if it's not a tool
+}
+
+func newToolResolver(root string, install bool) *toolResolver {
+	return &toolResolver{
+		root:    root,
+		install: install,
+		paths:   make(map[string]string),
+	}
+}
+
+// toolName returns the first word of the command if it can be a tool, or empty
+func (c *Command) toolName() string {
+	name := c.execCommand
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name = name[:i]
+	}
+
+	if name == "kitty" || !toolNameRegexp.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+// withProgram returns a copy of the command running the program at path instead of its first word
+func (c *Command) withProgram(path string) *Command {
+	name := c.toolName()
+	program := shells.Quote(path)
+
+	result := *c
+	result.execCommand = program + c.execCommand[len(name):]
+	if c.rangeTemplate != "" || c.placeholder != "" {
+		result.argsAt += len(program) - len(name)
+	}
+	return &result
+}
+
+// installedCommand returns the command running the tool already installed, without installing it
+func (r *toolResolver) installedCommand(cmd *Command) *Command {
+	if r == nil || cmd.toolName() == "" {
+		return cmd
+	}
+
+	if path := r.lookup(cmd.toolName()); path != "" {
+		return cmd.withProgram(path)
+	}
+	return cmd
+}
+
+// command returns the command running the tool, which is installed first if it's configured but not installed (or outdated)
+func (r *toolResolver) command(cmd *Command) (*Command, error) {
+	name := cmd.toolName()
+	if r == nil || name == "" {
+		return cmd, nil
+	}
+
+	path, err := r.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		return cmd, nil
+	}
+	return cmd.withProgram(path), nil
+}
+
+func (r *toolResolver) resolve(name string) (string, error) {
+	// the tools are installed one by one
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if path, ok := r.paths[name]; ok {
+		return path, nil
+	}
+
+	if r.install {
+		// the task list is rendering, the progress of the installation is not shown
+		if err := tools.EnsureInstalledQuiet(r.root, name); err != nil {
+			return "", ee.Wrapf(err, "cannot install tool `%s`", name)
+		}
+	}
+
+	path := r.lookup(name)
+	if path != "" {
+		slog.Debug("Resolved tool", "name", name, "path", path)
+	}
+	r.paths[name] = path
+
+	return path, nil
+}
+
+// lookup returns the path of the tool in .kitty/.bin, or empty if it's not installed
+func (r *toolResolver) lookup(name string) string {
+	path, err := exec.LookPath(filepath.Join(r.root, ".kitty", ".bin", name))
+	if err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,30 @@
+package lintstaged
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommandWithProgram(t *testing.T) {
+	const tool = "/my repo/.kitty/.bin/golangci-lint"
+
+	for command, expected := range map[string]string{
+		"golangci-lint run":                    "'/my repo/.kitty/.bin/golangci-lint' run a.go:1",
+		"golangci-lint run {files} --fast":     "'/my repo/.kitty/.bin/golangci-lint' run a.go:1 --fast",
+		"golangci-lint --lines {file}:{start}": "'/my repo/.kitty/.bin/golangci-lint' --lines a.go:1",
+	} {
+		cmd, err := parseStringCommand(command)
+		require.NoError(t, err)
+		require.Equal(t, "golangci-lint", cmd.toolName())
+
+		assert.Equal(t, expected, cmd.withProgram(tool).commandLine([]string{"a.go:1"}), command)
+	}
+
+	for _, command := range []string{"./scripts/lint.sh", "FOO=1 lint", "kitty @version", "{files}"} {
+		cmd, err := parseStringCommand(command)
+		require.NoError(t, err)
+		assert.Equal(t, "", cmd.toolName(), command)
+	}
+}
@@ -0,0 +1,97 @@
+package lintstaged
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ImSingee/kitty/internal/config"
+)
+
+// defaultWorkspaceMarkers are the files marking a workspace with "workspace": true
+var defaultWorkspaceMarkers = []string{"go.mod", "package.json", "Cargo.toml"}
+
+// parseWorkspace parses the workspace option of a rule, true for the default markers,
+// or the marker files (a string or a list of strings); nil means the rule runs in the config's directory
+func parseWorkspace(path []any, v any) ([]string, error) {
+	if b, ok := v.(bool); ok {
+		if b {
+			return defaultWorkspaceMarkers, nil
+		}
+		return nil, nil
+	}
+
+	markers, err := stringOrList(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: must be a boolean, a string or a list of strings", config.FormatPath(path))
+	}
+	if len(markers) == 0 {
+		return nil, fmt.Errorf("%s: empty list", config.FormatPath(path))
+	}
+	for _, marker := range markers {
+		if marker == "" || strings.ContainsAny(marker, `/\`) {
+			return nil, fmt.Errorf("%s: invalid marker file `%s` (must be a file name)", config.FormatPath(path), marker)
+		}
+	}
+	return markers, nil
+}
+
+// workspaceFinder finds the nearest directory containing a marker file of the files, up to the git root
+type workspaceFinder struct {
+	root   string
+	exists map[string]bool // by the path of the marker files
+}
+
+func newWorkspaceFinder(root string) *workspaceFinder {
+	return &workspaceFinder{
+		root:   root,
+		exists: make(map[string]bool),
+	}
+}
+
+// find returns the workspace of the file, or empty if it's not in any workspace
+func (f *workspaceFinder) find(file string, markers []string) string {
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		for _, marker := range markers {
+			if f.fileExists(filepath.Join(dir, marker)) {
+				return dir
+			}
+		}
+
+		if dir == f.root || dir == filepath.Dir(dir) || !strings.HasPrefix(dir, f.root) {
+			return ""
+		}
+	}
+}
+
+func (f *workspaceFinder) fileExists(p string) bool {
+	exists, ok := f.exists[p]
+	if !ok {
+		info, err := os.Stat(p)
+		exists = err == nil && !info.IsDir()
+		f.exists[p] = exists
+	}
+	return exists
+}
+
+// groupFilesByWorkspace groups the files by their workspaces (sorted), the files not in any workspace use defaultDir
+func (f *workspaceFinder) groupFilesByWorkspace(files Files, markers []string, defaultDir string) ([]string, map[string]Files) {
+	groups := make(map[string]Files)
+	for _, file := range files {
+		dir := f.find(file.AbsolutePath(), markers)
+		if dir == "" {
+			dir = defaultDir
+		}
+		groups[dir] = append(groups[dir], file)
+	}
+
+	dirs := make([]string, 0, len(groups))
+	for dir := range groups {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+
+	return dirs, groups
+}
@@ -4,7 +4,6 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
 
 	"github.com/ImSingee/go-ex/ee"
 	"github.com/ysmood/gson"
@@ -89,29 +88,28 @@ func getAppFromUrl(name string, url string) (*App, error) {
 	return app, nil
 }
 
-const KittyRegistryEnv = "KITTY_REGISTRY"
-const DefaultKittyRegistry = "https://raw.githubusercontent.com/ImSingee/kitty-registry/master/"
+const KittyRegistryEnv = config.RegistryEnv
+const DefaultKittyRegistry = config.DefaultRegistry
 
+// GetRegistry returns the registry resolved from (in order of precedence)
+// the KITTY_REGISTRY environment variable, the repo-level config, the user-level config and the default one
 func GetRegistry() (string, error) {
-	// from environment variable
-	if r := os.Getenv(KittyRegistryEnv); r != "" {
-		return r, nil
+	c, err := config.Resolve("")
+	if err != nil {
+		return "", ee.Wrap(err, "cannot load kitty config")
 	}
 
-	// from config file
-	c, err := config.GetKittyConfig("")
-	if err != nil && !ee.Is(err, os.ErrNotExist) {
-		return "", ee.Wrap(err, "cannot load kitty config")
+	registryVal, exists := c.Get("registry")
+	if !exists {
+		return DefaultKittyRegistry, nil
 	}
-	registryVal := c["registry"].Val()
-	if registryVal != nil {
-		if r, ok := registryVal.(string); !ok {
-			return "", ee.New("invalid registry value (type is not string) in kitty config")
-		} else if r != "" {
-			return r, nil
-		}
+	r, ok := registryVal.(string)
+	if !ok {
+		return "", ee.Errorf("invalid registry value (type is not string) in %s", c.Origin("registry"))
+	}
+	if r == "" {
+		return DefaultKittyRegistry, nil
 	}
 
-	// default
-	return DefaultKittyRegistry, nil
+	return r, nil
 }
@@ -0,0 +1,160 @@
+package glob
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// expandBraces expands the braces of the pattern, e.g. `*.{js,ts}` to `*.js` and `*.ts`
+//
+// a brace without comma or range (e.g. `{a}`) or without matching close brace is kept as is
+func expandBraces(pattern string) []string {
+	return expandBracesFrom(pattern, 0)
+}
+
+func expandBracesFrom(pattern string, from int) []string {
+	for i := from; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			end := closingBrace(pattern, i)
+			if end < 0 {
+				return []string{pattern}
+			}
+
+			options := braceOptions(pattern[i+1 : end])
+			if options == nil {
+				continue
+			}
+
+			prefix, suffix := pattern[:i], pattern[end+1:]
+			var result []string
+			for _, option := range options {
+				// the option and the suffix may contain braces too
+				result = append(result, expandBracesFrom(prefix+option+suffix, i)...)
+			}
+			return result
+		}
+	}
+
+	return []string{pattern}
+}
+
+// closingBrace returns the index of the brace closing the one at start, or -1 if not found
+func closingBrace(pattern string, start int) int {
+	depth := 0
+	for i := start; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// braceOptions splits the content of braces by the top-level commas, or expands it as a range
+//
+// it returns nil if the content is neither a list nor a range
+func braceOptions(content string) []string {
+	var options []string
+
+	depth, last := 0, 0
+	for i := 0; i < len(content); i++ {
+		switch content[i] {
+		case '\\':
+			i++
+		case '{', '(':
+			depth++
+		case '}', ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				options = append(options, content[last:i])
+				last = i + 1
+			}
+		}
+	}
+
+	if options != nil {
+		return append(options, content[last:])
+	}
+
+	return braceRange(content)
+}
+
+var rangeRegexp = regexp.MustCompile(`^(-?[0-9]+|[a-zA-Z])\.\.(-?[0-9]+|[a-zA-Z])(?:\.\.(-?[0-9]+))?$`)
+
+// braceRange expands the ranges like `1..3`, `01..10`, `a..e` and `1..10..2`
+func braceRange(content string) []string {
+	m := rangeRegexp.FindStringSubmatch(content)
+	if m == nil {
+		return nil
+	}
+
+	step := 1
+	if m[3] != "" {
+		step, _ = strconv.Atoi(m[3])
+		if step < 0 {
+			step = -step
+		}
+		if step == 0 {
+			step = 1
+		}
+	}
+
+	from, errFrom := strconv.Atoi(m[1])
+	to, errTo := strconv.Atoi(m[2])
+	isNumber := errFrom == nil && errTo == nil
+	if !isNumber {
+		if errFrom == nil || errTo == nil { // mixed number and letter
+			return nil
+		}
+		from, to = int(m[1][0]), int(m[2][0])
+	}
+
+	width := 0
+	if isNumber && (hasLeadingZero(m[1]) || hasLeadingZero(m[2])) {
+		width = max(len(m[1]), len(m[2]))
+	}
+
+	format := func(n int) string {
+		if !isNumber {
+			return string(rune(n))
+		}
+
+		s := strconv.Itoa(n)
+		if len(s) < width {
+			if n < 0 {
+				return "-" + strings.Repeat("0", width-len(s)) + s[1:]
+			}
+			return strings.Repeat("0", width-len(s)) + s
+		}
+		return s
+	}
+
+	var result []string
+	if from <= to {
+		for n := from; n <= to; n += step {
+			result = append(result, format(n))
+		}
+	} else {
+		for n := from; n >= to; n -= step {
+			result = append(result, format(n))
+		}
+	}
+	return result
+}
+
+func hasLeadingZero(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
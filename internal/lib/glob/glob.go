@@ -1,20 +1,92 @@
+// Package glob implements the glob patterns of lint-staged, which are compatible with micromatch
+// (with the options lint-staged uses: dotfiles are matched, and a pattern without slash matches the base name)
+//
+// supported syntax:
+//   - `*` matches any characters except `/`, `?` matches one character except `/`
+//   - `**` as a whole path segment matches any number of segments (including zero)
+//   - `[abc]`, `[a-z]`, `[!a]`, `[^a]` and the POSIX classes like `[[:alpha:]]`
+//   - `{a,b}` and the ranges `{1..3}`, `{a..c}` (can be nested)
+//   - extglobs `@(a|b)`, `?(a|b)`, `*(a|b)`, `+(a|b)` and `!(a|b)`
+//   - a leading `!` negates the whole pattern
+//   - `\` escapes the next character
 package glob
 
 import (
-	"path/filepath"
+	"path"
 	"strings"
 
-	"github.com/gobwas/glob"
+	"github.com/ImSingee/go-ex/ee"
 )
 
-func Match(pattern string, g glob.Glob, name string) bool {
-	if strings.Contains(pattern, "/") {
-		return match(g, name)
-	} else {
-		return match(g, filepath.Base(name))
+// Glob is a compiled glob pattern
+type Glob struct {
+	pattern   string
+	negated   bool
+	matchBase bool
+	alts      [][]token // one for each brace expansion
+}
+
+// Compile parses a glob pattern
+func Compile(pattern string) (*Glob, error) {
+	g := &Glob{pattern: pattern}
+
+	p := pattern
+	for strings.HasPrefix(p, "!") && !strings.HasPrefix(p, "!(") {
+		g.negated = !g.negated
+		p = p[1:]
+	}
+	p = strings.TrimPrefix(p, "./")
+	if p == "" {
+		return nil, ee.Errorf("invalid glob pattern `%s`: empty pattern", pattern)
+	}
+
+	g.matchBase = !strings.Contains(p, "/")
+
+	for _, expanded := range expandBraces(p) {
+		tokens, err := parse(expanded)
+		if err != nil {
+			return nil, ee.Wrapf(err, "invalid glob pattern `%s`", pattern)
+		}
+		g.alts = append(g.alts, tokens)
 	}
+
+	return g, nil
 }
 
-func match(g glob.Glob, target string) bool {
-	return g.Match(target)
+// MustCompile is like Compile but panics if the pattern cannot be parsed
+func MustCompile(pattern string) *Glob {
+	g, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// String returns the original pattern
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// IsParentPattern reports whether the pattern targets the files outside its base directory (starts with `../`)
+func (g *Glob) IsParentPattern() bool {
+	return strings.HasPrefix(strings.TrimLeft(g.pattern, "!"), "../")
+}
+
+// Match reports whether name matches the pattern,
+// name is a slash-separated path relative to the base directory of the pattern (e.g. the directory of the config file)
+func (g *Glob) Match(name string) bool {
+	name = strings.TrimPrefix(name, "./")
+	if g.matchBase {
+		name = path.Base(name)
+	}
+
+	matched := false
+	for _, tokens := range g.alts {
+		if matchAll(tokens, name) {
+			matched = true
+			break
+		}
+	}
+
+	return matched != g.negated
 }
@@ -0,0 +1,164 @@
+package glob
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the files used by lint-staged's generateTasks tests
+var lintStagedFiles = []string{
+	"test.js",
+	"deeper/test.js",
+	"deeper/test2.js",
+	"even/deeper/test.js",
+	".hidden/test.js",
+	"test.css",
+	"deeper/test1.css",
+	"deeper/test2.css",
+	"even/deeper/test.css",
+	".hidden/test.css",
+}
+
+func TestMatchLintStagedCases(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"*.js", []string{"test.js", "deeper/test.js", "deeper/test2.js", "even/deeper/test.js", ".hidden/test.js"}},
+		{"**/*.js", []string{"test.js", "deeper/test.js", "deeper/test2.js", "even/deeper/test.js", ".hidden/test.js"}},
+		{"deeper/*.js", []string{"deeper/test.js", "deeper/test2.js"}},
+		{".hidden/*.js", []string{".hidden/test.js"}},
+		{"test{1..2}.css", []string{"deeper/test1.css", "deeper/test2.css"}},
+		{"*.{js,css}", lintStagedFiles},
+		{"even/**", []string{"even/deeper/test.js", "even/deeper/test.css"}},
+		{"**/deeper/*.css", []string{"deeper/test1.css", "deeper/test2.css", "even/deeper/test.css"}},
+		{"!(*.js)", []string{"test.css", "deeper/test1.css", "deeper/test2.css", "even/deeper/test.css", ".hidden/test.css"}},
+		{"!**/deeper/**", []string{"test.js", ".hidden/test.js", "test.css", ".hidden/test.css"}},
+		{"../*.js", nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			g, err := Compile(tc.pattern)
+			require.NoError(t, err)
+
+			var matched []string
+			for _, f := range lintStagedFiles {
+				if g.Match(f) {
+					matched = append(matched, f)
+				}
+			}
+			assert.Equal(t, tc.expected, matched)
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		// basename matching for patterns without slash
+		{"*", []string{"a", ".env", "a/b.js", "a/.env"}, nil},
+		{"*.js", []string{"a.js", ".eslintrc.js", "a/b/c.js"}, []string{"a.jsx", "a.js/b"}},
+		{"?.js", []string{"a.js", "b/c.js"}, []string{"ab.js", ".js"}},
+		{"README.md", []string{"README.md", "docs/README.md"}, []string{"readme.md"}},
+
+		// paths
+		{"src/*.js", []string{"src/a.js", "src/.a.js"}, []string{"a.js", "src/a/b.js", "lib/src/a.js"}},
+		{"./src/*.js", []string{"src/a.js"}, []string{"a.js"}},
+		{"a/*/b", []string{"a/x/b", "a/.x/b"}, []string{"a/b", "a/x/y/b"}},
+		{"../*.js", []string{"../a.js"}, []string{"a.js", "../a/b.js"}},
+
+		// globstar
+		{"**", []string{"a", "a/b", ".a/.b/c"}, nil},
+		{"**/*.js", []string{"a.js", "a/b.js", "a/b/c.js", ".a/b.js"}, []string{"a.ts", "a.js/b"}},
+		{"src/**", []string{"src", "src/a.js", "src/a/b/c.js"}, []string{"srcx/a.js", "lib/src/a.js"}},
+		{"src/**/*.js", []string{"src/a.js", "src/a/b.js", "src/a/b/c.js"}, []string{"a.js", "src/a.ts"}},
+		{"src/**/test/*.js", []string{"src/test/a.js", "src/a/b/test/a.js"}, []string{"src/test/a/b.js", "src/atest/a.js"}},
+		{"**/test.js", []string{"test.js", "a/test.js", "a/b/test.js"}, []string{"atest.js", "a/btest.js"}},
+		{"**/.*", []string{".gitignore", "a/.env"}, []string{"a/b"}},
+		{"a**/*.js", []string{"a/b.js", "ab/c.js"}, []string{"a/b/c.js", "b/c.js"}},
+
+		// braces
+		{"*.{js,ts}", []string{"a.js", "a/b.ts"}, []string{"a.css", "a.jsts"}},
+		{"*.{js,{ts,tsx}}", []string{"a.js", "a.ts", "a.tsx"}, []string{"a.css"}},
+		{"{src,lib}/**/*.go", []string{"src/a.go", "lib/a/b.go"}, []string{"cmd/a.go"}},
+		{"file{01..10}.txt", []string{"file01.txt", "file05.txt", "file10.txt"}, []string{"file1.txt", "file11.txt"}},
+		{"{a..c}.txt", []string{"a.txt", "b.txt", "c.txt"}, []string{"d.txt"}},
+		{"{1..9..4}.txt", []string{"1.txt", "5.txt", "9.txt"}, []string{"2.txt"}},
+		{"{a}.txt", []string{"{a}.txt"}, []string{"a.txt"}},
+		{"a{,.min}.js", []string{"a.js", "a.min.js"}, []string{"a.max.js"}},
+		{`\{a,b\}.txt`, []string{"{a,b}.txt"}, []string{"a.txt"}},
+
+		// brackets
+		{"[abc].txt", []string{"a.txt", "c.txt"}, []string{"d.txt", "ab.txt"}},
+		{"[a-c]*.go", []string{"a.go", "cat.go"}, []string{"dog.go"}},
+		{"[!abc].txt", []string{"d.txt"}, []string{"a.txt"}},
+		{"[^abc].txt", []string{"d.txt"}, []string{"a.txt"}},
+		{"[[:digit:]].txt", []string{"1.txt"}, []string{"a.txt"}},
+		{"[[:upper:][:digit:]]*", []string{"README", "1.txt"}, []string{"readme"}},
+		{"[]a].txt", []string{"].txt", "a.txt"}, []string{"b.txt"}},
+		{"a[/]b", nil, []string{"a/b"}},
+
+		// extglobs
+		{"!(*.md)", []string{"a.js", "b/c.ts"}, []string{"README.md", "docs/a.md"}},
+		{"*.!(js)", []string{"a.ts", "a.jsx"}, []string{"a.js"}},
+		{"!(foo).js", []string{"bar.js", "foobar.js"}, []string{"foo.js"}},
+		{"@(foo|bar)/*.go", []string{"foo/a.go", "bar/b.go"}, []string{"baz/a.go", "foobar/a.go"}},
+		{"+(a|b).js", []string{"a.js", "ab.js", "bba.js"}, []string{".js", "c.js"}},
+		{"*(a|b).js", []string{".js", "a.js", "abab.js"}, []string{"c.js"}},
+		{"?(a|b).js", []string{".js", "a.js"}, []string{"ab.js"}},
+		{"(a|b).js", []string{"a.js", "b.js"}, []string{"c.js"}},
+		{"*.@(js|ts)?(x)", []string{"a.js", "a.tsx"}, []string{"a.css", "a.jsxx"}},
+
+		// negation
+		{"!*.md", []string{"a.js", "docs/a.js"}, []string{"README.md", "docs/a.md"}},
+		{"!vendor/**", []string{"a.go", "src/vendor/a.go"}, []string{"vendor", "vendor/a.go", "vendor/a/b.go"}},
+		{"!!*.md", []string{"a.md"}, []string{"a.js"}},
+
+		// escapes
+		{`\*.js`, []string{"*.js"}, []string{"a.js"}},
+		{`a\?.js`, []string{"a?.js"}, []string{"ab.js"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			g, err := Compile(tc.pattern)
+			require.NoError(t, err)
+
+			for _, name := range tc.match {
+				assert.True(t, g.Match(name), "%s should match %s", tc.pattern, name)
+			}
+			for _, name := range tc.noMatch {
+				assert.False(t, g.Match(name), "%s should not match %s", tc.pattern, name)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pattern := range []string{"", "!", "[abc", "*.[", "@(a|b", "a)", "[[:foo:]]"} {
+		_, err := Compile(pattern)
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestIsParentPattern(t *testing.T) {
+	assert.True(t, MustCompile("../*.js").IsParentPattern())
+	assert.True(t, MustCompile("!../*.js").IsParentPattern())
+	assert.False(t, MustCompile("*.js").IsParentPattern())
+	assert.False(t, MustCompile("a/../*.js").IsParentPattern())
+}
+
+func TestExpandBraces(t *testing.T) {
+	assert.Equal(t, []string{"a.js", "a.ts"}, expandBraces("a.{js,ts}"))
+	assert.Equal(t, []string{"a/c", "a/d", "b/c", "b/d"}, expandBraces("{a,b}/{c,d}"))
+	assert.Equal(t, []string{"3", "2", "1"}, expandBraces("{3..1}"))
+	assert.Equal(t, []string{"-01", "000", "001"}, expandBraces("{-01..1}"))
+	assert.Equal(t, []string{"{a"}, expandBraces("{a"))
+	assert.Equal(t, []string{"{a..1}"}, expandBraces("{a..1}"))
+}
@@ -0,0 +1,133 @@
+package glob
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+func matchAll(tokens []token, s string) bool {
+	return matchTokens(tokens, s, 0, func(i int) bool { return i == len(s) })
+}
+
+// matchTokens reports whether tokens match s from i, and the rest of s (from where tokens end) is accepted by next
+func matchTokens(tokens []token, s string, i int, next func(int) bool) bool {
+	if len(tokens) == 0 {
+		return next(i)
+	}
+
+	t := &tokens[0]
+	rest := func(j int) bool {
+		return matchTokens(tokens[1:], s, j, next)
+	}
+
+	switch t.kind {
+	case tokenLiteral:
+		return strings.HasPrefix(s[i:], t.text) && rest(i+len(t.text))
+	case tokenStar:
+		for j := i; ; {
+			if rest(j) {
+				return true
+			}
+			if j == len(s) || s[j] == '/' {
+				return false
+			}
+			_, w := utf8.DecodeRuneInString(s[j:])
+			j += w
+		}
+	case tokenQuestion, tokenClass:
+		if i == len(s) || s[i] == '/' {
+			return false
+		}
+		r, w := utf8.DecodeRuneInString(s[i:])
+		if t.kind == tokenClass && !t.matchRune(r) {
+			return false
+		}
+		return rest(i + w)
+	case tokenGlobstar:
+		for j := i; j <= len(s); j++ {
+			if rest(j) {
+				return true
+			}
+		}
+		return false
+	case tokenGlobstarPrefix:
+		if rest(i) {
+			return true
+		}
+		for j := i; j < len(s); j++ {
+			if s[j] == '/' && rest(j+1) {
+				return true
+			}
+		}
+		return false
+	case tokenGlobstarSuffix:
+		if rest(i) {
+			return true
+		}
+		if i < len(s) && s[i] == '/' {
+			for j := i + 1; j <= len(s); j++ {
+				if rest(j) {
+					return true
+				}
+			}
+		}
+		return false
+	case tokenExtglob:
+		return matchExtglob(t, s, i, rest)
+	default:
+		return false
+	}
+}
+
+func matchExtglob(t *token, s string, i int, next func(int) bool) bool {
+	matchAny := func(i int, next func(int) bool) bool {
+		for _, alt := range t.alts {
+			if matchTokens(alt, s, i, next) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// repeat matches the alternatives one or more times
+	var repeat func(i int) bool
+	repeat = func(i int) bool {
+		return matchAny(i, func(j int) bool {
+			return next(j) || (j > i && repeat(j))
+		})
+	}
+
+	switch t.op {
+	case '@':
+		return matchAny(i, next)
+	case '?':
+		return next(i) || matchAny(i, next)
+	case '*':
+		return next(i) || repeat(i)
+	case '+':
+		return repeat(i)
+	case '!':
+		// any characters in the segment that cannot match the alternatives
+		for j := i; ; {
+			if !matchAnyAlt(t.alts, s[i:j]) && next(j) {
+				return true
+			}
+			if j == len(s) || s[j] == '/' {
+				return false
+			}
+			_, w := utf8.DecodeRuneInString(s[j:])
+			j += w
+		}
+	default:
+		return false
+	}
+}
+
+func matchAnyAlt(alts [][]token, s string) bool {
+	for _, alt := range alts {
+		if matchAll(alt, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package glob
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ImSingee/go-ex/ee"
+)
+
+type tokenKind int
+
+const (
+	tokenLiteral        tokenKind = iota
+	tokenStar                     // `*`
+	tokenQuestion                 // `?`
+	tokenGlobstar                 // `**` as the whole pattern, matches anything
+	tokenGlobstarPrefix           // `**/`, matches nothing or any segments ending with `/`
+	tokenGlobstarSuffix           // `/**` at the end, matches nothing or `/` followed by anything
+	tokenClass                    // `[...]`
+	tokenExtglob                  // `@(...)`, `?(...)`, `*(...)`, `+(...)` and `!(...)`
+)
+
+type token struct {
+	kind tokenKind
+
+	text string // for tokenLiteral
+
+	negated bool              // for tokenClass
+	items   []func(rune) bool // for tokenClass
+
+	op   byte      // for tokenExtglob
+	alts [][]token // for tokenExtglob
+}
+
+func (t *token) matchRune(r rune) bool {
+	for _, item := range t.items {
+		if item(r) {
+			return !t.negated
+		}
+	}
+	return t.negated
+}
+
+var posixClasses = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"ascii":  func(r rune) bool { return r < utf8.RuneSelf },
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"word":   func(r rune) bool { return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"xdigit": func(r rune) bool { return strings.ContainsRune("0123456789abcdefABCDEF", r) },
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+// parse parses a pattern without braces (see expandBraces)
+func parse(pattern string) ([]token, error) {
+	p := &parser{s: pattern}
+
+	tokens, stop, err := p.parseSequence(false)
+	if err != nil {
+		return nil, err
+	}
+	if stop != 0 {
+		return nil, ee.Errorf("unexpected `%c` at %d", stop, p.i)
+	}
+
+	return tokens, nil
+}
+
+// parseSequence parses until the end of the pattern, or `|` / `)` if inside an extglob (which is returned as stop)
+func (p *parser) parseSequence(inGroup bool) (tokens []token, stop byte, err error) {
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+
+		switch {
+		case c == ')' || (c == '|' && inGroup):
+			if !inGroup {
+				return nil, c, nil
+			}
+			p.i++
+			return tokens, c, nil
+		case c == '\\':
+			if p.i+1 < len(p.s) {
+				_, w := utf8.DecodeRuneInString(p.s[p.i+1:])
+				tokens = appendLiteral(tokens, p.s[p.i+1:p.i+1+w])
+				p.i += 1 + w
+			} else {
+				tokens = appendLiteral(tokens, `\`)
+				p.i++
+			}
+		case strings.IndexByte("@?*+!", c) != -1 && p.peek(1) == '(':
+			t, err := p.parseExtglob(c, 2)
+			if err != nil {
+				return nil, 0, err
+			}
+			tokens = append(tokens, t)
+		case c == '(':
+			// a group without operator is the same as `@(...)`
+			t, err := p.parseExtglob('@', 1)
+			if err != nil {
+				return nil, 0, err
+			}
+			tokens = append(tokens, t)
+		case c == '*':
+			tokens = p.parseStar(tokens, inGroup)
+		case c == '?':
+			tokens = append(tokens, token{kind: tokenQuestion})
+			p.i++
+		case c == '[':
+			t, err := p.parseClass()
+			if err != nil {
+				return nil, 0, err
+			}
+			tokens = append(tokens, t)
+		default:
+			_, w := utf8.DecodeRuneInString(p.s[p.i:])
+			tokens = appendLiteral(tokens, p.s[p.i:p.i+w])
+			p.i += w
+		}
+	}
+
+	if inGroup {
+		return nil, 0, ee.New("missing closing `)`")
+	}
+
+	return tokens, 0, nil
+}
+
+func (p *parser) peek(n int) byte {
+	if p.i+n < len(p.s) {
+		return p.s[p.i+n]
+	}
+	return 0
+}
+
+// parseStar parses `*` and `**`, the latter is a globstar only if it's a whole path segment
+func (p *parser) parseStar(tokens []token, inGroup bool) []token {
+	start := p.i
+	for p.i < len(p.s) && p.s[p.i] == '*' {
+		p.i++
+	}
+
+	if p.i-start == 1 || inGroup {
+		return append(tokens, token{kind: tokenStar})
+	}
+
+	atSegmentStart := len(tokens) == 0 || (tokens[len(tokens)-1].kind == tokenLiteral && strings.HasSuffix(tokens[len(tokens)-1].text, "/"))
+	if !atSegmentStart {
+		return append(tokens, token{kind: tokenStar})
+	}
+
+	switch {
+	case p.i < len(p.s) && p.s[p.i] == '/':
+		p.i++
+		return append(tokens, token{kind: tokenGlobstarPrefix})
+	case p.i == len(p.s) && len(tokens) == 0:
+		return append(tokens, token{kind: tokenGlobstar})
+	case p.i == len(p.s):
+		last := &tokens[len(tokens)-1]
+		last.text = strings.TrimSuffix(last.text, "/")
+		if last.text == "" {
+			tokens = tokens[:len(tokens)-1]
+		}
+		return append(tokens, token{kind: tokenGlobstarSuffix})
+	default:
+		return append(tokens, token{kind: tokenStar})
+	}
+}
+
+// parseExtglob parses the extglob starting at the current position, skip is the length of the op and `(`
+func (p *parser) parseExtglob(op byte, skip int) (token, error) {
+	start := p.i
+	p.i += skip
+
+	t := token{kind: tokenExtglob, op: op}
+	for {
+		alt, stop, err := p.parseSequence(true)
+		if err != nil {
+			return token{}, ee.Wrapf(err, "invalid extglob at %d", start)
+		}
+		t.alts = append(t.alts, alt)
+		if stop == ')' {
+			return t, nil
+		}
+	}
+}
+
+func (p *parser) parseClass() (token, error) {
+	start := p.i
+	p.i++ // `[`
+
+	t := token{kind: tokenClass}
+	if c := p.peek(0); c == '!' || c == '^' {
+		t.negated = true
+		p.i++
+	}
+
+	for first := true; ; first = false {
+		if p.i >= len(p.s) {
+			return token{}, ee.Errorf("missing closing `]` of the bracket at %d", start)
+		}
+
+		if p.s[p.i] == ']' && !first {
+			p.i++
+			return t, nil
+		}
+
+		if strings.HasPrefix(p.s[p.i:], "[:") {
+			if end := strings.Index(p.s[p.i+2:], ":]"); end != -1 {
+				name := p.s[p.i+2 : p.i+2+end]
+				class, ok := posixClasses[name]
+				if !ok {
+					return token{}, ee.Errorf("unknown character class `[:%s:]`", name)
+				}
+				t.items = append(t.items, class)
+				p.i += 2 + end + 2
+				continue
+			}
+		}
+
+		lo := p.classRune()
+		if p.peek(0) == '-' && p.peek(1) != ']' && p.peek(1) != 0 {
+			p.i++
+			hi := p.classRune()
+			t.items = append(t.items, func(r rune) bool { return lo <= r && r <= hi })
+		} else {
+			t.items = append(t.items, func(r rune) bool { return r == lo })
+		}
+	}
+}
+
+// classRune reads a (maybe escaped) character inside a bracket
+func (p *parser) classRune() rune {
+	if p.s[p.i] == '\\' && p.i+1 < len(p.s) {
+		p.i++
+	}
+	r, w := utf8.DecodeRuneInString(p.s[p.i:])
+	p.i += w
+	return r
+}
+
+func appendLiteral(tokens []token, s string) []token {
+	if len(tokens) != 0 && tokens[len(tokens)-1].kind == tokenLiteral {
+		tokens[len(tokens)-1].text += s
+		return tokens
+	}
+	return append(tokens, token{kind: tokenLiteral, text: s})
+}
@@ -2,6 +2,7 @@ package tl
 
 import (
 	"strings"
+	"sync"
 
 	"github.com/ImSingee/go-ex/mr"
 	tea "github.com/charmbracelet/bubbletea"
@@ -34,6 +35,7 @@ func (tl *TaskList) use() {
 	if !tl.inited {
 		tl.option = defaultOption()
 	}
+	tl.option.concurrent = 1 // not inherited
 
 	for _, applyOpt := range tl.Options {
 		applyOpt(&tl.option)
@@ -97,6 +99,10 @@ func (tl *TaskList) start(p *tea.Program) (result *Result) {
 		SubResults: make([]*Result, len(tl.tasks)),
 	}
 
+	if tl.concurrent != 1 && len(tl.tasks) > 1 {
+		return tl.startConcurrent(p, result)
+	}
+
 	preventContinue := false
 
 	for i, task := range tl.tasks {
@@ -118,3 +124,59 @@ func (tl *TaskList) start(p *tea.Program) (result *Result) {
 	}
 	return
 }
+
+// startConcurrent is like start, but runs at most tl.concurrent tasks at the same time
+//
+// if a task fails and the list should exit on error, the running tasks are kept
+// and the tasks not started yet are skipped
+func (tl *TaskList) startConcurrent(p *tea.Program, result *Result) *Result {
+	limit := tl.concurrent
+	if limit <= 0 || limit > len(tl.tasks) {
+		limit = len(tl.tasks)
+	}
+
+	var (
+		mu              sync.Mutex
+		wg              sync.WaitGroup
+		preventContinue bool
+	)
+	sem := make(chan struct{}, limit)
+
+	for i, task := range tl.tasks {
+		sem <- struct{}{}
+
+		mu.Lock()
+		stop := preventContinue
+		mu.Unlock()
+		if stop {
+			<-sem
+			task.skip(p)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, task *Task) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			taskResult := task.start(p)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			result.SubResults[i] = taskResult
+			if taskResult.Error {
+				result.Error = true
+
+				if tl.exitOnError && task.exitOnError {
+					preventContinue = true
+				}
+			}
+		}(i, task)
+	}
+
+	wg.Wait()
+	return result
+}
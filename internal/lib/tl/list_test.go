@@ -0,0 +1,101 @@
+package tl
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskListConcurrent(t *testing.T) {
+	var running, maxRunning atomic.Int32
+
+	newTask := func() *Task {
+		return &Task{
+			Title: "task",
+			Run: func(callback TaskCallback) error {
+				n := running.Add(1)
+				defer running.Add(-1)
+
+				for {
+					m := maxRunning.Load()
+					if n <= m || maxRunning.CompareAndSwap(m, n) {
+						break
+					}
+				}
+
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		concurrent int
+		expected   int32
+	}{
+		{concurrent: 1, expected: 1},
+		{concurrent: 2, expected: 2},
+		{concurrent: 0, expected: 4},
+	} {
+		maxRunning.Store(0)
+
+		tasks := []*Task{newTask(), newTask(), newTask(), newTask()}
+		_, err := New(tasks, WithConcurrent(tc.concurrent)).Run()
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, maxRunning.Load(), "concurrent = %d", tc.concurrent)
+	}
+}
+
+func TestTaskListConcurrentExitOnError(t *testing.T) {
+	var mu sync.Mutex
+	var ran []string
+
+	newTask := func(title string, d time.Duration, err error) *Task {
+		return &Task{
+			Title: title,
+			Run: func(callback TaskCallback) error {
+				time.Sleep(d)
+
+				mu.Lock()
+				ran = append(ran, title)
+				mu.Unlock()
+
+				return err
+			},
+		}
+	}
+
+	tasks := []*Task{
+		newTask("a", 20*time.Millisecond, errors.New("failed")),
+		newTask("b", 100*time.Millisecond, nil),
+		newTask("c", 0, nil),
+	}
+
+	result, err := New(tasks, WithConcurrent(2)).Run()
+	require.Error(t, err)
+	assert.ElementsMatch(t, []string{"a", "b"}, ran, "running tasks are kept, pending ones are skipped")
+
+	require.NotNil(t, result)
+	require.Len(t, result.SubResults, 3)
+	assert.True(t, result.Error)
+	assert.True(t, result.SubResults[0].Error)
+	assert.GreaterOrEqual(t, result.SubResults[0].Duration, 20*time.Millisecond)
+	assert.False(t, result.SubResults[1].Error)
+	assert.Nil(t, result.SubResults[2], "pending tasks have no result")
+}
+
+func TestConcurrentIsNotInherited(t *testing.T) {
+	l := NewTaskList(nil, WithConcurrent(0))
+	l.use()
+	assert.Equal(t, 0, l.concurrent)
+
+	sub := NewTaskList(nil)
+	sub.option = l.option
+	sub.use()
+	assert.Equal(t, 1, sub.concurrent)
+}
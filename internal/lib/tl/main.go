@@ -9,8 +9,9 @@ import (
 type Runner struct {
 	tl *TaskList
 
-	err  error
-	done bool
+	result *Result
+	err    error
+	done   bool
 }
 
 func New(tasks []*Task, options ...OptionApplier) *Runner {
@@ -19,7 +20,9 @@ func New(tasks []*Task, options ...OptionApplier) *Runner {
 	}
 }
 
-func (runner *Runner) Run() error {
+// Run runs all tasks and returns the result tree,
+// the result is nil if the tasks are not finished (e.g. canceled)
+func (runner *Runner) Run() (*Result, error) {
 	runner.prepare()
 	p := tea.NewProgram(runner.createModel(), tea.WithoutSignals(), tea.WithInput(nil))
 
@@ -27,38 +30,32 @@ func (runner *Runner) Run() error {
 		runner.start(p)
 	}()
 
-	// TODO refactor this (return result in Run)
-
 	_, err := p.Run()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if runner.err != nil {
-		return runner.err
-	}
 	if !runner.done {
-		return fmt.Errorf("canceled")
+		return nil, fmt.Errorf("canceled")
 	}
 
-	return err
+	return runner.result, runner.err
 }
 
 func (runner *Runner) prepare() {
 	runner.tl.prepare()
 }
 
-func (runner *Runner) start(p *tea.Program) *Result {
+func (runner *Runner) start(p *tea.Program) {
 	defer func() {
 		runner.done = true
 		p.Send(tea.Quit())
 	}()
 
-	result := runner.tl.start(p)
-	if result.Error {
+	runner.result = runner.tl.start(p)
+	if runner.result.Error {
 		runner.err = fmt.Errorf("some tasks error")
 	}
-	return result
 }
 
 type runnerModel struct {
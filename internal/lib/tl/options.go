@@ -3,12 +3,14 @@ package tl
 type option struct {
 	inited      bool
 	exitOnError bool
+	concurrent  int
 }
 
 func defaultOption() option {
 	return option{
 		inited:      true,
 		exitOnError: true,
+		concurrent:  1,
 	}
 }
 
@@ -19,3 +21,13 @@ func WithExitOnError(exitOnError bool) OptionApplier {
 		o.exitOnError = exitOnError
 	}
 }
+
+// WithConcurrent sets how many tasks of a task list can run at the same time,
+// n <= 0 means no limit and 1 means run one by one (the default)
+//
+// unlike other options, it's not inherited by the sub task lists
+func WithConcurrent(n int) OptionApplier {
+	return func(o *option) {
+		o.concurrent = n
+	}
+}
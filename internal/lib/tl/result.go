@@ -1,5 +1,7 @@
 package tl
 
+import "time"
+
 type Result struct {
 	Task     *Task
 	TaskList *TaskList
@@ -10,6 +12,7 @@ type Result struct {
 	Hide       bool
 	Error      bool
 	Err        error
+	Duration   time.Duration // including the sub tasks, zero if not enabled
 
 	SubResults []*Result
 }
@@ -3,6 +3,7 @@ package tl
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ImSingee/go-ex/ee"
 	tea "github.com/charmbracelet/bubbletea"
@@ -95,7 +96,9 @@ func (t *Task) start(p *tea.Program) (result *Result) {
 
 	controller := t.controller()
 
+	startedAt := time.Now()
 	defer func() {
+		result.Duration = time.Since(startedAt)
 		t.postRun(result)
 	}()
 
@@ -292,7 +295,7 @@ func (m taskModel) View() string {
 	case taskStatusPending:
 		icon = symBlue("○")
 	case taskStatusRunning:
-		icon = symBlue("○")
+		icon = symBlue("◉")
 	case taskStatusSuccess:
 		icon = symGreen("✓")
 	case taskStatusFailed:
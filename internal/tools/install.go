@@ -72,12 +72,13 @@ func ensureInstalled(root string, quiet bool, apps ...string) error {
 		return err
 	}
 
-	newToolsInfo := cloneToolsMap(currentTools)
+	// pin the installed versions of the tools configured in the repo config only
+	newToolsInfo := make(map[string]string, len(toInstallOrUpdate))
 	for _, app := range toInstallOrUpdate {
 		newToolsInfo[app] = results[app].version
 	}
 
-	return o.writeToolsInfo(newToolsInfo)
+	return o.writeToolsInfo(newToolsInfo, false)
 }
 
 func InstallCommand() *cobra.Command {
@@ -145,17 +146,19 @@ func (o *installOptions) install() error {
 		_ = os.Remove(filepath.Join(o.root, ".kitty", ".bin", app))
 	}
 
-	// generate new tools info
-	newToolsInfo := make(map[string]string, len(currentTools))
-	for app, version := range currentTools {
-		newToolsInfo[app] = version
-	}
-	for _, app := range toInstallOrUpdate {
-		newToolsInfo[app] = results[app].version
+	// generate new tools info, only for the tools passed in the args
+	newToolsInfo := make(map[string]string, len(o.toInstall))
+	for _, t := range o.toInstall {
+		app, _, _ := strings.Cut(t, "@")
+		if result, ok := results[app]; ok {
+			newToolsInfo[app] = result.version
+		} else {
+			newToolsInfo[app] = currentTools[app]
+		}
 	}
 
 	// save tools info
-	err = o.writeToolsInfo(newToolsInfo)
+	err = o.writeToolsInfo(newToolsInfo, true)
 	if err != nil {
 		return ee.Wrap(err, "cannot save tools info")
 	}
@@ -216,15 +219,27 @@ func (o *installOptions) installTools(currentTools map[string]string, toInstallO
 	return results, nil
 }
 
-func (o *installOptions) writeToolsInfo(tools map[string]string) error {
+// writeToolsInfo sets the versions of the tools in the repo config's own tools,
+// the tools not in it are added only if add is true (the tools from extends or other configs are left alone)
+func (o *installOptions) writeToolsInfo(tools map[string]string, add bool) error {
 	return config.PatchKittyConfig(o.root, func(c map[string]gson.JSON) (save bool, err error) {
-		// if c["tools"] not exist and tools is empty, do nothing
-		if _, toolsKeyExist := c["tools"]; !toolsKeyExist && len(tools) == 0 {
-			return false, nil
+		ownTools := map[string]any{}
+		if v, ok := c["tools"]; ok {
+			if ownTools, ok = v.Val().(map[string]any); !ok {
+				return false, ee.Errorf("invalid tools config: must be a string-to-string map")
+			}
 		}
 
-		c["tools"] = gson.New(tools)
-		return true, nil
+		for app, version := range tools {
+			if _, ok := ownTools[app]; ok || add {
+				ownTools[app] = version
+				save = true
+			}
+		}
+		if save {
+			c["tools"] = gson.New(ownTools)
+		}
+		return save, nil
 	})
 }
 
@@ -124,3 +124,63 @@ func TestEnsureInstalledIgnoresUnconfiguredRequestedTool(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestInstallWritesOnlyRequestedToolsToRepoConfig(t *testing.T) {
+	root := t.TempDir()
+	binKey := string(binkey.GetCurrentBinKey())
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apps/tool/manifest.json":
+			_, _ = fmt.Fprintf(w, `{
+				"tags": {"latest": "2.0.0"},
+				"versions": {
+					"2.0.0": {
+						"bin": {
+							"%s": {"url": "%s/tool-bin"}
+						}
+					}
+				}
+			}`, binKey, server.URL)
+		case "/tool-bin":
+			_, _ = w.Write([]byte("#!/bin/sh\necho new\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	if err := os.MkdirAll(filepath.Join(root, "shared"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "shared", "kitty.json"), []byte(`{"tools":{"preset":"-"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configJSON := fmt.Sprintf(`{"extends":"./shared/kitty.json","registry":%q,"tools":{"own":"-"}}`, server.URL+"/")
+	if err := os.WriteFile(filepath.Join(root, ".kittyrc.json"), []byte(configJSON), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (&installOptions{root: root, quiet: true, toInstall: []string{"tool"}}).install(); err != nil {
+		t.Fatal(err)
+	}
+
+	configData, err := os.ReadFile(filepath.Join(root, ".kittyrc.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsedConfig struct {
+		Extends string            `json:"extends"`
+		Tools   map[string]string `json:"tools"`
+	}
+	if err := json.Unmarshal(configData, &parsedConfig); err != nil {
+		t.Fatal(err)
+	}
+	if parsedConfig.Extends != "./shared/kitty.json" {
+		t.Fatalf("config extends = %q, want ./shared/kitty.json", parsedConfig.Extends)
+	}
+	if want := map[string]string{"own": "-", "tool": "2.0.0"}; fmt.Sprint(parsedConfig.Tools) != fmt.Sprint(want) {
+		t.Fatalf("config tools = %v, want %v (the tools of extends are not copied)", parsedConfig.Tools, want)
+	}
+}
@@ -24,16 +24,12 @@ type tool struct {
 // but version can be any string in fact
 // and version can be a '-' to indicate it's an external tool
 func (o *listOptions) getCurrentToolsMap() (map[string]string, error) {
-	c, err := config.GetKittyConfig(o.root)
+	m, err := config.Resolve(o.root)
 	if err != nil {
-		if ee.Is(err, os.ErrNotExist) {
-			return map[string]string{}, nil
-		} else {
-			return nil, err
-		}
+		return nil, err
 	}
 
-	tools_ := c["tools"].Val()
+	tools_, _ := m.Get("tools")
 	if tools_ == nil {
 		return map[string]string{}, nil
 	}
@@ -119,12 +115,3 @@ func convertToolsMapToToolsSlice(m map[string]string) []*tool {
 
 	return result
 }
-
-func cloneToolsMap(m map[string]string) map[string]string {
-	result := make(map[string]string, len(m))
-	for name, version := range m {
-		result[name] = version
-	}
-
-	return result
-}
@@ -0,0 +1,20 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("validate multiple config files", func(t *testing.T) {
+		setup(t)
+		t.Setenv("KITTY_CONFIG_DIR", t.TempDir())
+
+		runBash(t, `echo '{}' > .kittyrc.json && echo '{}' > kitty.config.json`)
+
+		output := expectFailRunBash(t, "kitty config validate")
+		assert.Contains(t, output, "multiple kitty config files found")
+		assert.Contains(t, output, "found 1 problem(s)")
+	})
+}
@@ -131,21 +131,24 @@ func expectSuccessRunBash(t *testing.T, script string) {
 	runCommand(t, "bash", "-c", script)
 }
 
-func expectFailRunBash(t *testing.T, script string) {
+func expectFailRunBash(t *testing.T, script string) string {
 	t.Helper()
 
-	expectFailRunCommand(t, "bash", "-c", script)
+	return expectFailRunCommand(t, "bash", "-c", script)
 }
 
-func expectFailRunCommand(t *testing.T, args ...string) {
+// expectFailRunCommand returns the combined output of the failed command
+func expectFailRunCommand(t *testing.T, args ...string) string {
 	t.Helper()
 
 	cmd := exec.Command(args[0], args[1:]...)
 
-	err := cmd.Run()
+	output, err := cmd.CombinedOutput()
 	require.Error(t, err)
 	var exitError = &exec.ExitError{}
 	require.ErrorAs(t, err, &exitError)
+
+	return string(output)
 }
 
 func runBash(t *testing.T, script string) string {